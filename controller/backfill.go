@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"log-detect/entities"
+	"log-detect/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get Backfill Jobs
+// @Tags Backfill
+// @Accept  json
+// @Produce  json
+// @Param index_id query int false "index id"
+// @Success 200 {object} []entities.BackfillJob
+// @Router /Backfill/GetAll [get]
+func GetBackfillJobs(c *gin.Context) {
+	indexID, _ := strconv.Atoi(c.Query("index_id"))
+
+	res := services.GetBackfillJobs(indexID)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Get Backfill Job
+// @Description 取得回補工作與進度
+// @Tags Backfill
+// @Accept  json
+// @Produce  json
+// @Param id path int true "id"
+// @Success 200 {object} entities.BackfillJob
+// @Router /Backfill/{id} [get]
+func GetBackfillJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.GetBackfillJob(id)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Create Backfill Job
+// @Description 依 index 排程重播過去的檢查區間並寫入 TimescaleDB（標記為 backfilled，不發送通知）
+// @Tags Backfill
+// @Accept  json
+// @Produce  json
+// @Param BackfillRequest body entities.BackfillRequest true "backfill request"
+// @Success 200 {object} entities.BackfillJob
+// @Router /Backfill/Create [post]
+func CreateBackfillJob(c *gin.Context) {
+	body := new(entities.BackfillRequest)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	createdBy := ""
+	if username, exists := c.Get("username"); exists {
+		createdBy = username.(string)
+	}
+
+	res := services.StartBackfill(*body, createdBy)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Cancel Backfill Job
+// @Description 取消執行中的回補工作，目前的區間完成後停止
+// @Tags Backfill
+// @Accept  json
+// @Produce  json
+// @Param id path int true "id"
+// @Success 200 {object} string
+// @Router /Backfill/Cancel/{id} [post]
+func CancelBackfillJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.CancelBackfill(id)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Msg)
+}
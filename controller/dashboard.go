@@ -185,6 +185,8 @@ func GetDeviceStatusOverview(c *gin.Context) {
 // @Produce  json
 // @Param limit query int false "Number of alerts to return (default: 10)"
 // @Param status query string false "Alert status filter (active, resolved, acknowledged)"
+// @Param alert_type query string false "Alert type filter (offline, warning, error)"
+// @Param logname query string false "Logname filter"
 // @Success 200 {object} models.Response
 // @Failure 401 {object} models.Response
 // @Security ApiKeyAuth
@@ -203,6 +205,12 @@ func GetRecentAlerts(c *gin.Context) {
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
+	if alertType := c.Query("alert_type"); alertType != "" {
+		query = query.Where("alert_type = ?", alertType)
+	}
+	if logname := c.Query("logname"); logname != "" {
+		query = query.Where("logname = ?", logname)
+	}
 
 	var alerts []entities.AlertHistory
 	if err := query.Find(&alerts).Error; err != nil {
@@ -213,6 +221,29 @@ func GetRecentAlerts(c *gin.Context) {
 	c.JSON(http.StatusOK, alerts)
 }
 
+// @Summary Get Open Alerts
+// @Description 取得尚未恢復的設備事件（active / acknowledged），duration 為目前已離線秒數
+// @Tags Dashboard
+// @Accept  json
+// @Produce  json
+// @Param logname query string false "Logname filter"
+// @Param alert_type query string false "Alert type filter (default: offline)"
+// @Success 200 {object} []entities.AlertHistory
+// @Failure 401 {object} models.Response
+// @Security ApiKeyAuth
+// @Router /dashboard/alerts/open [get]
+func GetOpenAlerts(c *gin.Context) {
+	alertType := c.DefaultQuery("alert_type", services.IncidentTypeOffline)
+
+	incidents, err := services.NewDeviceIncidentService().GetOpenIncidents(c.Query("logname"), alertType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch open alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, incidents)
+}
+
 // @Summary Create Alert
 // @Tags Dashboard
 // @Accept  json
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"log-detect/entities"
+	"log-detect/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get Detect Runs
+// @Description 查詢每次偵測的執行紀錄（查詢區間、ES 診斷、比對結果、通知結果）
+// @Tags DetectRun
+// @Accept  json
+// @Produce  json
+// @Param target_id query int false "target id"
+// @Param index_id query int false "index id"
+// @Param logname query string false "logname"
+// @Param status query string false "running / success / partial / failed"
+// @Param from query int false "開始時間起（Unix 秒）"
+// @Param to query int false "開始時間迄（Unix 秒）"
+// @Param limit query int false "limit (default 100)"
+// @Param offset query int false "offset"
+// @Success 200 {object} []entities.DetectRun
+// @Router /DetectRun/List [get]
+func GetDetectRuns(c *gin.Context) {
+	query := services.DetectRunQuery{
+		Logname: c.Query("logname"),
+		Status:  c.Query("status"),
+	}
+	query.TargetID, _ = strconv.Atoi(c.Query("target_id"))
+	query.IndexID, _ = strconv.Atoi(c.Query("index_id"))
+	query.From, _ = strconv.ParseInt(c.Query("from"), 10, 64)
+	query.To, _ = strconv.ParseInt(c.Query("to"), 10, 64)
+	query.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "100"))
+	query.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	res := services.GetDetectRuns(query)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Get Detect Run Detail
+// @Tags DetectRun
+// @Accept  json
+// @Produce  json
+// @Param id path int true "id"
+// @Success 200 {object} services.DetectRunDetail
+// @Router /DetectRun/{id} [get]
+func GetDetectRun(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.GetDetectRun(id)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Run Detect Now
+// @Description 立即對單一 index 執行偵測，回傳 buckets、比對結果與通知內容；dry_run（預設 true）時不建立設備、不寫歷史、不發通知
+// @Tags DetectRun
+// @Accept  json
+// @Produce  json
+// @Param RunDetect body entities.RunDetectRequest true "run detect"
+// @Success 200 {object} services.DetectReport
+// @Router /DetectRun/Run [post]
+func RunDetectNow(c *gin.Context) {
+	body := new(entities.RunDetectRequest)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.RunDetectNow(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
@@ -1,8 +1,11 @@
 package controller
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 	// "log-detect/handler"
 	// "log-detect/models"
 	"log-detect/entities"
@@ -52,13 +55,14 @@ func UpdateDevice(c *gin.Context) {
 
 	body := new(entities.Device)
 
-	err := c.Bind(&body)
+	// 只更新有送出的欄位；狀態請用 /Device/Status 修改
+	fields, err := bindWithFields(c, body)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	res := services.UpdateDevice(*body)
+	res := services.UpdateDevice(*body, fields)
 
 	if !res.Success {
 		c.JSON(http.StatusBadRequest, res.Msg)
@@ -72,11 +76,25 @@ func UpdateDevice(c *gin.Context) {
 // @Tags Device
 // @Accept  json
 // @Produce  json
+// @Param q query string false "name keyword"
+// @Param device_group query string false "device group"
+// @Param tag query string false "tag"
+// @Param owner query string false "owner email"
+// @Param site query string false "site"
+// @Param environment query string false "environment"
+// @Param criticality query string false "critical, high, medium, low"
+// @Param status query string false "active, decommissioned, expected_absent"
 // @Success 200 {object} models.Response
 // @Router /Device/GetAll [get]
 func GetAllDevices(c *gin.Context) {
 
-	res := services.GetAllDevices()
+	filter := entities.DeviceFilter{}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.GetAllDevices(filter)
 
 	if !res.Success {
 		c.JSON(http.StatusBadRequest, res.Msg)
@@ -143,4 +161,120 @@ func GetDeviceGroup(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, res.Body)
-}
\ No newline at end of file
+}
+
+// @Summary Set Device Status
+// @Description 批次設定設備狀態：active、decommissioned（除役，不再告警）、expected_absent（預期缺席到 absent_until 後自動恢復檢查）
+// @Tags Device
+// @Accept  json
+// @Produce  json
+// @Param DeviceStatusRequest body entities.DeviceStatusRequest true "device status"
+// @Success 200 {object} []entities.Device
+// @Router /Device/Status [put]
+func SetDeviceStatus(c *gin.Context) {
+	body := new(entities.DeviceStatusRequest)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.SetDeviceStatus(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Import Devices
+// @Description 由 CSV / XLSX 匯入設備；mapping 為 JSON（設備欄位 -> 檔案欄位標題），preview=true 時只回傳檢查結果；有無效列時不寫入並回傳 400 與檢查結果
+// @Tags Device
+// @Accept  multipart/form-data
+// @Produce  json
+// @Param file formData file true "csv or xlsx file"
+// @Param mode formData string false "upsert (default) or replace_group"
+// @Param preview formData bool false "preview only"
+// @Param device_group formData string false "device group used when the file has no device_group column"
+// @Param sheet formData string false "xlsx sheet name"
+// @Param mapping formData string false "column mapping JSON, e.g. {\"name\":\"Hostname\"}"
+// @Success 200 {object} entities.DeviceImportReport
+// @Router /Device/Import [post]
+func ImportDevices(c *gin.Context) {
+	opts := entities.DeviceImportOptions{}
+	if err := c.ShouldBind(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	if mapping := c.PostForm("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &opts.Mapping); err != nil {
+			c.JSON(http.StatusBadRequest, fmt.Sprintf("invalid mapping: %s", err.Error()))
+			return
+		}
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	format, err := services.DeviceFileFormat(header.Filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+	defer file.Close()
+
+	res := services.ImportDevices(file, format, opts)
+
+	if !res.Success {
+		if res.Body != nil {
+			c.JSON(http.StatusBadRequest, res)
+			return
+		}
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Export Devices
+// @Description 匯出設備清單（CSV / XLSX），可依群組、標籤等條件篩選
+// @Tags Device
+// @Produce  octet-stream
+// @Param format query string false "csv (default) or xlsx"
+// @Param device_group query string false "device group"
+// @Param tag query string false "tag"
+// @Success 200 {file} file
+// @Router /Device/Export [get]
+func ExportDevices(c *gin.Context) {
+	filter := entities.DeviceFilter{}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := c.DefaultQuery("format", services.DeviceFileCSV)
+	res := services.ExportDevices(filter, format)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == services.DeviceFileXLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	filename := fmt.Sprintf("devices_%s.%s", time.Now().Format("20060102_150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Data(http.StatusOK, contentType, res.Body.([]byte))
+}
@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"log-detect/entities"
+	"log-detect/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get All Device Groups
+// @Tags DeviceGroup
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []entities.DeviceGroup
+// @Router /DeviceGroup/GetAll [get]
+func GetDeviceGroups(c *gin.Context) {
+	res := services.GetDeviceGroups()
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Get Device Group Tree
+// @Description 以 children 巢狀回傳群組階層
+// @Tags DeviceGroup
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []entities.DeviceGroup
+// @Router /DeviceGroup/Tree [get]
+func GetDeviceGroupTree(c *gin.Context) {
+	res := services.GetDeviceGroupTree()
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Create Device Group
+// @Tags DeviceGroup
+// @Accept  json
+// @Produce  json
+// @Param DeviceGroup body entities.DeviceGroup true "device group"
+// @Success 200 {object} entities.DeviceGroup
+// @Router /DeviceGroup/Create [post]
+func CreateDeviceGroup(c *gin.Context) {
+	body := new(entities.DeviceGroup)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.CreateDeviceGroup(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Update Device Group
+// @Description 更新說明、上層群組、預設收件人與標籤，名稱請用 /DeviceGroup/Rename
+// @Tags DeviceGroup
+// @Accept  json
+// @Produce  json
+// @Param DeviceGroup body entities.DeviceGroup true "device group"
+// @Success 200 {object} entities.DeviceGroup
+// @Router /DeviceGroup/Update [put]
+func UpdateDeviceGroup(c *gin.Context) {
+	body := new(entities.DeviceGroup)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.UpdateDeviceGroup(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Rename Device Group
+// @Description 更新設備、index、維護時段與歷史資料中的群組名稱，TimescaleDB 歷史可用新名稱查詢
+// @Tags DeviceGroup
+// @Accept  json
+// @Produce  json
+// @Param DeviceGroupRenameRequest body entities.DeviceGroupRenameRequest true "rename request"
+// @Success 200 {object} entities.DeviceGroupChangeResult
+// @Router /DeviceGroup/Rename [post]
+func RenameDeviceGroup(c *gin.Context) {
+	body := new(entities.DeviceGroupRenameRequest)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.RenameDeviceGroup(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Merge Device Groups
+// @Description 將來源群組併入目標群組後刪除來源群組，同名設備保留目標群組的設定
+// @Tags DeviceGroup
+// @Accept  json
+// @Produce  json
+// @Param DeviceGroupMergeRequest body entities.DeviceGroupMergeRequest true "merge request"
+// @Success 200 {object} entities.DeviceGroupChangeResult
+// @Router /DeviceGroup/Merge [post]
+func MergeDeviceGroups(c *gin.Context) {
+	body := new(entities.DeviceGroupMergeRequest)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.MergeDeviceGroups(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Remap Device Group History
+// @Description 依群組的舊名稱重新建立 TimescaleDB 歷史的對應，改名或合併結果 history_remapped 為 false 時重試
+// @Tags DeviceGroup
+// @Accept  json
+// @Produce  json
+// @Param DeviceGroupRemapRequest body entities.DeviceGroupRemapRequest true "remap request"
+// @Success 200 {object} entities.DeviceGroupChangeResult
+// @Router /DeviceGroup/RemapHistory [post]
+func RemapDeviceGroupHistory(c *gin.Context) {
+	body := new(entities.DeviceGroupRemapRequest)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.RemapDeviceGroupHistory(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Delete Device Group
+// @Description 只能刪除沒有子群組、設備與 index 的群組
+// @Tags DeviceGroup
+// @Accept  json
+// @Produce  json
+// @Param id path int true "id"
+// @Success 200 {object} string
+// @Router /DeviceGroup/Delete/{id} [delete]
+func DeleteDeviceGroup(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.DeleteDeviceGroup(id)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Msg)
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"log-detect/entities"
+	"log-detect/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get Discovered Devices
+// @Description 取得偵測時新出現、尚未登錄的設備（待審核佇列）
+// @Tags Device
+// @Accept  json
+// @Produce  json
+// @Param status query string false "pending, rejected"
+// @Param device_group query string false "device group"
+// @Param index_id query int false "index id"
+// @Success 200 {object} []entities.DiscoveredDevice
+// @Router /Device/Discovered [get]
+func GetDiscoveredDevices(c *gin.Context) {
+	indexID, _ := strconv.Atoi(c.Query("index_id"))
+
+	res := services.GetDiscoveredDevices(c.Query("status"), c.Query("device_group"), indexID)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Approve Discovered Devices
+// @Description 批次核准設備，加入所屬設備群組並開始監控
+// @Tags Device
+// @Accept  json
+// @Produce  json
+// @Param DiscoveredDeviceReviewRequest body entities.DiscoveredDeviceReviewRequest true "discovered device ids"
+// @Success 200 {object} []entities.Device
+// @Router /Device/Discovered/Approve [post]
+func ApproveDiscoveredDevices(c *gin.Context) {
+	body := new(entities.DiscoveredDeviceReviewRequest)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reviewer := ""
+	if username, exists := c.Get("username"); exists {
+		reviewer = username.(string)
+	}
+
+	res := services.ApproveDiscoveredDevices(body.IDs, reviewer)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Reject Discovered Devices
+// @Description 批次拒絕設備，之後再出現不會通知也不會加入監控
+// @Tags Device
+// @Accept  json
+// @Produce  json
+// @Param DiscoveredDeviceReviewRequest body entities.DiscoveredDeviceReviewRequest true "discovered device ids"
+// @Success 200 {object} int
+// @Router /Device/Discovered/Reject [post]
+func RejectDiscoveredDevices(c *gin.Context) {
+	body := new(entities.DiscoveredDeviceReviewRequest)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reviewer := ""
+	if username, exists := c.Get("username"); exists {
+		reviewer = username.(string)
+	}
+
+	res := services.RejectDiscoveredDevices(body.IDs, reviewer)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
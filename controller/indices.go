@@ -64,13 +64,14 @@ func UpdateIndices(c *gin.Context) {
 
 	body := new(entities.Index)
 
-	err := c.Bind(&body)
+	// 只更新有送出的欄位，舊版用戶端未送出的新欄位不會被清成零值
+	fields, err := bindWithFields(c, body)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, err.Error())
 		return
 	}
 
-	res := services.UpdateIndices(*body)
+	res := services.UpdateIndices(*body, fields)
 
 	if !res.Success {
 		c.JSON(http.StatusBadRequest, res.Msg)
@@ -167,3 +168,30 @@ func GetIndiceData(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, res.Body)
 }
+
+// @Summary Preview Schedule
+// @Description 驗證排程設定（minutes / hours / days / cron）並回傳接下來的執行時間
+// @Tags Indices
+// @Accept  json
+// @Produce  json
+// @Param Schedule body entities.SchedulePreviewRequest true "schedule"
+// @Success 200 {object} entities.SchedulePreview
+// @Router /Indices/NextRuns [post]
+func PreviewSchedule(c *gin.Context) {
+
+	body := new(entities.SchedulePreviewRequest)
+
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.PreviewSchedule(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+	c.JSON(http.StatusOK, res.Body)
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"log-detect/entities"
+	"log-detect/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get All Maintenance Windows
+// @Tags Maintenance
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []entities.MaintenanceWindow
+// @Router /Maintenance/GetAll [get]
+func GetAllMaintenanceWindows(c *gin.Context) {
+	res := services.GetAllMaintenanceWindows()
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Get Active Maintenance Windows
+// @Description 取得目前生效中的維護時段
+// @Tags Maintenance
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []entities.MaintenanceWindow
+// @Router /Maintenance/Active [get]
+func GetActiveMaintenanceWindows(c *gin.Context) {
+	res := services.GetActiveMaintenanceWindows()
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Create Maintenance Window
+// @Description 單次維護設定 start_at / end_at；週期維護設定 cron_expr 與 duration（分鐘）
+// @Tags Maintenance
+// @Accept  json
+// @Produce  json
+// @Param MaintenanceWindow body entities.MaintenanceWindow true "maintenance window"
+// @Success 200 {object} entities.MaintenanceWindow
+// @Router /Maintenance/Create [post]
+func CreateMaintenanceWindow(c *gin.Context) {
+	body := new(entities.MaintenanceWindow)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if username, exists := c.Get("username"); exists {
+		body.CreatedBy = username.(string)
+	}
+
+	res := services.CreateMaintenanceWindow(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Update Maintenance Window
+// @Tags Maintenance
+// @Accept  json
+// @Produce  json
+// @Param MaintenanceWindow body entities.MaintenanceWindow true "maintenance window"
+// @Success 200 {object} entities.MaintenanceWindow
+// @Router /Maintenance/Update [put]
+func UpdateMaintenanceWindow(c *gin.Context) {
+	body := new(entities.MaintenanceWindow)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.UpdateMaintenanceWindow(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Delete Maintenance Window
+// @Tags Maintenance
+// @Accept  json
+// @Produce  json
+// @Param id path int true "id"
+// @Success 200 {object} string
+// @Router /Maintenance/Delete/{id} [delete]
+func DeleteMaintenanceWindow(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.DeleteMaintenanceWindow(id)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Msg)
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"log-detect/entities"
+	"log-detect/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get All Notification Channels
+// @Tags Notification
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []entities.NotificationChannel
+// @Router /Notification/Channel/GetAll [get]
+func GetAllNotificationChannels(c *gin.Context) {
+	res := services.GetAllNotificationChannels()
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Create Notification Channel
+// @Tags Notification
+// @Accept  json
+// @Produce  json
+// @Param NotificationChannel body entities.NotificationChannel true "channel"
+// @Success 200 {object} entities.NotificationChannel
+// @Router /Notification/Channel/Create [post]
+func CreateNotificationChannel(c *gin.Context) {
+	body := new(entities.NotificationChannel)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.CreateNotificationChannel(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Update Notification Channel
+// @Tags Notification
+// @Accept  json
+// @Produce  json
+// @Param NotificationChannel body entities.NotificationChannel true "channel"
+// @Success 200 {object} entities.NotificationChannel
+// @Router /Notification/Channel/Update [put]
+func UpdateNotificationChannel(c *gin.Context) {
+	body := new(entities.NotificationChannel)
+
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.UpdateNotificationChannel(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Delete Notification Channel
+// @Tags Notification
+// @Accept  json
+// @Produce  json
+// @Param id path int true "id"
+// @Success 200 {object} string
+// @Router /Notification/Channel/Delete/{id} [delete]
+func DeleteNotificationChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.DeleteNotificationChannel(id)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Msg)
+}
+
+// @Summary Send Test Notification
+// @Tags Notification
+// @Accept  json
+// @Produce  json
+// @Param id path int true "id"
+// @Success 200 {object} string
+// @Router /Notification/Channel/Test/{id} [post]
+func TestNotificationChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.TestNotificationChannel(id)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Msg)
+}
+
+// @Summary Get Notification Deliveries
+// @Tags Notification
+// @Accept  json
+// @Produce  json
+// @Param source query string false "device / es_monitor"
+// @Param logname query string false "logname"
+// @Param status query string false "sent / failed"
+// @Param limit query int false "limit (default 100)"
+// @Success 200 {object} []entities.NotificationDelivery
+// @Router /Notification/Deliveries [get]
+func GetNotificationDeliveries(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	res := services.GetNotificationDeliveries(c.Query("source"), c.Query("logname"), c.Query("status"), limit)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
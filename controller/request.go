@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"encoding/json"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// bindWithFields 綁定請求並回傳有送出的 JSON 欄位名稱，讓更新只寫入用戶端實際送出的欄位；
+// 非 JSON 請求無法判斷時回傳 nil
+func bindWithFields(c *gin.Context, obj interface{}) ([]string, error) {
+	if c.ContentType() != binding.MIMEJSON {
+		return nil, c.ShouldBind(obj)
+	}
+	if err := c.ShouldBindBodyWith(obj, binding.JSON); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := c.ShouldBindBodyWith(&raw, binding.JSON); err != nil {
+		return nil, err
+	}
+	fields := make([]string, 0, len(raw))
+	for field := range raw {
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
@@ -0,0 +1,207 @@
+package controller
+
+import (
+	"net/http"
+
+	"log-detect/entities"
+	"log-detect/middleware"
+	"log-detect/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get Scheduler Leader
+// @Description 取得目前實例與排程 leader 租約狀態（多實例部署時只有 leader 執行偵測與 ES 監控）
+// @Tags Scheduler
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} entities.LeaderStatus
+// @Failure 401 {object} models.Response
+// @Security ApiKeyAuth
+// @Router /admin/scheduler/leader [get]
+func GetSchedulerLeader(c *gin.Context) {
+	// 檢查管理員權限
+	currentUser, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	if currentUser.Role.Name != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+		return
+	}
+
+	res := services.GetLeaderStatus()
+
+	if !res.Success {
+		c.JSON(http.StatusInternalServerError, res)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Get Detect Executor Stats
+// @Description 取得偵測執行器的佇列統計（全域與每個 ES 連線的等待中 / 執行中數量、等待時間）
+// @Tags Scheduler
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} services.DetectExecutorStats
+// @Failure 401 {object} models.Response
+// @Security ApiKeyAuth
+// @Router /admin/scheduler/executor [get]
+func GetDetectExecutorStats(c *gin.Context) {
+	// 檢查管理員權限
+	currentUser, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	if currentUser.Role.Name != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+		return
+	}
+
+	res := services.GetDetectExecutorStats()
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Get Scheduled Jobs
+// @Description 列出目前實例的所有偵測排程（target / index、cron 表示式、暫停狀態、下次與上次執行時間）
+// @Tags Scheduler
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []entities.ScheduledJob
+// @Failure 401 {object} models.Response
+// @Security ApiKeyAuth
+// @Router /admin/scheduler/jobs [get]
+func GetScheduledJobs(c *gin.Context) {
+	// 檢查管理員權限
+	currentUser, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	if currentUser.Role.Name != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+		return
+	}
+
+	res := services.GetScheduledJobs()
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Pause Scheduled Job
+// @Description 暫停單一 (target, index) 的偵測排程，只影響目前實例且重啟後恢復
+// @Tags Scheduler
+// @Accept  json
+// @Produce  json
+// @Param ScheduledJobRequest body entities.ScheduledJobRequest true "target / index"
+// @Success 200 {object} []entities.ScheduledJob
+// @Failure 400 {object} models.Response
+// @Security ApiKeyAuth
+// @Router /admin/scheduler/jobs/pause [post]
+func PauseScheduledJob(c *gin.Context) {
+	// 檢查管理員權限
+	currentUser, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	if currentUser.Role.Name != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+		return
+	}
+
+	body := new(entities.ScheduledJobRequest)
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.PauseScheduledJob(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Resume Scheduled Job
+// @Description 恢復已暫停的偵測排程
+// @Tags Scheduler
+// @Accept  json
+// @Produce  json
+// @Param ScheduledJobRequest body entities.ScheduledJobRequest true "target / index"
+// @Success 200 {object} []entities.ScheduledJob
+// @Failure 400 {object} models.Response
+// @Security ApiKeyAuth
+// @Router /admin/scheduler/jobs/resume [post]
+func ResumeScheduledJob(c *gin.Context) {
+	// 檢查管理員權限
+	currentUser, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	if currentUser.Role.Name != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+		return
+	}
+
+	body := new(entities.ScheduledJobRequest)
+	if err := c.ShouldBindJSON(body); err != nil {
+		c.JSON(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	res := services.ResumeScheduledJob(*body)
+
+	if !res.Success {
+		c.JSON(http.StatusBadRequest, res.Msg)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
+
+// @Summary Reconcile Schedules
+// @Description 依資料庫中啟用的 targets / indices 重新同步排程（加入缺少、移除多餘、設定變動的重新排程）
+// @Tags Scheduler
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} entities.SchedulerReconcileResult
+// @Failure 500 {object} models.Response
+// @Security ApiKeyAuth
+// @Router /admin/scheduler/reconcile [post]
+func ReconcileSchedules(c *gin.Context) {
+	// 檢查管理員權限
+	currentUser, exists := middleware.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	if currentUser.Role.Name != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin privileges required"})
+		return
+	}
+
+	res := services.ReconcileSchedules()
+
+	if !res.Success {
+		c.JSON(http.StatusInternalServerError, res)
+		return
+	}
+
+	c.JSON(http.StatusOK, res.Body)
+}
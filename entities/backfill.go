@@ -0,0 +1,53 @@
+package entities
+
+import (
+	"log-detect/models"
+)
+
+// 回補工作狀態
+const (
+	BackfillPending   = "pending"
+	BackfillRunning   = "running"
+	BackfillCompleted = "completed"
+	BackfillFailed    = "failed"
+	BackfillCancelled = "cancelled"
+)
+
+// BackfillJob 歷史可用率回補工作（依排程重播過去的檢查區間，寫入 TimescaleDB device_metrics）
+type BackfillJob struct {
+	models.Common
+	ID           int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	IndexID      int    `gorm:"index" json:"index_id" form:"index_id"`
+	TargetID     int    `json:"target_id" form:"target_id"`
+	Logname      string `gorm:"type:varchar(50)" json:"logname"`
+	From         int64  `json:"from" form:"from"` // 回補起始時間（Unix 秒）
+	To           int64  `json:"to" form:"to"`     // 回補結束時間（Unix 秒）
+	SkipExisting bool   `gorm:"type:tinyint(1)" json:"skip_existing" form:"skip_existing"`
+	Status       string `gorm:"type:varchar(20);index" json:"status"`
+
+	// 進度
+	TotalWindows     int   `json:"total_windows"`
+	CompletedWindows int   `json:"completed_windows"`
+	SkippedWindows   int   `json:"skipped_windows"` // 已有資料或查詢失敗而略過的區間
+	RowsWritten      int64 `json:"rows_written"`
+
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at"`
+	Error      string `gorm:"type:text" json:"error"`
+	CreatedBy  string `gorm:"type:varchar(100)" json:"created_by"`
+	Instance   string `gorm:"type:varchar(100)" json:"instance"` // 執行回補的實例
+}
+
+// TableName 指定表名
+func (BackfillJob) TableName() string {
+	return "backfill_jobs"
+}
+
+// BackfillRequest 建立回補工作的參數
+type BackfillRequest struct {
+	IndexID      int    `json:"index_id" binding:"required"`
+	TargetID     int    `json:"target_id"`
+	From         string `json:"from" binding:"required"` // RFC3339
+	To           string `json:"to" binding:"required"`   // RFC3339
+	SkipExisting *bool  `json:"skip_existing"`           // 略過已有資料的區間，預設 true
+}
@@ -0,0 +1,63 @@
+package entities
+
+import (
+	"log-detect/models"
+)
+
+// 偵測執行狀態
+const (
+	DetectRunRunning = "running"
+	DetectRunSuccess = "success"
+	DetectRunPartial = "partial" // ES 結果不完整（逾時 / shard 失敗）
+	DetectRunFailed  = "failed"
+	DetectRunSkipped = "skipped" // 前一次執行尚未結束
+)
+
+// DetectRun 每次 Detect 執行的紀錄（排程是否有跑、看到了什麼）
+type DetectRun struct {
+	models.Common
+	ID       int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	TargetID int    `gorm:"index:idx_detect_runs_target" json:"target_id"`
+	IndexID  int    `gorm:"index:idx_detect_runs_index" json:"index_id"`
+	Logname  string `gorm:"type:varchar(50);index" json:"logname"`
+	Pattern  string `gorm:"type:varchar(50)" json:"pattern"`
+	Trigger  string `gorm:"type:varchar(20)" json:"trigger"` // schedule
+	Status   string `gorm:"type:varchar(20);index" json:"status"`
+
+	// 執行時間（Unix 毫秒）
+	StartedAt  int64 `gorm:"index" json:"started_at"`
+	FinishedAt int64 `json:"finished_at"`
+	DurationMs int64 `json:"duration_ms"`
+
+	// 查詢區間（含時區位移）
+	WindowFrom string `gorm:"type:varchar(40)" json:"window_from"`
+	WindowTo   string `gorm:"type:varchar(40)" json:"window_to"`
+
+	// ES 查詢診斷
+	Took          int      `json:"took"`
+	Pages         int      `json:"pages"`
+	TimedOut      bool     `json:"timed_out"`
+	ShardsTotal   int      `json:"shards_total"`
+	ShardsFailed  int      `json:"shards_failed"`
+	ShardFailures []string `gorm:"serializer:json" json:"shard_failures"`
+	BucketCount   int      `json:"bucket_count"`
+
+	// 比對結果
+	ExpectedCount int `json:"expected_count"` // 資產清單中的設備數
+	AddedCount    int `json:"added_count"`
+	RemovedCount  int `json:"removed_count"`
+	OnlineCount   int `json:"online_count"`
+	WarningCount  int `json:"warning_count"`
+	OfflineCount  int `json:"offline_count"`
+
+	// 通知結果
+	NotificationsSent   int `json:"notifications_sent"`
+	NotificationsFailed int `json:"notifications_failed"`
+
+	Error string `gorm:"type:text" json:"error"`
+}
+
+// TableName 指定表名
+func (DetectRun) TableName() string {
+	return "detect_runs"
+}
@@ -0,0 +1,49 @@
+package entities
+
+import "log-detect/models"
+
+// DeviceGroup 設備群組；devices、indices 與歷史資料的 device_group 欄位存放群組名稱，改名與合併時一併更新
+type DeviceGroup struct {
+	models.Common
+	ID          int      `gorm:"primaryKey;autoIncrement" json:"id" form:"id"`
+	Name        string   `gorm:"type:varchar(50);uniqueIndex" json:"name" form:"name"`
+	Description string   `gorm:"type:varchar(255)" json:"description" form:"description"`
+	ParentID    *int     `gorm:"index" json:"parent_id" form:"parent_id"`
+	Receivers   []string `gorm:"serializer:json" json:"receivers" form:"receivers"` // 預設收件人，連同上層群組的收件人併入該群組的設備通知
+	Tags        []string `gorm:"serializer:json" json:"tags" form:"tags"`
+
+	// 改名或合併前使用過的名稱，TimescaleDB 中以舊名稱寫入的歷史資料依此對應到目前的名稱
+	FormerNames []string `gorm:"serializer:json" json:"former_names"`
+
+	Children []DeviceGroup `gorm:"-" json:"children,omitempty"`
+}
+
+// TableName 指定表名
+func (DeviceGroup) TableName() string {
+	return "device_groups"
+}
+
+// DeviceGroupRenameRequest 群組改名
+type DeviceGroupRenameRequest struct {
+	ID   int    `json:"id" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
+
+// DeviceGroupMergeRequest 將來源群組的設備、index 與歷史資料併入目標群組後刪除來源群組
+type DeviceGroupMergeRequest struct {
+	SourceID int `json:"source_id" binding:"required"`
+	TargetID int `json:"target_id" binding:"required"`
+}
+
+// DeviceGroupRemapRequest 重新建立群組舊名稱與目前名稱在 TimescaleDB 的對應
+type DeviceGroupRemapRequest struct {
+	ID int `json:"id" binding:"required"`
+}
+
+// DeviceGroupChangeResult 改名、合併或重新對應歷史的結果；
+// MySQL 的變更已完成，HistoryRemapped 為 false 時 TimescaleDB 中舊名稱的歷史暫時查不到，可呼叫 /DeviceGroup/RemapHistory 重試
+type DeviceGroupChangeResult struct {
+	Group           DeviceGroup `json:"group"`
+	HistoryRemapped bool        `json:"history_remapped"`
+	Warning         string      `json:"warning,omitempty"`
+}
@@ -0,0 +1,41 @@
+package entities
+
+// 設備匯入模式
+const (
+	DeviceImportUpsert       = "upsert"        // 新增不存在的設備，已存在的更新檔案中有對應欄位的屬性
+	DeviceImportReplaceGroup = "replace_group" // 同 upsert，另外刪除檔案中各群組內未列出的設備
+)
+
+// DeviceImportOptions 匯入設定（multipart 表單欄位）
+type DeviceImportOptions struct {
+	Mode        string            `form:"mode"`         // upsert（預設）或 replace_group
+	Preview     bool              `form:"preview"`      // 只回傳檢查結果，不寫入
+	DeviceGroup string            `form:"device_group"` // 檔案沒有群組欄位時使用的群組
+	Sheet       string            `form:"sheet"`        // XLSX 工作表名稱，預設第一個
+	Mapping     map[string]string `form:"-"`            // 設備欄位 -> 檔案欄位標題，未指定時以欄位名稱對應
+}
+
+// DeviceImportRow 單列的檢查結果
+type DeviceImportRow struct {
+	Row      int      `json:"row"` // 檔案中的列號（含標題列，從 1 開始）
+	Device   Device   `json:"device"`
+	Action   string   `json:"action"` // create, update, invalid
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DeviceImportReport 匯入（或預覽）的結果
+type DeviceImportReport struct {
+	Mode          string            `json:"mode"`
+	Preview       bool              `json:"preview"`
+	Columns       map[string]string `json:"columns"` // 實際採用的欄位對應
+	Total         int               `json:"total"`
+	Created       int               `json:"created"`
+	Updated       int               `json:"updated"`
+	Deleted       int               `json:"deleted"`
+	Invalid       int               `json:"invalid"`
+	Duplicates    []string          `json:"duplicates"`     // 檔案中重複的 群組/名稱
+	UnknownGroups []string          `json:"unknown_groups"` // 尚未建立的群組，匯入時自動建立
+	Removed       []Device          `json:"removed"`        // replace_group 模式會刪除的設備
+	Rows          []DeviceImportRow `json:"rows"`
+}
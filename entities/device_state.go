@@ -0,0 +1,21 @@
+package entities
+
+import "log-detect/models"
+
+// DeviceState 設備偵測狀態（連續缺失次數與狀態切換紀錄），以 target / index / 設備為單位
+type DeviceState struct {
+	models.Common
+	ID                int     `gorm:"primaryKey;autoIncrement" json:"id"`
+	TargetID          int     `gorm:"uniqueIndex:idx_device_states_key" json:"target_id"`
+	IndexID           int     `gorm:"uniqueIndex:idx_device_states_key" json:"index_id"`
+	DeviceName        string  `gorm:"type:varchar(100);uniqueIndex:idx_device_states_key" json:"device_name"`
+	Present           bool    `json:"present"`                             // 最近一次檢查是否有資料
+	ConsecutiveMisses int     `gorm:"default:0" json:"consecutive_misses"` // 連續缺失次數
+	Transitions       []int64 `gorm:"serializer:json" json:"transitions"`  // 狀態切換時間（Unix），只保留抖動視窗內的紀錄
+	LastCheckedAt     int64   `json:"last_checked_at"`
+}
+
+// TableName 指定表名
+func (DeviceState) TableName() string {
+	return "device_states"
+}
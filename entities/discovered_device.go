@@ -0,0 +1,35 @@
+package entities
+
+import "log-detect/models"
+
+// 待審核設備狀態
+const (
+	DiscoveredDevicePending  = "pending"
+	DiscoveredDeviceRejected = "rejected" // 拒絕後不再通知，仍持續更新最後出現時間
+)
+
+// DiscoveredDevice 偵測時在 ES 中出現、但尚未登錄於 devices 的設備；核准後移入 devices
+type DiscoveredDevice struct {
+	models.Common
+	ID          int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	DeviceGroup string `gorm:"type:varchar(50);uniqueIndex:idx_discovered_devices_key" json:"device_group"`
+	Name        string `gorm:"type:varchar(50);uniqueIndex:idx_discovered_devices_key" json:"name"`
+	IndexID     int    `gorm:"index" json:"index_id"` // 第一次發現的 index
+	Logname     string `gorm:"type:varchar(50)" json:"logname"`
+	Status      string `gorm:"type:varchar(20);index" json:"status"`
+	FirstSeen   int64  `json:"first_seen"` // Unix 秒
+	LastSeen    int64  `json:"last_seen"`
+	SeenCount   int    `gorm:"default:0" json:"seen_count"` // 出現過的偵測次數
+	ReviewedBy  string `gorm:"type:varchar(100)" json:"reviewed_by"`
+	ReviewedAt  int64  `json:"reviewed_at"`
+}
+
+// TableName 指定表名
+func (DiscoveredDevice) TableName() string {
+	return "discovered_devices"
+}
+
+// DiscoveredDeviceReviewRequest 批次核准 / 拒絕的請求
+type DiscoveredDeviceReviewRequest struct {
+	IDs []int `json:"ids" binding:"required"`
+}
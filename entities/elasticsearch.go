@@ -22,6 +22,7 @@ type ElasticsearchMonitor struct {
 	Receivers         []string `json:"receivers" gorm:"type:json;serializer:json;comment:告警收件人陣列"`
 	Subject           string   `json:"subject" gorm:"type:varchar(255);comment:告警主題"`
 	Description       string   `json:"description" gorm:"type:text;comment:監控描述"`
+	ChannelIDs        []int    `json:"channel_ids" gorm:"type:json;serializer:json;comment:額外通知通道 ID 陣列"`
 
 	// ES 連線配置（可選，用於複用 indices 的 ES 連線）
 	// NULL 表示使用自己的 host/port，有值表示複用指定的連線配置
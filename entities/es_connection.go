@@ -8,16 +8,18 @@ import (
 // ESConnection Elasticsearch 連線配置（基礎實體）
 type ESConnection struct {
 	models.Common
-	ID          int    `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name        string `gorm:"type:varchar(100);not null;uniqueIndex" json:"name" form:"name"`
-	Host        string `gorm:"type:varchar(255);not null" json:"host" form:"host"`
-	Port        int    `gorm:"type:int;not null;default:9200" json:"port" form:"port"`
-	Username    string `gorm:"type:varchar(100)" json:"username" form:"username"`
-	Password    string `gorm:"type:varchar(255)" json:"password,omitempty" form:"password"` // omitempty 避免在 JSON 中返回密碼
-	EnableAuth  bool   `gorm:"type:tinyint(1);default:0" json:"enable_auth" form:"enable_auth"`
-	UseTLS      bool   `gorm:"type:tinyint(1);default:1" json:"use_tls" form:"use_tls"`
-	IsDefault   bool   `gorm:"type:tinyint(1);default:0" json:"is_default" form:"is_default"`
-	Description string `gorm:"type:text" json:"description" form:"description"`
+	ID             int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name           string `gorm:"type:varchar(100);not null;uniqueIndex" json:"name" form:"name"`
+	Host           string `gorm:"type:varchar(255);not null" json:"host" form:"host"`
+	Port           int    `gorm:"type:int;not null;default:9200" json:"port" form:"port"`
+	Username       string `gorm:"type:varchar(100)" json:"username" form:"username"`
+	Password       string `gorm:"type:varchar(255)" json:"password,omitempty" form:"password"` // omitempty 避免在 JSON 中返回密碼
+	EnableAuth     bool   `gorm:"type:tinyint(1);default:0" json:"enable_auth" form:"enable_auth"`
+	UseTLS         bool   `gorm:"type:tinyint(1);default:1" json:"use_tls" form:"use_tls"`
+	IsDefault      bool   `gorm:"type:tinyint(1);default:0" json:"is_default" form:"is_default"`
+	Description    string `gorm:"type:text" json:"description" form:"description"`
+	QueryTimeout   int    `gorm:"default:0" json:"query_timeout" form:"query_timeout"`     // 偵測查詢逾時（秒），0 表示使用全域設定
+	MaxConcurrency int    `gorm:"default:0" json:"max_concurrency" form:"max_concurrency"` // 同時執行的偵測數上限，0 表示使用全域設定
 }
 
 // TableName 指定表名
@@ -53,6 +55,12 @@ func (c *ESConnection) Validate() error {
 	if c.EnableAuth && (c.Username == "" || c.Password == "") {
 		return fmt.Errorf("啟用認證時，用戶名和密碼不能為空")
 	}
+	if c.QueryTimeout < 0 {
+		return fmt.Errorf("查詢逾時不能為負數")
+	}
+	if c.MaxConcurrency < 0 {
+		return fmt.Errorf("並行上限不能為負數")
+	}
 	return nil
 }
 
@@ -67,27 +75,31 @@ func (c *ESConnection) MaskPassword() *ESConnection {
 
 // ESConnectionSummary ES 連線摘要（用於列表顯示，不包含敏感資訊）
 type ESConnectionSummary struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Host        string `json:"host"`
-	Port        int    `json:"port"`
-	EnableAuth  bool   `json:"enable_auth"`
-	UseTLS      bool   `json:"use_tls"`
-	IsDefault   bool   `json:"is_default"`
-	Description string `json:"description"`
-	Status      string `json:"status,omitempty"` // online, offline, unknown（連線測試結果）
+	ID             int    `json:"id"`
+	Name           string `json:"name"`
+	Host           string `json:"host"`
+	Port           int    `json:"port"`
+	EnableAuth     bool   `json:"enable_auth"`
+	UseTLS         bool   `json:"use_tls"`
+	IsDefault      bool   `json:"is_default"`
+	Description    string `json:"description"`
+	QueryTimeout   int    `json:"query_timeout"`
+	MaxConcurrency int    `json:"max_concurrency"`
+	Status         string `json:"status,omitempty"` // online, offline, unknown（連線測試結果）
 }
 
 // ToSummary 轉換為摘要格式（移除敏感資訊）
 func (c *ESConnection) ToSummary() ESConnectionSummary {
 	return ESConnectionSummary{
-		ID:          c.ID,
-		Name:        c.Name,
-		Host:        c.Host,
-		Port:        c.Port,
-		EnableAuth:  c.EnableAuth,
-		UseTLS:      c.UseTLS,
-		IsDefault:   c.IsDefault,
-		Description: c.Description,
+		ID:             c.ID,
+		Name:           c.Name,
+		Host:           c.Host,
+		Port:           c.Port,
+		EnableAuth:     c.EnableAuth,
+		UseTLS:         c.UseTLS,
+		IsDefault:      c.IsDefault,
+		Description:    c.Description,
+		QueryTimeout:   c.QueryTimeout,
+		MaxConcurrency: c.MaxConcurrency,
 	}
 }
@@ -0,0 +1,72 @@
+package entities
+
+import (
+	"fmt"
+	"log-detect/models"
+)
+
+// 維護時段的作用範圍
+const (
+	MaintenanceScopeTarget      = "target"
+	MaintenanceScopeIndex       = "index"
+	MaintenanceScopeDeviceGroup = "device_group"
+	MaintenanceScopeDevice      = "device"
+)
+
+// MaintenanceWindow 維護時段 / 靜音設定
+// 單次維護使用 StartAt ~ EndAt；週期維護以 CronExpr 決定每次開始時間、持續 Duration 分鐘，StartAt / EndAt 為生效期間
+type MaintenanceWindow struct {
+	models.Common
+	ID          int    `gorm:"primaryKey;autoIncrement" json:"id" form:"id"`
+	Name        string `gorm:"type:varchar(100);not null" json:"name" form:"name"`
+	ScopeType   string `gorm:"type:varchar(20);not null;index" json:"scope_type" form:"scope_type"` // target, index, device_group, device
+	ScopeValue  string `gorm:"type:varchar(100);not null" json:"scope_value" form:"scope_value"`    // target / index 為 ID，device_group / device 為名稱
+	StartAt     int64  `json:"start_at" form:"start_at"`                                            // Unix 秒；週期維護為 0 表示立即生效
+	EndAt       int64  `json:"end_at" form:"end_at"`                                                // Unix 秒；週期維護為 0 表示不限期
+	CronExpr    string `gorm:"type:varchar(100)" json:"cron_expr" form:"cron_expr"`                 // 週期維護的開始時間，空白表示單次維護
+	Duration    int    `gorm:"default:0" json:"duration" form:"duration"`                           // 週期維護每次持續的分鐘數
+	Timezone    string `gorm:"type:varchar(64)" json:"timezone" form:"timezone"`                    // CronExpr 使用的時區
+	Enable      bool   `gorm:"type:tinyint(1);default:1" json:"enable" form:"enable"`
+	Description string `gorm:"type:text" json:"description" form:"description"`
+	CreatedBy   string `gorm:"type:varchar(100)" json:"created_by" form:"created_by"`
+}
+
+// TableName 指定表名
+func (MaintenanceWindow) TableName() string {
+	return "maintenance_windows"
+}
+
+// IsRecurring 是否為週期維護
+func (w *MaintenanceWindow) IsRecurring() bool {
+	return w.CronExpr != ""
+}
+
+// Validate 驗證維護時段設定（cron 語法由 service 驗證）
+func (w *MaintenanceWindow) Validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("維護名稱不能為空")
+	}
+	switch w.ScopeType {
+	case MaintenanceScopeTarget, MaintenanceScopeIndex, MaintenanceScopeDeviceGroup, MaintenanceScopeDevice:
+	default:
+		return fmt.Errorf("不支援的維護範圍: %s", w.ScopeType)
+	}
+	if w.ScopeValue == "" {
+		return fmt.Errorf("維護範圍的值不能為空")
+	}
+
+	if w.IsRecurring() {
+		if w.Duration <= 0 {
+			return fmt.Errorf("週期維護必須設定持續時間")
+		}
+		if w.EndAt > 0 && w.EndAt <= w.StartAt {
+			return fmt.Errorf("生效結束時間必須晚於開始時間")
+		}
+		return nil
+	}
+
+	if w.StartAt <= 0 || w.EndAt <= w.StartAt {
+		return fmt.Errorf("單次維護的結束時間必須晚於開始時間")
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package entities
+
+import (
+	"fmt"
+	"log-detect/models"
+)
+
+// 通知通道類型
+const (
+	ChannelTypeEmail      = "email"
+	ChannelTypeWebhook    = "webhook"
+	ChannelTypeSlack      = "slack"
+	ChannelTypeTeams      = "teams"
+	ChannelTypeLineNotify = "line_notify"
+)
+
+// NotificationChannel 通知通道配置（Webhook / Slack / Teams / LINE Notify）
+type NotificationChannel struct {
+	models.Common
+	ID          int               `gorm:"primaryKey;autoIncrement" json:"id" form:"id"`
+	Name        string            `gorm:"type:varchar(100);not null;uniqueIndex" json:"name" form:"name"`
+	Type        string            `gorm:"type:varchar(20);not null" json:"type" form:"type"` // webhook, slack, teams, line_notify
+	URL         string            `gorm:"type:varchar(500)" json:"url" form:"url"`
+	Token       string            `gorm:"type:varchar(255)" json:"token,omitempty" form:"token"` // LINE Notify access token
+	Headers     map[string]string `gorm:"serializer:json" json:"headers" form:"headers"`         // 通用 webhook 自訂 header
+	Enable      bool              `gorm:"type:tinyint(1);default:1" json:"enable" form:"enable"`
+	Description string            `gorm:"type:text" json:"description" form:"description"`
+}
+
+// TableName 指定表名
+func (NotificationChannel) TableName() string {
+	return "notification_channels"
+}
+
+// Validate 驗證通道配置的有效性
+func (c *NotificationChannel) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("通道名稱不能為空")
+	}
+	switch c.Type {
+	case ChannelTypeWebhook, ChannelTypeSlack, ChannelTypeTeams:
+		if c.URL == "" {
+			return fmt.Errorf("%s 通道必須設定 URL", c.Type)
+		}
+	case ChannelTypeLineNotify:
+		if c.Token == "" {
+			return fmt.Errorf("LINE Notify 通道必須設定 Token")
+		}
+	default:
+		return fmt.Errorf("不支援的通道類型: %s", c.Type)
+	}
+	return nil
+}
+
+// MaskToken 隱藏 Token 與 webhook header 值（用於 API 回應），header 常帶有 Authorization 或 API key
+func (c *NotificationChannel) MaskToken() *NotificationChannel {
+	masked := *c
+	if masked.Token != "" {
+		masked.Token = "********"
+	}
+	if len(c.Headers) > 0 {
+		masked.Headers = make(map[string]string, len(c.Headers))
+		for key, value := range c.Headers {
+			if value != "" {
+				value = "********"
+			}
+			masked.Headers[key] = value
+		}
+	}
+	return &masked
+}
+
+// NotificationDelivery 通知發送紀錄（每個通道 / 收件群組一筆）
+type NotificationDelivery struct {
+	models.Common
+	ID          int    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Source      string `gorm:"type:varchar(20);index" json:"source"` // device, es_monitor
+	SourceID    int    `gorm:"index" json:"source_id"`               // target id 或 monitor id
+	Logname     string `gorm:"type:varchar(100);index" json:"logname"`
+	Event       string `gorm:"type:varchar(50)" json:"event"`
+	ChannelID   int    `gorm:"index" json:"channel_id"` // 0 表示 Email 收件人
+	ChannelType string `gorm:"type:varchar(20)" json:"channel_type"`
+	Recipient   string `gorm:"type:text" json:"recipient"`
+	Subject     string `gorm:"type:varchar(255)" json:"subject"`
+	Status      string `gorm:"type:varchar(20);index" json:"status"` // sent, failed
+	ErrorMsg    string `gorm:"type:text" json:"error_msg,omitempty"`
+	Date        string `gorm:"type:varchar(10);index" json:"date"`
+	Time        string `gorm:"type:varchar(8)" json:"time"`
+}
+
+// TableName 指定表名
+func (NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
+
+// NotificationDigestItem 等待併入摘要通知的明細（低重要性設備的事件）
+type NotificationDigestItem struct {
+	models.Common
+	ID         int      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Source     string   `gorm:"type:varchar(20);index:idx_notification_digest_items_source" json:"source"`
+	SourceID   int      `gorm:"index:idx_notification_digest_items_source" json:"source_id"`
+	Receivers  []string `gorm:"serializer:json" json:"receivers"`
+	ChannelIDs []int    `gorm:"serializer:json" json:"channel_ids"`
+	Subject    string   `gorm:"type:varchar(255)" json:"subject"`
+	Logname    string   `gorm:"type:varchar(100)" json:"logname"`
+	Event      string   `gorm:"type:varchar(50)" json:"event"`
+	Item       string   `gorm:"type:text" json:"item"`
+	Device     string   `gorm:"type:text" json:"device"`   // NotificationDevice JSON
+	EventTime  int64    `json:"event_time"`                // Unix 秒
+	Attempts   int      `gorm:"default:0" json:"attempts"` // 已重送次數
+}
+
+// TableName 指定表名
+func (NotificationDigestItem) TableName() string {
+	return "notification_digest_items"
+}
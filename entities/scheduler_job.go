@@ -0,0 +1,32 @@
+package entities
+
+import "time"
+
+// ScheduledJob 排程器中單一 (target, index) 的偵測排程
+type ScheduledJob struct {
+	TargetID int        `json:"target_id"`
+	Subject  string     `json:"subject"`
+	IndexID  int        `json:"index_id"`
+	Logname  string     `json:"logname"`
+	Schedule string     `json:"schedule"` // cron 表示式
+	EntryID  int        `json:"entry_id"` // cron entry ID，暫停時為 0
+	Paused   bool       `json:"paused"`
+	Next     *time.Time `json:"next,omitempty"`  // 下次執行時間，暫停時為空
+	Prev     *time.Time `json:"prev,omitempty"`  // 上次執行時間，尚未執行過為空
+	Error    string     `json:"error,omitempty"` // 最近一次加入排程失敗的原因；沒有 entry 時此工作不會執行
+}
+
+// ScheduledJobRequest 暫停 / 恢復排程的請求
+type ScheduledJobRequest struct {
+	TargetID int `json:"target_id" binding:"required"`
+	IndexID  int `json:"index_id" binding:"required"`
+}
+
+// SchedulerReconcileResult 依資料庫重新同步排程的結果
+type SchedulerReconcileResult struct {
+	Added       int `json:"added"`
+	Removed     int `json:"removed"`
+	Rescheduled int `json:"rescheduled"`
+	Unchanged   int `json:"unchanged"`
+	Failed      int `json:"failed"` // 排程表示式無效等無法加入的工作
+}
@@ -0,0 +1,23 @@
+package entities
+
+// SchedulerLease 多實例排程的 leader 租約（每個 name 一筆，持有者需在 ExpiresAt 前續約）
+type SchedulerLease struct {
+	Name       string `gorm:"type:varchar(50);primaryKey" json:"name"`
+	Holder     string `gorm:"type:varchar(100)" json:"holder"`
+	AcquiredAt int64  `json:"acquired_at"` // 取得 leader 的時間（Unix 秒）
+	RenewedAt  int64  `json:"renewed_at"`  // 最後續約時間（Unix 秒）
+	ExpiresAt  int64  `json:"expires_at"`  // 租約到期時間（Unix 秒）
+}
+
+// TableName 指定表名
+func (SchedulerLease) TableName() string {
+	return "scheduler_leases"
+}
+
+// LeaderStatus 目前實例與 leader 的狀態
+type LeaderStatus struct {
+	Enabled    bool           `json:"enabled"` // 未啟用時每個實例都執行排程
+	InstanceID string         `json:"instance_id"`
+	IsLeader   bool           `json:"is_leader"`
+	Lease      SchedulerLease `json:"lease"`
+}
@@ -1,7 +1,10 @@
 package entities
 
 import (
+	"fmt"
 	"log-detect/models"
+	"net/mail"
+	"time"
 )
 
 type Target struct {
@@ -10,6 +13,9 @@ type Target struct {
 	Subject string `gorm:"type:varchar(50)" json:"subject" form:"subject"`
 	To      to     `gorm:"serializer:json"  json:"to" form:"to"`
 	Enable  bool   `json:"enable"`
+
+	// 額外通知通道（notification_channels.id），Email 仍使用 To
+	ChannelIDs []int `gorm:"serializer:json" json:"channel_ids" form:"channel_ids"`
 	// Indices []Index `gorm:"foreignKey:TargetID;constraint:OnUpdate:RESTRICT,OnDelete:RESTRICT;"`
 	Indices []Index `gorm:"many2many:indices_targets;foreignKey:ID;reference:ID;" json:"indices"`
 }
@@ -37,27 +43,189 @@ type Index struct {
 	Pattern     string   `gorm:"type:varchar(50)" json:"pattern" form:"pattern"`
 	DeviceGroup string   `gorm:"type:varchar(50)" json:"device_group" form:"device_group"`
 	Logname     string   `gorm:"type:varchar(50)" json:"logname" form:"logname"`
-	Period      string   `gorm:"type:varchar(50)" json:"period" form:"period"`
+	Period      string   `gorm:"type:varchar(50)" json:"period" form:"period"` // minutes, hours, days, cron
 	Unit        int      `type:"int" json:"unit" form:"unit"`
 	Field       string   `gorm:"type:varchar(50)" json:"field" form:"field"`
+	CronExpr    string   `gorm:"type:varchar(100)" json:"cron_expr" form:"cron_expr"` // period 為 cron 時使用的五欄位 cron 表示式
+
+	// 時間欄位與時區（History 的 Date / Time / DateTime 依此時區產生）
+	TimestampField string `gorm:"type:varchar(100);default:@timestamp" json:"timestamp_field" form:"timestamp_field"`
+	Timezone       string `gorm:"type:varchar(64);default:Asia/Taipei" json:"timezone" form:"timezone"` // IANA 時區名稱
+
+	// 日誌延遲到達的處理
+	GraceDelay   int `gorm:"default:0" json:"grace_delay" form:"grace_delay"`     // 檢查區間往前平移的分鐘數，容許 Logstash 寫入延遲
+	RecheckDelay int `gorm:"default:0" json:"recheck_delay" form:"recheck_delay"` // 幾分鐘後以相同區間重新查詢缺失設備，0 表示停用
+
+	// ES 查詢逾時（秒），0 表示使用 ES 連線或全域設定
+	QueryTimeout int `gorm:"default:0" json:"query_timeout" form:"query_timeout"`
+
+	// 額外過濾條件，合併到偵測查詢的 bool.filter
+	FilterType  string `gorm:"type:varchar(10)" json:"filter_type" form:"filter_type"` // "", lucene, kql, dsl
+	FilterQuery string `gorm:"type:text" json:"filter_query" form:"filter_query"`      // query string 或 JSON DSL 片段
+
+	// 告警容忍度
+	MissThreshold int   `gorm:"default:1" json:"miss_threshold" form:"miss_threshold"` // 連續缺失 N 次才視為離線（0 或 1 表示立即告警）
+	FlapWindow    int   `gorm:"default:0" json:"flap_window" form:"flap_window"`       // 抖動偵測視窗（分鐘），0 表示停用
+	FlapThreshold int   `gorm:"default:0" json:"flap_threshold" form:"flap_threshold"` // 視窗內上線/離線切換次數達此值即標記為 warning
+	MinDocCount   int64 `gorm:"default:0" json:"min_doc_count" form:"min_doc_count"`   // 每台設備每個週期的最低日誌筆數，低於此值標記為 warning，0 表示停用
+
+	// 設備名稱正規化：ES bucket key 依序轉小寫、去除網域後綴、套用改寫規則後再與資產清單比對
+	NormalizeLowercase   bool              `gorm:"default:0" json:"normalize_lowercase" form:"normalize_lowercase"`
+	NormalizeStripDomain bool              `gorm:"default:0" json:"normalize_strip_domain" form:"normalize_strip_domain"` // fw01.corp.local -> fw01，IP 位址不處理
+	NormalizeRewrites    []NameRewriteRule `gorm:"serializer:json" json:"normalize_rewrites" form:"normalize_rewrites"`
+
+	// 新出現的設備直接加入 devices（舊行為）；false 時進入待審核佇列並通知
+	AutoAddDevices bool `gorm:"default:0" json:"auto_add_devices" form:"auto_add_devices"`
+
+	// 日誌量異常偵測（與 TimescaleDB 中同星期、同小時的歷史資料比較）
+	AnomalyDetection     bool    `gorm:"default:0" json:"anomaly_detection" form:"anomaly_detection"`
+	AnomalySensitivity   float64 `gorm:"default:3" json:"anomaly_sensitivity" form:"anomaly_sensitivity"`       // 偏離平均幾個標準差視為異常
+	AnomalyBaselineWeeks int     `gorm:"default:4" json:"anomaly_baseline_weeks" form:"anomaly_baseline_weeks"` // 基準值回看週數
 
 	// ES 連線配置（關聯到 es_connections 表）
 	ESConnectionID *int          `gorm:"index" json:"es_connection_id" form:"es_connection_id"`
 	ESConnection   *ESConnection `gorm:"foreignKey:ESConnectionID" json:"es_connection,omitempty"`
 }
 
+// 偵測預設值（與舊版寫死的 @timestamp / +08:00 相同）
+const (
+	DefaultTimestampField = "@timestamp"
+	DefaultTimezone       = "Asia/Taipei"
+)
+
+// GetTimestampField 取得偵測用的時間欄位
+func (i Index) GetTimestampField() string {
+	if i.TimestampField == "" {
+		return DefaultTimestampField
+	}
+	return i.TimestampField
+}
+
+// Location 取得 index 設定的時區
+func (i Index) Location() (*time.Location, error) {
+	if i.Timezone == "" {
+		return time.LoadLocation(DefaultTimezone)
+	}
+	return time.LoadLocation(i.Timezone)
+}
+
+// NameRewriteRule 設備名稱改寫規則（Go regexp 語法，Replace 可使用 $1 等群組參照）
+type NameRewriteRule struct {
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+}
+
+// SchedulePreviewRequest 排程預覽參數
+type SchedulePreviewRequest struct {
+	Period   string `json:"period" form:"period" binding:"required"`
+	Unit     int    `json:"unit" form:"unit"`
+	CronExpr string `json:"cron_expr" form:"cron_expr"`
+	Timezone string `json:"timezone" form:"timezone"`
+	Count    int    `json:"count" form:"count"` // 預設 5 筆，最多 50 筆
+}
+
+// SchedulePreview 排程預覽結果
+type SchedulePreview struct {
+	Schedule string   `json:"schedule"`  // 實際使用的 cron 表示式
+	NextRuns []string `json:"next_runs"` // 接下來的執行時間（RFC3339）
+}
+
+// RunDetectRequest 立即執行偵測的參數
+type RunDetectRequest struct {
+	IndexID  int    `json:"index_id" form:"index_id" binding:"required"`
+	TargetID int    `json:"target_id" form:"target_id"` // 實際執行時必填；dry-run 時用於預覽通知對象
+	From     string `json:"from" form:"from"`           // RFC3339，與 to 同時指定時覆蓋排程區間
+	To       string `json:"to" form:"to"`
+	DryRun   *bool  `json:"dry_run" form:"dry_run"` // 未指定時預設為 true
+}
+
 type Device struct {
 	models.Common
 	ID          int    `gorm:"primaryKey;index" json:"id" form:"id"`
 	DeviceGroup string `gorm:"type:varchar(50)" json:"device_group" form:"device_group"`
 	Name        string `gorm:"type:varchar(50)" json:"name" form:"name"`
+
+	// 設備專屬的最低日誌筆數，NULL 表示沿用 index 設定
+	MinDocCount *int64 `json:"min_doc_count" form:"min_doc_count"`
+
+	// 設備狀態：decommissioned 與 expected_absent 不列入缺失告警，expected_absent 在 AbsentUntil 後自動恢復檢查
+	Status      string `gorm:"type:varchar(20);default:active;index" json:"status" form:"status"`
+	AbsentUntil *int64 `json:"absent_until" form:"absent_until"` // Unix 秒
+
+	// 設備屬性：用於篩選、通知分級（critical 立即另發、low 併入摘要）與郵件內容
+	Tags        []string `gorm:"serializer:json" json:"tags" form:"tags"`
+	Owner       string   `gorm:"type:varchar(100);index" json:"owner" form:"owner"` // 負責人 Email
+	Site        string   `gorm:"type:varchar(50);index" json:"site" form:"site"`
+	Environment string   `gorm:"type:varchar(20);index" json:"environment" form:"environment"` // 例如 prod, staging, dev
+	Criticality string   `gorm:"type:varchar(10)" json:"criticality" form:"criticality"`       // critical, high, medium, low，空白視為 medium
+
+	// ES 中同一台設備的其他名稱（例如 FQDN、IP），比對前換成 Name
+	Aliases []string `gorm:"serializer:json" json:"aliases" form:"aliases"`
 }
 
-type CronList struct {
-	models.Common
-	EntryID  int `gorm:"index" json:"entry_id" form:"entry_id"`
-	TargetID int `gorm:"index" json:"target_id" form:"target_id"`
-	IndexID  int `gorm:"index" json:"index_id" form:"index_id"`
+// 設備狀態
+const (
+	DeviceActive         = "active"
+	DeviceDecommissioned = "decommissioned"
+	DeviceExpectedAbsent = "expected_absent"
+)
+
+// 設備重要性
+const (
+	DeviceCriticalityCritical = "critical"
+	DeviceCriticalityHigh     = "high"
+	DeviceCriticalityMedium   = "medium"
+	DeviceCriticalityLow      = "low"
+)
+
+// Validate 驗證設備屬性
+func (d Device) Validate() error {
+	switch d.Criticality {
+	case "", DeviceCriticalityCritical, DeviceCriticalityHigh, DeviceCriticalityMedium, DeviceCriticalityLow:
+	default:
+		return fmt.Errorf("invalid criticality: %s", d.Criticality)
+	}
+	if d.Owner != "" {
+		if _, err := mail.ParseAddress(d.Owner); err != nil {
+			return fmt.Errorf("invalid owner email: %s", d.Owner)
+		}
+	}
+	for _, alias := range d.Aliases {
+		if alias == "" || alias == d.Name {
+			return fmt.Errorf("invalid alias for %s: %q", d.Name, alias)
+		}
+	}
+	return nil
+}
+
+// Monitored 設備在 now 時是否需要檢查（舊資料沒有狀態時視為 active）
+func (d Device) Monitored(now time.Time) bool {
+	switch d.Status {
+	case DeviceDecommissioned:
+		return false
+	case DeviceExpectedAbsent:
+		return d.AbsentUntil != nil && *d.AbsentUntil <= now.Unix()
+	}
+	return true
+}
+
+// DeviceFilter 設備列表的篩選條件
+type DeviceFilter struct {
+	Q           string `form:"q"` // 名稱關鍵字
+	DeviceGroup string `form:"device_group"`
+	Tag         string `form:"tag"`
+	Owner       string `form:"owner"`
+	Site        string `form:"site"`
+	Environment string `form:"environment"`
+	Criticality string `form:"criticality"`
+	Status      string `form:"status"`
+}
+
+// DeviceStatusRequest 批次設定設備狀態的請求
+type DeviceStatusRequest struct {
+	IDs         []int  `json:"ids" binding:"required"`
+	Status      string `json:"status" binding:"required"` // active, decommissioned, expected_absent
+	AbsentUntil *int64 `json:"absent_until"`              // status 為 expected_absent 時必填（Unix 秒）
 }
 
 type Table_counts struct {
@@ -79,7 +247,7 @@ type History struct {
 	IndexID     int    `gorm:"index" json:"index_id" form:"index_id"`
 
 	// 檢查結果
-	Status  string `gorm:"type:varchar(20)" json:"status" form:"status"` // "online", "offline", "warning", "error"
+	Status  string `gorm:"type:varchar(20)" json:"status" form:"status"` // "online", "offline", "warning", "error", "maintenance"
 	Lost    string `gorm:"type:varchar(10)" json:"lost" form:"lost"`     // "true", "false"
 	LostNum int    `gorm:"default:0" json:"lost_num" form:"lost_num"`
 
@@ -103,6 +271,9 @@ type History struct {
 
 	// 額外元數據
 	Metadata string `gorm:"type:json" json:"metadata" form:"metadata"` // JSON 格式的額外信息
+
+	// 由回補工作產生（只寫入 TimescaleDB）
+	Backfilled bool `gorm:"-" json:"backfilled,omitempty"`
 }
 
 type Logname struct {
@@ -110,9 +281,10 @@ type Logname struct {
 }
 
 type HistoryData struct {
-	Name string `json:"name" form:"name"`
-	Time string `json:"time" form:"time"`
-	Lost string `json:"lost" form:"lost"`
+	Name   string `json:"name" form:"name"`
+	Time   string `json:"time" form:"time"`
+	Lost   string `json:"lost" form:"lost"`
+	Status string `json:"status" form:"status"` // 設備狀態（active, decommissioned, expected_absent）
 }
 
 // 統計和視覺化相關實體
@@ -141,7 +313,7 @@ type DeviceTimeline struct {
 // TimelinePoint 時間點數據
 type TimelinePoint struct {
 	Timestamp    int64  `json:"timestamp"`
-	Status       string `json:"status"` // online, offline, warning, error
+	Status       string `json:"status"` // online, offline, warning, error, maintenance
 	ResponseTime int64  `json:"response_time"`
 	DataCount    int64  `json:"data_count"`
 	ErrorMsg     string `json:"error_msg,omitempty"`
@@ -167,18 +339,27 @@ type TrendData struct {
 	AvgResponseTime int64   `json:"avg_response_time"`
 }
 
-// AlertHistory 告警歷史
+// AlertHistory 告警歷史（設備離線事件：active/acknowledged 期間不重複通知，恢復時轉為 resolved）
 type AlertHistory struct {
 	models.Common
+	ID          int    `gorm:"primaryKey;autoIncrement" json:"id"`
 	Logname     string `gorm:"type:varchar(50);index" json:"logname"`
 	DeviceGroup string `gorm:"type:varchar(50);index" json:"device_group"`
 	DeviceName  string `gorm:"type:varchar(100);index" json:"device_name"`
-	AlertType   string `gorm:"type:varchar(20)" json:"alert_type"` // offline, error, warning
+	TargetID    int    `gorm:"index" json:"target_id"`
+	IndexID     int    `gorm:"index" json:"index_id"`
+	AlertType   string `gorm:"type:varchar(20)" json:"alert_type"` // offline, flapping, low_volume, volume_anomaly, error, warning
 	Severity    string `gorm:"type:varchar(10)" json:"severity"`   // low, medium, high, critical
 	Message     string `gorm:"type:text" json:"message"`
 	Status      string `gorm:"type:varchar(20)" json:"status"` // active, resolved, acknowledged
 	ResolvedAt  *int64 `json:"resolved_at,omitempty"`
 	ResolvedBy  string `gorm:"type:varchar(100)" json:"resolved_by,omitempty"`
+
+	// 事件生命週期
+	StartedAt     int64 `json:"started_at"`                   // 第一次偵測到離線的時間（Unix）
+	LastCheckedAt int64 `json:"last_checked_at"`              // 最近一次仍離線的檢查時間（Unix）
+	CheckCount    int   `gorm:"default:1" json:"check_count"` // 連續離線的檢查次數
+	Duration      int64 `json:"duration"`                     // 恢復時計算的離線秒數
 }
 
 // HistoryArchive 歷史記錄歸檔表
@@ -225,12 +406,15 @@ type HistoryDailyStats struct {
 type DashboardData struct {
 	TotalTargets   int64   `json:"total_targets"`
 	ActiveTargets  int64   `json:"active_targets"`
-	TotalDevices   int64   `json:"total_devices"`
+	TotalDevices   int64   `json:"total_devices"` // 需檢查的設備（不含除役與預期缺席）
 	OnlineDevices  int64   `json:"online_devices"`
 	OfflineDevices int64   `json:"offline_devices"`
 	UptimeRate     float64 `json:"uptime_rate"`
 	ActiveAlerts   int64   `json:"active_alerts"`
 	LastUpdateTime string  `json:"last_update_time"`
+
+	DecommissionedDevices int64 `json:"decommissioned_devices"`
+	ExpectedAbsentDevices int64 `json:"expected_absent_devices"`
 }
 
 // 兼容性實體
@@ -239,14 +423,6 @@ type LognameCheck struct {
 	Lost string `json:"lost" form:"lost"`
 }
 
-type MailHistory struct {
-	models.Common
-	Date    string `gorm:"type:varchar(50)" json:"date" form:"date"`
-	Time    string `gorm:"type:varchar(50)" json:"time" form:"time"`
-	Logname string `gorm:"type:varchar(50)" json:"logname" form:"logname"`
-	Sended  bool   `gorm:"type:boolean" json:"sended" form:"sended"`
-}
-
 // User represents a system user
 type User struct {
 	models.Common
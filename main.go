@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"time"
+	_ "time/tzdata" // 容器內沒有 zoneinfo 時仍可載入 Index 設定的時區
 
 	"log-detect/clients"
 	"log-detect/global"
@@ -65,6 +66,15 @@ func main() {
 	}
 	fmt.Println("✅ Migrations completed")
 
+	// 多實例部署時選出執行排程的 leader
+	if err := services.InitLeaderElection(); err != nil {
+		fmt.Fprintf(os.Stderr, "❌ Leader election failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 上次未完成的回補工作無法接續，標記為失敗
+	services.MarkInterruptedBackfillJobs()
+
 	// Initialize ES client after tables are created
 	clients.SetElkClient()
 
@@ -88,6 +98,9 @@ func main() {
 
 	services.Control_center()
 
+	// 低重要性設備事件的摘要通知
+	services.StartNotificationDigest()
+
 	r := router.LoadRouter()
 	r.Run(global.EnvConfig.Server.Port)
 
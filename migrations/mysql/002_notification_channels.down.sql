@@ -0,0 +1,24 @@
+-- Rollback notification channels
+-- Version: 002
+
+CREATE TABLE IF NOT EXISTS `mail_histories` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `date` VARCHAR(50),
+    `time` VARCHAR(50),
+    `logname` VARCHAR(50),
+    `sended` TINYINT(1),
+    `created_at` INT UNSIGNED,
+    `updated_at` INT UNSIGNED,
+    `deleted_at` INT
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT INTO `mail_histories` (`date`, `time`, `logname`, `sended`, `created_at`, `updated_at`)
+SELECT `date`, `time`, `logname`, IF(`status` = 'sent', 1, 0), `created_at`, `updated_at`
+FROM `notification_deliveries`
+WHERE `channel_type` = 'email' AND `source` = 'device';
+
+ALTER TABLE `elasticsearch_monitors` DROP COLUMN `channel_ids`;
+ALTER TABLE `targets` DROP COLUMN `channel_ids`;
+
+DROP TABLE IF EXISTS `notification_deliveries`;
+DROP TABLE IF EXISTS `notification_channels`;
@@ -0,0 +1,57 @@
+-- Notification channels and delivery log
+-- Version: 002
+-- Created: 2026-10-17
+--
+-- 新增 Webhook / Slack / Teams / LINE Notify 通知通道
+-- 以 notification_deliveries 取代 mail_histories
+
+CREATE TABLE IF NOT EXISTS `notification_channels` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(100) NOT NULL,
+    `type` VARCHAR(20) NOT NULL,
+    `url` VARCHAR(500),
+    `token` VARCHAR(255),
+    `headers` JSON,
+    `enable` TINYINT(1) DEFAULT 1,
+    `description` TEXT,
+    `created_at` INT UNSIGNED,
+    `updated_at` INT UNSIGNED,
+    `deleted_at` INT,
+    UNIQUE INDEX `idx_notification_channels_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `notification_deliveries` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `source` VARCHAR(20),
+    `source_id` INT,
+    `logname` VARCHAR(100),
+    `event` VARCHAR(50),
+    `channel_id` INT DEFAULT 0,
+    `channel_type` VARCHAR(20),
+    `recipient` TEXT,
+    `subject` VARCHAR(255),
+    `status` VARCHAR(20),
+    `error_msg` TEXT,
+    `date` VARCHAR(10),
+    `time` VARCHAR(8),
+    `created_at` INT UNSIGNED,
+    `updated_at` INT UNSIGNED,
+    `deleted_at` INT,
+    INDEX `idx_notification_deliveries_source` (`source`, `source_id`),
+    INDEX `idx_notification_deliveries_logname` (`logname`),
+    INDEX `idx_notification_deliveries_channel_id` (`channel_id`),
+    INDEX `idx_notification_deliveries_status` (`status`),
+    INDEX `idx_notification_deliveries_date` (`date`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Target / ES 監控器的額外通知通道
+ALTER TABLE `targets` ADD COLUMN `channel_ids` JSON;
+ALTER TABLE `elasticsearch_monitors` ADD COLUMN `channel_ids` JSON;
+
+-- 將舊的郵件紀錄搬到 notification_deliveries
+INSERT INTO `notification_deliveries` (`source`, `source_id`, `logname`, `event`, `channel_id`, `channel_type`, `status`, `date`, `time`, `created_at`, `updated_at`)
+SELECT 'device', 0, `logname`, 'device_offline', 0, 'email',
+       IF(`sended` = 1, 'sent', 'failed'), LEFT(`date`, 10), LEFT(`time`, 8), `created_at`, `updated_at`
+FROM `mail_histories`;
+
+DROP TABLE IF EXISTS `mail_histories`;
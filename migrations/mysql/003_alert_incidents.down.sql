@@ -0,0 +1,13 @@
+-- Rollback device offline incident lifecycle
+-- Version: 003
+
+DROP INDEX `idx_alert_histories_status` ON `alert_histories`;
+DROP INDEX `idx_alert_histories_incident` ON `alert_histories`;
+
+ALTER TABLE `alert_histories`
+    DROP COLUMN `duration`,
+    DROP COLUMN `check_count`,
+    DROP COLUMN `last_checked_at`,
+    DROP COLUMN `started_at`,
+    DROP COLUMN `index_id`,
+    DROP COLUMN `target_id`;
@@ -0,0 +1,19 @@
+-- Device offline incident lifecycle
+-- Version: 003
+-- Created: 2026-10-17
+--
+-- alert_histories 改為事件模型：離線時開啟，持續期間不重複通知，恢復時記錄離線時間
+
+ALTER TABLE `alert_histories`
+    ADD COLUMN `target_id` INT DEFAULT 0,
+    ADD COLUMN `index_id` INT DEFAULT 0,
+    ADD COLUMN `started_at` BIGINT DEFAULT 0,
+    ADD COLUMN `last_checked_at` BIGINT DEFAULT 0,
+    ADD COLUMN `check_count` INT DEFAULT 1,
+    ADD COLUMN `duration` BIGINT DEFAULT 0;
+
+-- 舊資料以建立時間作為事件起點
+UPDATE `alert_histories` SET `started_at` = `created_at`, `last_checked_at` = `created_at` WHERE `started_at` = 0;
+
+CREATE INDEX `idx_alert_histories_incident` ON `alert_histories` (`target_id`, `index_id`, `alert_type`, `status`);
+CREATE INDEX `idx_alert_histories_status` ON `alert_histories` (`status`);
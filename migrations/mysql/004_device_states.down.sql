@@ -0,0 +1,9 @@
+-- Rollback consecutive-miss tolerance and flap detection
+-- Version: 004
+
+DROP TABLE IF EXISTS `device_states`;
+
+ALTER TABLE `indices`
+    DROP COLUMN `flap_threshold`,
+    DROP COLUMN `flap_window`,
+    DROP COLUMN `miss_threshold`;
@@ -0,0 +1,24 @@
+-- Consecutive-miss tolerance and flap detection
+-- Version: 004
+-- Created: 2026-10-17
+
+ALTER TABLE `indices`
+    ADD COLUMN `miss_threshold` INT DEFAULT 1,
+    ADD COLUMN `flap_window` INT DEFAULT 0,
+    ADD COLUMN `flap_threshold` INT DEFAULT 0;
+
+-- 每台設備的連續缺失次數與狀態切換紀錄
+CREATE TABLE IF NOT EXISTS `device_states` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `target_id` INT NOT NULL,
+    `index_id` INT NOT NULL,
+    `device_name` VARCHAR(100) NOT NULL,
+    `present` TINYINT(1) DEFAULT 0,
+    `consecutive_misses` INT DEFAULT 0,
+    `transitions` JSON,
+    `last_checked_at` BIGINT,
+    `created_at` INT UNSIGNED,
+    `updated_at` INT UNSIGNED,
+    `deleted_at` INT,
+    UNIQUE INDEX `idx_device_states_key` (`target_id`, `index_id`, `device_name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
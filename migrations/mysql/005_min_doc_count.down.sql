@@ -0,0 +1,5 @@
+-- Rollback minimum document-count thresholds
+-- Version: 005
+
+ALTER TABLE `devices` DROP COLUMN `min_doc_count`;
+ALTER TABLE `indices` DROP COLUMN `min_doc_count`;
@@ -0,0 +1,8 @@
+-- Minimum document-count thresholds
+-- Version: 005
+-- Created: 2026-10-17
+
+ALTER TABLE `indices` ADD COLUMN `min_doc_count` BIGINT DEFAULT 0;
+
+-- NULL 表示沿用 index 設定
+ALTER TABLE `devices` ADD COLUMN `min_doc_count` BIGINT NULL;
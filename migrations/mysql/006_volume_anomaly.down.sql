@@ -0,0 +1,7 @@
+-- Rollback volume anomaly detection settings
+-- Version: 006
+
+ALTER TABLE `indices`
+    DROP COLUMN `anomaly_baseline_weeks`,
+    DROP COLUMN `anomaly_sensitivity`,
+    DROP COLUMN `anomaly_detection`;
@@ -0,0 +1,8 @@
+-- Volume anomaly detection settings
+-- Version: 006
+-- Created: 2026-10-17
+
+ALTER TABLE `indices`
+    ADD COLUMN `anomaly_detection` TINYINT(1) DEFAULT 0,
+    ADD COLUMN `anomaly_sensitivity` DOUBLE DEFAULT 3,
+    ADD COLUMN `anomaly_baseline_weeks` INT DEFAULT 4;
@@ -0,0 +1,6 @@
+-- Rollback per-index filter query
+-- Version: 007
+
+ALTER TABLE `indices`
+    DROP COLUMN `filter_query`,
+    DROP COLUMN `filter_type`;
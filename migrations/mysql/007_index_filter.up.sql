@@ -0,0 +1,7 @@
+-- Per-index filter query
+-- Version: 007
+-- Created: 2026-10-17
+
+ALTER TABLE `indices`
+    ADD COLUMN `filter_type` VARCHAR(10),
+    ADD COLUMN `filter_query` TEXT;
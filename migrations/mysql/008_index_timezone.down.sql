@@ -0,0 +1,6 @@
+-- Rollback per-index timestamp field and timezone
+-- Version: 008
+
+ALTER TABLE `indices`
+    DROP COLUMN `timezone`,
+    DROP COLUMN `timestamp_field`;
@@ -0,0 +1,7 @@
+-- Per-index timestamp field and timezone
+-- Version: 008
+-- Created: 2026-10-17
+
+ALTER TABLE `indices`
+    ADD COLUMN `timestamp_field` VARCHAR(100) DEFAULT '@timestamp',
+    ADD COLUMN `timezone` VARCHAR(64) DEFAULT 'Asia/Taipei';
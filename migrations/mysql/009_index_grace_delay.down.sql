@@ -0,0 +1,6 @@
+-- Rollback ingestion lag grace delay and recheck
+-- Version: 009
+
+ALTER TABLE `indices`
+    DROP COLUMN `recheck_delay`,
+    DROP COLUMN `grace_delay`;
@@ -0,0 +1,7 @@
+-- Ingestion lag grace delay and recheck
+-- Version: 009
+-- Created: 2026-10-17
+
+ALTER TABLE `indices`
+    ADD COLUMN `grace_delay` INT DEFAULT 0,
+    ADD COLUMN `recheck_delay` INT DEFAULT 0;
@@ -0,0 +1,5 @@
+-- Rollback cron expression schedules for indices
+-- Version: 010
+
+ALTER TABLE `indices`
+    DROP COLUMN `cron_expr`;
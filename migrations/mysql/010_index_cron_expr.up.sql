@@ -0,0 +1,6 @@
+-- Cron expression schedules for indices
+-- Version: 010
+-- Created: 2026-10-17
+
+ALTER TABLE `indices`
+    ADD COLUMN `cron_expr` VARCHAR(100);
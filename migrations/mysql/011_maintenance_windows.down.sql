@@ -0,0 +1,4 @@
+-- Rollback maintenance windows and silences
+-- Version: 011
+
+DROP TABLE IF EXISTS `maintenance_windows`;
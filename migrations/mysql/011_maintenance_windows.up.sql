@@ -0,0 +1,22 @@
+-- Maintenance windows and silences
+-- Version: 011
+-- Created: 2026-10-17
+
+CREATE TABLE IF NOT EXISTS `maintenance_windows` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(100) NOT NULL,
+    `scope_type` VARCHAR(20) NOT NULL COMMENT 'target, index, device_group, device',
+    `scope_value` VARCHAR(100) NOT NULL,
+    `start_at` BIGINT DEFAULT 0,
+    `end_at` BIGINT DEFAULT 0,
+    `cron_expr` VARCHAR(100),
+    `duration` INT DEFAULT 0,
+    `timezone` VARCHAR(64),
+    `enable` TINYINT(1) DEFAULT 1,
+    `description` TEXT,
+    `created_by` VARCHAR(100),
+    `created_at` INT UNSIGNED,
+    `updated_at` INT UNSIGNED,
+    `deleted_at` INT,
+    INDEX `idx_maintenance_windows_scope_type` (`scope_type`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,4 @@
+-- Rollback detection run journal
+-- Version: 012
+
+DROP TABLE IF EXISTS `detect_runs`;
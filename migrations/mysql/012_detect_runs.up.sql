@@ -0,0 +1,42 @@
+-- Detection run journal
+-- Version: 012
+-- Created: 2026-10-17
+
+CREATE TABLE IF NOT EXISTS `detect_runs` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `target_id` INT,
+    `index_id` INT,
+    `logname` VARCHAR(50),
+    `pattern` VARCHAR(50),
+    `trigger` VARCHAR(20),
+    `status` VARCHAR(20) COMMENT 'running, success, partial, failed',
+    `started_at` BIGINT COMMENT 'Unix milliseconds',
+    `finished_at` BIGINT,
+    `duration_ms` BIGINT,
+    `window_from` VARCHAR(40),
+    `window_to` VARCHAR(40),
+    `took` INT DEFAULT 0,
+    `pages` INT DEFAULT 0,
+    `timed_out` TINYINT(1) DEFAULT 0,
+    `shards_total` INT DEFAULT 0,
+    `shards_failed` INT DEFAULT 0,
+    `shard_failures` JSON,
+    `bucket_count` INT DEFAULT 0,
+    `expected_count` INT DEFAULT 0,
+    `added_count` INT DEFAULT 0,
+    `removed_count` INT DEFAULT 0,
+    `online_count` INT DEFAULT 0,
+    `warning_count` INT DEFAULT 0,
+    `offline_count` INT DEFAULT 0,
+    `notifications_sent` INT DEFAULT 0,
+    `notifications_failed` INT DEFAULT 0,
+    `error` TEXT,
+    `created_at` INT UNSIGNED,
+    `updated_at` INT UNSIGNED,
+    `deleted_at` INT,
+    INDEX `idx_detect_runs_target` (`target_id`),
+    INDEX `idx_detect_runs_index` (`index_id`),
+    INDEX `idx_detect_runs_logname` (`logname`),
+    INDEX `idx_detect_runs_status` (`status`),
+    INDEX `idx_detect_runs_started_at` (`started_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
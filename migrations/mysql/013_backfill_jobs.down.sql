@@ -0,0 +1,4 @@
+-- Rollback historical backfill jobs
+-- Version: 013
+
+DROP TABLE IF EXISTS `backfill_jobs`;
@@ -0,0 +1,27 @@
+-- Historical backfill jobs
+-- Version: 013
+-- Created: 2026-10-17
+
+CREATE TABLE IF NOT EXISTS `backfill_jobs` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `index_id` INT,
+    `target_id` INT DEFAULT 0,
+    `logname` VARCHAR(50),
+    `from` BIGINT COMMENT 'Unix seconds',
+    `to` BIGINT COMMENT 'Unix seconds',
+    `skip_existing` TINYINT(1) DEFAULT 1,
+    `status` VARCHAR(20) COMMENT 'pending, running, completed, failed, cancelled',
+    `total_windows` INT DEFAULT 0,
+    `completed_windows` INT DEFAULT 0,
+    `skipped_windows` INT DEFAULT 0,
+    `rows_written` BIGINT DEFAULT 0,
+    `started_at` BIGINT,
+    `finished_at` BIGINT,
+    `error` TEXT,
+    `created_by` VARCHAR(100),
+    `created_at` INT UNSIGNED,
+    `updated_at` INT UNSIGNED,
+    `deleted_at` INT,
+    INDEX `idx_backfill_jobs_index_id` (`index_id`),
+    INDEX `idx_backfill_jobs_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,7 @@
+-- Rollback scheduler leader lease
+-- Version: 014
+
+ALTER TABLE `backfill_jobs`
+    DROP COLUMN `instance`;
+
+DROP TABLE IF EXISTS `scheduler_leases`;
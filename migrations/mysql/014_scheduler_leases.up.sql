@@ -0,0 +1,16 @@
+-- Scheduler leader lease for multi-instance deployments
+-- Version: 014
+-- Created: 2026-10-17
+--
+-- 寫入：services/leader.go（leader 定期續約，其他實例在租約到期後接手）
+
+CREATE TABLE IF NOT EXISTS `scheduler_leases` (
+    `name` VARCHAR(50) PRIMARY KEY,
+    `holder` VARCHAR(100) NOT NULL DEFAULT '',
+    `acquired_at` BIGINT DEFAULT 0 COMMENT 'Unix seconds',
+    `renewed_at` BIGINT DEFAULT 0 COMMENT 'Unix seconds',
+    `expires_at` BIGINT DEFAULT 0 COMMENT 'Unix seconds'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE `backfill_jobs`
+    ADD COLUMN `instance` VARCHAR(100);
@@ -0,0 +1,8 @@
+-- Rollback query timeouts
+-- Version: 015
+
+ALTER TABLE `indices`
+    DROP COLUMN `query_timeout`;
+
+ALTER TABLE `es_connections`
+    DROP COLUMN `query_timeout`;
@@ -0,0 +1,9 @@
+-- Query timeouts per ES connection and per index
+-- Version: 015
+-- Created: 2026-10-17
+
+ALTER TABLE `es_connections`
+    ADD COLUMN `query_timeout` INT DEFAULT 0 COMMENT 'seconds, 0 uses detect.query_timeout';
+
+ALTER TABLE `indices`
+    ADD COLUMN `query_timeout` INT DEFAULT 0 COMMENT 'seconds, 0 uses the ES connection setting';
@@ -0,0 +1,5 @@
+-- Rollback per ES connection detection concurrency cap
+-- Version: 016
+
+ALTER TABLE `es_connections`
+    DROP COLUMN `max_concurrency`;
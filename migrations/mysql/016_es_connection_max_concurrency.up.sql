@@ -0,0 +1,6 @@
+-- Per ES connection detection concurrency cap
+-- Version: 016
+-- Created: 2026-10-17
+
+ALTER TABLE `es_connections`
+    ADD COLUMN `max_concurrency` INT DEFAULT 0 COMMENT '0 uses detect.per_connection_limit';
@@ -0,0 +1,5 @@
+-- Drop cron_lists, detection schedules are tracked in memory by the scheduler registry
+-- Version: 017
+-- Created: 2026-10-17
+
+DROP TABLE IF EXISTS `cron_lists`;
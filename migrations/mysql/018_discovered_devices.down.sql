@@ -0,0 +1,7 @@
+-- Rollback pending approval queue for auto discovered devices
+-- Version: 018
+
+ALTER TABLE `indices`
+    DROP COLUMN `auto_add_devices`;
+
+DROP TABLE IF EXISTS `discovered_devices`;
@@ -0,0 +1,26 @@
+-- Pending approval queue for auto discovered devices
+-- Version: 018
+-- Created: 2026-10-17
+
+CREATE TABLE IF NOT EXISTS `discovered_devices` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `device_group` VARCHAR(50),
+    `name` VARCHAR(50),
+    `index_id` INT,
+    `logname` VARCHAR(50),
+    `status` VARCHAR(20) COMMENT 'pending, rejected',
+    `first_seen` BIGINT COMMENT 'Unix seconds',
+    `last_seen` BIGINT COMMENT 'Unix seconds',
+    `seen_count` INT DEFAULT 0,
+    `reviewed_by` VARCHAR(100),
+    `reviewed_at` BIGINT,
+    `created_at` INT UNSIGNED,
+    `updated_at` INT UNSIGNED,
+    `deleted_at` INT,
+    UNIQUE INDEX `idx_discovered_devices_key` (`device_group`, `name`),
+    INDEX `idx_discovered_devices_index_id` (`index_id`),
+    INDEX `idx_discovered_devices_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE `indices`
+    ADD COLUMN `auto_add_devices` TINYINT(1) DEFAULT 0 COMMENT '1 adds new devices directly, 0 queues them for approval';
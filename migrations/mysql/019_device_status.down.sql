@@ -0,0 +1,7 @@
+-- Rollback device status
+-- Version: 019
+
+ALTER TABLE `devices`
+    DROP INDEX `idx_devices_status`,
+    DROP COLUMN `absent_until`,
+    DROP COLUMN `status`;
@@ -0,0 +1,8 @@
+-- Device status for decommissioned and expected absent devices
+-- Version: 019
+-- Created: 2026-10-17
+
+ALTER TABLE `devices`
+    ADD COLUMN `status` VARCHAR(20) DEFAULT 'active' COMMENT 'active, decommissioned, expected_absent',
+    ADD COLUMN `absent_until` BIGINT NULL COMMENT 'Unix seconds, expected_absent devices are checked again after this time',
+    ADD INDEX `idx_devices_status` (`status`);
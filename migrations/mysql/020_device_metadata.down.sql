@@ -0,0 +1,14 @@
+-- Rollback device metadata and notification digest queue
+-- Version: 020
+
+DROP TABLE IF EXISTS `notification_digest_items`;
+
+ALTER TABLE `devices`
+    DROP INDEX `idx_devices_environment`,
+    DROP INDEX `idx_devices_site`,
+    DROP INDEX `idx_devices_owner`,
+    DROP COLUMN `criticality`,
+    DROP COLUMN `environment`,
+    DROP COLUMN `site`,
+    DROP COLUMN `owner`,
+    DROP COLUMN `tags`;
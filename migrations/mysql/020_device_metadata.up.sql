@@ -0,0 +1,31 @@
+-- Device tags, owner, site, environment and criticality, plus digest queue for low criticality devices
+-- Version: 020
+-- Created: 2026-10-17
+
+ALTER TABLE `devices`
+    ADD COLUMN `tags` JSON,
+    ADD COLUMN `owner` VARCHAR(100) COMMENT 'owner email',
+    ADD COLUMN `site` VARCHAR(50),
+    ADD COLUMN `environment` VARCHAR(20),
+    ADD COLUMN `criticality` VARCHAR(10) COMMENT 'critical, high, medium, low',
+    ADD INDEX `idx_devices_owner` (`owner`),
+    ADD INDEX `idx_devices_site` (`site`),
+    ADD INDEX `idx_devices_environment` (`environment`);
+
+CREATE TABLE IF NOT EXISTS `notification_digest_items` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `source` VARCHAR(20),
+    `source_id` INT,
+    `receivers` JSON,
+    `channel_ids` JSON,
+    `subject` VARCHAR(255),
+    `logname` VARCHAR(100),
+    `event` VARCHAR(50),
+    `item` TEXT,
+    `device` TEXT COMMENT 'device attributes as JSON',
+    `event_time` BIGINT COMMENT 'Unix seconds',
+    `created_at` INT UNSIGNED,
+    `updated_at` INT UNSIGNED,
+    `deleted_at` INT,
+    INDEX `idx_notification_digest_items_source` (`source`, `source_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
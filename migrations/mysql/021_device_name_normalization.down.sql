@@ -0,0 +1,10 @@
+-- Rollback device aliases and name normalization rules
+-- Version: 021
+
+ALTER TABLE `indices`
+    DROP COLUMN `normalize_rewrites`,
+    DROP COLUMN `normalize_strip_domain`,
+    DROP COLUMN `normalize_lowercase`;
+
+ALTER TABLE `devices`
+    DROP COLUMN `aliases`;
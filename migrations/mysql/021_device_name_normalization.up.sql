@@ -0,0 +1,11 @@
+-- Device aliases and per-index name normalization rules applied before comparing ES results with the device list
+-- Version: 021
+-- Created: 2026-10-17
+
+ALTER TABLE `devices`
+    ADD COLUMN `aliases` JSON COMMENT 'other names of the device in ES, e.g. FQDN or IP';
+
+ALTER TABLE `indices`
+    ADD COLUMN `normalize_lowercase` TINYINT(1) DEFAULT 0,
+    ADD COLUMN `normalize_strip_domain` TINYINT(1) DEFAULT 0 COMMENT 'fw01.corp.local -> fw01, IP addresses are kept',
+    ADD COLUMN `normalize_rewrites` JSON COMMENT 'list of {pattern, replace} regexp rewrites';
@@ -0,0 +1,4 @@
+-- Rollback device groups
+-- Version: 022
+
+DROP TABLE IF EXISTS `device_groups`;
@@ -0,0 +1,30 @@
+-- Device groups as a first-class entity with hierarchy, default receivers and tags
+-- Version: 022
+-- Created: 2026-10-17
+--
+-- devices / indices / histories 仍以 device_group 欄位存放群組名稱，改名與合併時由服務層一併更新
+
+CREATE TABLE IF NOT EXISTS `device_groups` (
+    `id` INT AUTO_INCREMENT PRIMARY KEY,
+    `name` VARCHAR(50) NOT NULL,
+    `description` VARCHAR(255),
+    `parent_id` INT COMMENT 'device_groups.id',
+    `receivers` JSON COMMENT 'default email receivers, inherited by child groups',
+    `tags` JSON,
+    `former_names` JSON COMMENT 'names used before rename or merge',
+    `created_at` INT UNSIGNED,
+    `updated_at` INT UNSIGNED,
+    `deleted_at` INT,
+    UNIQUE INDEX `idx_device_groups_name` (`name`),
+    INDEX `idx_device_groups_parent_id` (`parent_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 既有的群組名稱建立為群組
+INSERT IGNORE INTO `device_groups` (`name`, `created_at`, `updated_at`)
+SELECT DISTINCT `device_group`, UNIX_TIMESTAMP(), UNIX_TIMESTAMP()
+FROM (
+    SELECT `device_group` FROM `devices`
+    UNION SELECT `device_group` FROM `indices`
+    UNION SELECT `device_group` FROM `discovered_devices`
+) AS `names`
+WHERE `device_group` IS NOT NULL AND `device_group` <> '';
@@ -0,0 +1,5 @@
+-- Rollback auto_add_devices backfill
+-- Version: 023
+
+-- The previous per index values are not recorded; every index returns to approval mode.
+UPDATE `indices` SET `auto_add_devices` = 0;
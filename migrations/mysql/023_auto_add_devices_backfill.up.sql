@@ -0,0 +1,7 @@
+-- Keep auto adding new devices for indices created before the approval queue
+-- Version: 023
+-- Created: 2026-10-17
+
+-- 018 added auto_add_devices with DEFAULT 0, which moved every existing index into approval mode.
+-- Existing indices keep the previous behaviour; only indices created from now on default to approval.
+UPDATE `indices` SET `auto_add_devices` = 1;
@@ -0,0 +1,4 @@
+-- Rollback notification digest retry counter
+-- Version: 024
+
+ALTER TABLE `notification_digest_items` DROP COLUMN `attempts`;
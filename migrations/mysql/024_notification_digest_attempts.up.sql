@@ -0,0 +1,6 @@
+-- Retry counter for notification digest items whose channels failed
+-- Version: 024
+-- Created: 2026-10-17
+
+ALTER TABLE `notification_digest_items`
+    ADD COLUMN `attempts` INT NOT NULL DEFAULT 0 COMMENT 'failed delivery attempts';
@@ -0,0 +1,4 @@
+-- Rollback volume baseline index
+-- Version: 002
+
+DROP INDEX IF EXISTS idx_device_metrics_index_device;
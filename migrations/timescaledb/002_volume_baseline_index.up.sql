@@ -0,0 +1,7 @@
+-- Index for volume baseline queries
+-- Version: 002
+-- Created: 2026-10-17
+--
+-- 讀取：services/anomaly.go（依 index_id + device_id 取同星期、同小時的歷史日誌量）
+
+CREATE INDEX IF NOT EXISTS idx_device_metrics_index_device ON device_metrics (index_id, device_id, time DESC);
@@ -0,0 +1,4 @@
+-- Rollback backfilled flag
+-- Version: 003
+
+ALTER TABLE device_metrics DROP COLUMN IF EXISTS backfilled;
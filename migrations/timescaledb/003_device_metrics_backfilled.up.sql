@@ -0,0 +1,7 @@
+-- Mark backfilled rows in device_metrics
+-- Version: 003
+-- Created: 2026-10-17
+--
+-- 寫入：services/backfill.go（回補工作寫入的資料為 true，排程檢查維持預設 false）
+
+ALTER TABLE device_metrics ADD COLUMN IF NOT EXISTS backfilled BOOLEAN DEFAULT FALSE;
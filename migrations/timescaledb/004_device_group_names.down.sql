@@ -0,0 +1,4 @@
+-- Rollback device group name mapping
+-- Version: 004
+
+DROP TABLE IF EXISTS device_group_names;
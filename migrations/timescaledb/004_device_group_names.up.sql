@@ -0,0 +1,13 @@
+-- Map former device group names to the current name after rename or merge
+-- Version: 004
+-- Created: 2026-10-17
+--
+-- 寫入：services/device_group.go（改名、合併時更新）
+-- 查詢：device_metrics LEFT JOIN device_group_names，COALESCE(group_name, device_group) 即目前的群組名稱
+
+CREATE TABLE IF NOT EXISTS device_group_names (
+    name VARCHAR(50) PRIMARY KEY,
+    group_name VARCHAR(50) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_device_group_names_group_name ON device_group_names (group_name);
@@ -74,6 +74,67 @@ func LoadRouter() *gin.Engine {
 		targetGroup.DELETE("/Delete/:id", controller.DeleteTarget).Use(middleware.PermissionMiddleware("target", "delete"))
 	}
 
+	// Protected Notification routes
+	notificationGroup := apiv1.Group("/Notification")
+	notificationGroup.Use(middleware.AuthMiddleware())
+	notificationGroup.Use(middleware.PermissionMiddleware("target", "read")) // Using target permission for notification channels
+	{
+		notificationGroup.GET("/Channel/GetAll", controller.GetAllNotificationChannels)
+		notificationGroup.POST("/Channel/Create", controller.CreateNotificationChannel).Use(middleware.PermissionMiddleware("target", "create"))
+		notificationGroup.PUT("/Channel/Update", controller.UpdateNotificationChannel).Use(middleware.PermissionMiddleware("target", "update"))
+		notificationGroup.DELETE("/Channel/Delete/:id", controller.DeleteNotificationChannel).Use(middleware.PermissionMiddleware("target", "delete"))
+		notificationGroup.POST("/Channel/Test/:id", controller.TestNotificationChannel)
+		notificationGroup.GET("/Deliveries", controller.GetNotificationDeliveries)
+	}
+
+	// Protected DetectRun routes
+	detectRunGroup := apiv1.Group("/DetectRun")
+	detectRunGroup.Use(middleware.AuthMiddleware())
+	detectRunGroup.Use(middleware.PermissionMiddleware("indices", "read"))
+	{
+		detectRunGroup.GET("/List", controller.GetDetectRuns)
+		detectRunGroup.GET("/:id", controller.GetDetectRun)
+		detectRunGroup.POST("/Run", controller.RunDetectNow).Use(middleware.PermissionMiddleware("indices", "update"))
+	}
+
+	// Protected Backfill routes
+	backfillGroup := apiv1.Group("/Backfill")
+	backfillGroup.Use(middleware.AuthMiddleware())
+	backfillGroup.Use(middleware.PermissionMiddleware("indices", "read"))
+	{
+		backfillGroup.GET("/GetAll", controller.GetBackfillJobs)
+		backfillGroup.GET("/:id", controller.GetBackfillJob)
+		backfillGroup.POST("/Create", controller.CreateBackfillJob).Use(middleware.PermissionMiddleware("indices", "update"))
+		backfillGroup.POST("/Cancel/:id", controller.CancelBackfillJob)
+	}
+
+	// Protected Maintenance routes
+	maintenanceGroup := apiv1.Group("/Maintenance")
+	maintenanceGroup.Use(middleware.AuthMiddleware())
+	maintenanceGroup.Use(middleware.PermissionMiddleware("target", "read"))
+	{
+		maintenanceGroup.GET("/GetAll", controller.GetAllMaintenanceWindows)
+		maintenanceGroup.GET("/Active", controller.GetActiveMaintenanceWindows)
+		maintenanceGroup.POST("/Create", controller.CreateMaintenanceWindow).Use(middleware.PermissionMiddleware("target", "create"))
+		maintenanceGroup.PUT("/Update", controller.UpdateMaintenanceWindow).Use(middleware.PermissionMiddleware("target", "update"))
+		maintenanceGroup.DELETE("/Delete/:id", controller.DeleteMaintenanceWindow).Use(middleware.PermissionMiddleware("target", "delete"))
+	}
+
+	// Protected DeviceGroup routes
+	deviceGroupsGroup := apiv1.Group("/DeviceGroup")
+	deviceGroupsGroup.Use(middleware.AuthMiddleware())
+	deviceGroupsGroup.Use(middleware.PermissionMiddleware("device", "read"))
+	{
+		deviceGroupsGroup.GET("/GetAll", controller.GetDeviceGroups)
+		deviceGroupsGroup.GET("/Tree", controller.GetDeviceGroupTree)
+		deviceGroupsGroup.POST("/Create", controller.CreateDeviceGroup).Use(middleware.PermissionMiddleware("device", "create"))
+		deviceGroupsGroup.PUT("/Update", controller.UpdateDeviceGroup).Use(middleware.PermissionMiddleware("device", "update"))
+		deviceGroupsGroup.POST("/Rename", controller.RenameDeviceGroup)
+		deviceGroupsGroup.POST("/Merge", controller.MergeDeviceGroups)
+		deviceGroupsGroup.POST("/RemapHistory", controller.RemapDeviceGroupHistory)
+		deviceGroupsGroup.DELETE("/Delete/:id", controller.DeleteDeviceGroup).Use(middleware.PermissionMiddleware("device", "delete"))
+	}
+
 	// Protected Device routes
 	deviceGroup := apiv1.Group("/Device")
 	deviceGroup.Use(middleware.AuthMiddleware())
@@ -85,6 +146,12 @@ func LoadRouter() *gin.Engine {
 		deviceGroup.DELETE("/Delete/:id", controller.DeleteDevice).Use(middleware.PermissionMiddleware("device", "delete"))
 		deviceGroup.GET("/count", controller.GetTableCounts)
 		deviceGroup.GET("/GetGroup", controller.GetDeviceGroup)
+		deviceGroup.GET("/Discovered", controller.GetDiscoveredDevices)
+		deviceGroup.POST("/Discovered/Approve", controller.ApproveDiscoveredDevices)
+		deviceGroup.POST("/Discovered/Reject", controller.RejectDiscoveredDevices)
+		deviceGroup.PUT("/Status", controller.SetDeviceStatus)
+		deviceGroup.GET("/Export", controller.ExportDevices)
+		deviceGroup.POST("/Import", controller.ImportDevices)
 	}
 
 	// Protected Indices routes
@@ -99,6 +166,7 @@ func LoadRouter() *gin.Engine {
 		indicesGroup.GET("/GetIndicesByLogname/:logname", controller.GetIndiceData)
 		indicesGroup.GET("/GetIndicesByTargetID/:id", controller.GetIndicesByTargetID)
 		indicesGroup.GET("/GetLogname", controller.GetLogname)
+		indicesGroup.POST("/NextRuns", controller.PreviewSchedule)
 	}
 
 	// Protected History routes
@@ -120,6 +188,7 @@ func LoadRouter() *gin.Engine {
 		dashboardGroup.GET("/devices/status", controller.GetDeviceStatusOverview)
 		dashboardGroup.GET("/devices/:device_name/timeline", controller.GetDeviceTimeline)
 		dashboardGroup.GET("/alerts/recent", controller.GetRecentAlerts)
+		dashboardGroup.GET("/alerts/open", controller.GetOpenAlerts)
 		dashboardGroup.POST("/alerts", controller.CreateAlert)
 		dashboardGroup.PUT("/alerts/:id/status", controller.UpdateAlertStatus)
 
@@ -132,6 +201,14 @@ func LoadRouter() *gin.Engine {
 			dataGroup.POST("/archive-history", controller.ArchiveOldHistory)
 			dataGroup.POST("/create-aggregates", controller.CreateDailyAggregates)
 			dataGroup.GET("/storage-stats", controller.GetStorageStats)
+
+			schedulerGroup := adminGroup.Group("/scheduler")
+			schedulerGroup.GET("/leader", controller.GetSchedulerLeader)
+			schedulerGroup.GET("/executor", controller.GetDetectExecutorStats)
+			schedulerGroup.GET("/jobs", controller.GetScheduledJobs)
+			schedulerGroup.POST("/jobs/pause", controller.PauseScheduledJob)
+			schedulerGroup.POST("/jobs/resume", controller.ResumeScheduledJob)
+			schedulerGroup.POST("/reconcile", controller.ReconcileSchedules)
 		}
 	}
 
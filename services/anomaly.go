@@ -0,0 +1,178 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"math"
+	"time"
+)
+
+// 異常偵測預設值
+const (
+	defaultAnomalySensitivity   = 3.0
+	defaultAnomalyBaselineWeeks = 4
+	anomalyMinSamples           = 3   // 基準樣本數不足時不判斷
+	anomalyMinStdDevRatio       = 0.1 // 標準差下限（平均值的比例），避免歷史資料過於平穩時誤報
+)
+
+// VolumeAnomaly 單台設備的日誌量異常
+type VolumeAnomaly struct {
+	Device    string  `json:"device"`
+	Expected  float64 `json:"expected"` // 歷史平均值
+	StdDev    float64 `json:"std_dev"`
+	Actual    int64   `json:"actual"`
+	ZScore    float64 `json:"z_score"`
+	Direction string  `json:"direction"` // drop, spike
+	Samples   int     `json:"samples"`
+}
+
+// Summary 異常說明（用於通知與歷史紀錄）
+func (a VolumeAnomaly) Summary() string {
+	label := "驟降"
+	if a.Direction == "spike" {
+		label = "暴增"
+	}
+	return fmt.Sprintf("%s 日誌量%s：預期 %.0f ± %.0f 筆，實際 %d 筆", a.Device, label, a.Expected, a.StdDev, a.Actual)
+}
+
+// Metadata 轉為 History.Metadata 的 JSON 字串
+func (a VolumeAnomaly) Metadata() string {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// volumeBaseline 單台設備的歷史基準
+type volumeBaseline struct {
+	mean    float64
+	stddev  float64
+	samples int
+}
+
+// VolumeAnomalyService 以 TimescaleDB device_metrics 為基準的日誌量異常偵測
+type VolumeAnomalyService struct{}
+
+// NewVolumeAnomalyService 建立日誌量異常偵測服務
+func NewVolumeAnomalyService() *VolumeAnomalyService {
+	return &VolumeAnomalyService{}
+}
+
+// Detect 比較本次日誌量與同星期、同小時的歷史基準，回傳顯著偏離的設備
+// dateStr / hourStr 與 History.Date / History.Time 相同格式，確保與寫入時的時區一致
+func (s *VolumeAnomalyService) Detect(index entities.Index, devices []string, docCounts map[string]int64, checkTime time.Time, dateStr string, hourStr string) []VolumeAnomaly {
+	if !index.AnomalyDetection || len(devices) == 0 {
+		return nil
+	}
+	if global.TimescaleDB == nil {
+		log.Logrecord_no_rotate("WARNING", fmt.Sprintf("TimescaleDB not configured, skip anomaly detection for %s", index.Logname))
+		return nil
+	}
+
+	sensitivity := index.AnomalySensitivity
+	if sensitivity <= 0 {
+		sensitivity = defaultAnomalySensitivity
+	}
+	weeks := index.AnomalyBaselineWeeks
+	if weeks <= 0 {
+		weeks = defaultAnomalyBaselineWeeks
+	}
+
+	baselines, err := s.loadBaselines(index, checkTime, weeks, dateStr, hourStr)
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to load volume baseline for %s: %s", index.Logname, err.Error()))
+		return nil
+	}
+
+	var anomalies []VolumeAnomaly
+	for _, device := range devices {
+		baseline, ok := baselines[device]
+		if !ok || baseline.samples < anomalyMinSamples {
+			continue
+		}
+
+		stddev := math.Max(baseline.stddev, baseline.mean*anomalyMinStdDevRatio)
+		if stddev == 0 {
+			continue
+		}
+
+		actual := docCounts[device]
+		z := (float64(actual) - baseline.mean) / stddev
+		if math.Abs(z) < sensitivity {
+			continue
+		}
+
+		direction := "spike"
+		if z < 0 {
+			direction = "drop"
+		}
+		anomalies = append(anomalies, VolumeAnomaly{
+			Device:    device,
+			Expected:  baseline.mean,
+			StdDev:    baseline.stddev,
+			Actual:    actual,
+			ZScore:    math.Round(z*100) / 100,
+			Direction: direction,
+			Samples:   baseline.samples,
+		})
+	}
+
+	return anomalies
+}
+
+// loadBaselines 讀取同 index、同週期設定、同星期、同小時的歷史日誌量
+func (s *VolumeAnomalyService) loadBaselines(index entities.Index, checkTime time.Time, weeks int, dateStr string, hourStr string) (map[string]volumeBaseline, error) {
+	day, err := time.Parse("2006-01-02", dateStr)
+	if err != nil {
+		return nil, err
+	}
+	if len(hourStr) < 2 {
+		return nil, fmt.Errorf("invalid hour string: %s", hourStr)
+	}
+
+	query := `
+		SELECT device_id,
+		       AVG(data_count)::float8,
+		       COALESCE(STDDEV_SAMP(data_count), 0)::float8,
+		       COUNT(*)
+		FROM device_metrics
+		WHERE index_id = $1
+		  AND period = $2
+		  AND unit = $3
+		  AND time >= $4 AND time < $5
+		  AND LEFT(hour_time, 2) = $6
+		  AND EXTRACT(ISODOW FROM date::date) = $7
+		  AND data_count > 0
+		  AND COALESCE(error_code, '') <> 'VOLUME_ANOMALY'
+		GROUP BY device_id
+	`
+
+	// 不含本週期，避免拿自己當基準
+	from := checkTime.AddDate(0, 0, -7*weeks).Add(-time.Hour)
+	to := checkTime.Add(-time.Hour)
+	isoDow := int(day.Weekday())
+	if isoDow == 0 {
+		isoDow = 7
+	}
+
+	rows, err := global.TimescaleDB.Query(query, index.ID, index.Period, index.Unit, from, to, hourStr[:2], isoDow)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	baselines := map[string]volumeBaseline{}
+	for rows.Next() {
+		var device string
+		var b volumeBaseline
+		if err := rows.Scan(&device, &b.mean, &b.stddev, &b.samples); err != nil {
+			return nil, err
+		}
+		baselines[device] = b
+	}
+	return baselines, rows.Err()
+}
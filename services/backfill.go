@@ -0,0 +1,475 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// maxBackfillWindows 單一回補工作最多重播的區間數
+const maxBackfillWindows = 20000
+
+// backfillManager 執行中的回補工作（用於取消）
+var backfillManager = struct {
+	sync.Mutex
+	cancels map[int]context.CancelFunc
+}{cancels: map[int]context.CancelFunc{}}
+
+// StartBackfill 建立回補工作並在背景執行
+func StartBackfill(req entities.BackfillRequest, createdBy string) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if global.TimescaleDB == nil {
+		res.Msg = "TimescaleDB not configured"
+		return res
+	}
+
+	from, err := time.Parse(time.RFC3339, req.From)
+	if err != nil {
+		res.Msg = fmt.Sprintf("Invalid from: %s", err.Error())
+		return res
+	}
+	to, err := time.Parse(time.RFC3339, req.To)
+	if err != nil {
+		res.Msg = fmt.Sprintf("Invalid to: %s", err.Error())
+		return res
+	}
+	if !from.Before(to) || to.After(time.Now()) {
+		res.Msg = "from must be before to, and to must not be in the future"
+		return res
+	}
+
+	var index entities.Index
+	if err := global.Mysql.Where("id = ?", req.IndexID).First(&index).Error; err != nil {
+		res.Msg = fmt.Sprintf("Index not found: %s", err.Error())
+		return res
+	}
+	if req.TargetID > 0 {
+		if _, err := GetTargetByID(req.TargetID); err != nil {
+			res.Msg = fmt.Sprintf("Target not found: %s", err.Error())
+			return res
+		}
+	}
+
+	spec, err := IndexSchedule(index)
+	if err != nil {
+		res.Msg = fmt.Sprintf("Invalid schedule: %s", err.Error())
+		return res
+	}
+	fireTimes, err := backfillFireTimes(spec, from, to)
+	if err != nil {
+		res.Msg = err.Error()
+		return res
+	}
+	if len(fireTimes) == 0 {
+		res.Msg = "No scheduled check falls within the given range"
+		return res
+	}
+
+	var running int64
+	global.Mysql.Model(&entities.BackfillJob{}).
+		Where("index_id = ? AND status IN ?", index.ID, []string{entities.BackfillPending, entities.BackfillRunning}).
+		Count(&running)
+	if running > 0 {
+		res.Msg = fmt.Sprintf("A backfill job for index %d is already running", index.ID)
+		return res
+	}
+
+	job := entities.BackfillJob{
+		IndexID:      index.ID,
+		TargetID:     req.TargetID,
+		Logname:      index.Logname,
+		From:         from.Unix(),
+		To:           to.Unix(),
+		SkipExisting: req.SkipExisting == nil || *req.SkipExisting,
+		Status:       entities.BackfillRunning,
+		TotalWindows: len(fireTimes),
+		StartedAt:    time.Now().Unix(),
+		CreatedBy:    createdBy,
+		Instance:     InstanceID(),
+	}
+	if err := global.Mysql.Create(&job).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to create backfill job: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	backfillManager.Lock()
+	backfillManager.cancels[job.ID] = cancel
+	backfillManager.Unlock()
+
+	go runBackfill(ctx, job, index, fireTimes)
+
+	res.Success = true
+	res.Msg = "Backfill job started"
+	res.Body = job
+	return res
+}
+
+// CancelBackfill 取消執行中的回補工作（目前的區間完成後停止）
+// 工作在其他實例執行時，改寫狀態由該實例在下一個區間前停止
+func CancelBackfill(id int) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	backfillManager.Lock()
+	cancel, ok := backfillManager.cancels[id]
+	backfillManager.Unlock()
+	if ok {
+		cancel()
+	} else {
+		result := global.Mysql.Model(&entities.BackfillJob{}).
+			Where("id = ? AND status IN ?", id, []string{entities.BackfillPending, entities.BackfillRunning}).
+			Updates(map[string]interface{}{"status": entities.BackfillCancelled, "finished_at": time.Now().Unix()})
+		if result.Error != nil {
+			res.Msg = fmt.Sprintf("Failed to cancel backfill job: %s", result.Error.Error())
+			log.Logrecord_no_rotate("ERROR", res.Msg)
+			return res
+		}
+		if result.RowsAffected == 0 {
+			res.Msg = fmt.Sprintf("Backfill job %d is not running", id)
+			return res
+		}
+	}
+
+	res.Success = true
+	res.Msg = "Backfill job cancelling"
+	return res
+}
+
+// GetBackfillJobs 取得回補工作清單
+func GetBackfillJobs(indexID int) models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = []entities.BackfillJob{}
+
+	query := global.Mysql.Model(&entities.BackfillJob{})
+	if indexID > 0 {
+		query = query.Where("index_id = ?", indexID)
+	}
+
+	var jobs []entities.BackfillJob
+	if err := query.Order("id DESC").Limit(200).Find(&jobs).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to get backfill jobs: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Get backfill jobs success"
+	res.Body = jobs
+	return res
+}
+
+// GetBackfillJob 取得單一回補工作（含進度）
+func GetBackfillJob(id int) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	var job entities.BackfillJob
+	if err := global.Mysql.Where("id = ?", id).First(&job).Error; err != nil {
+		res.Msg = fmt.Sprintf("Backfill job not found: %s", err.Error())
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Get backfill job success"
+	res.Body = job
+	return res
+}
+
+// MarkInterruptedBackfillJobs 服務重啟時，將本實例上次未完成的回補工作標記為失敗
+func MarkInterruptedBackfillJobs() {
+	err := global.Mysql.Model(&entities.BackfillJob{}).
+		Where("instance = ? AND status IN ?", InstanceID(), []string{entities.BackfillPending, entities.BackfillRunning}).
+		Updates(map[string]interface{}{
+			"status":      entities.BackfillFailed,
+			"error":       "interrupted by service restart",
+			"finished_at": time.Now().Unix(),
+		}).Error
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to mark interrupted backfill jobs: %s", err.Error()))
+	}
+}
+
+// backfillFireTimes 列出區間內所有排程執行時間
+func backfillFireTimes(spec string, from time.Time, to time.Time) ([]time.Time, error) {
+	var fireTimes []time.Time
+	next := from.Add(-time.Second)
+	for {
+		batch, err := NextFireTimes(spec, next, 500)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range batch {
+			if t.After(to) {
+				return fireTimes, nil
+			}
+			fireTimes = append(fireTimes, t)
+			if len(fireTimes) > maxBackfillWindows {
+				return nil, fmt.Errorf("range contains more than %d scheduled checks, please split it", maxBackfillWindows)
+			}
+		}
+		if len(batch) == 0 {
+			return fireTimes, nil
+		}
+		next = batch[len(batch)-1]
+	}
+}
+
+// runBackfill 依序重播每個區間，並在每個區間完成後更新進度
+func runBackfill(ctx context.Context, job entities.BackfillJob, index entities.Index, fireTimes []time.Time) {
+	defer func() {
+		backfillManager.Lock()
+		delete(backfillManager.cancels, job.ID)
+		backfillManager.Unlock()
+	}()
+
+	finish := func(status string, errMsg string) {
+		err := global.Mysql.Model(&entities.BackfillJob{}).Where("id = ?", job.ID).
+			Updates(map[string]interface{}{
+				"status":            status,
+				"error":             errMsg,
+				"completed_windows": job.CompletedWindows,
+				"skipped_windows":   job.SkippedWindows,
+				"rows_written":      job.RowsWritten,
+				"finished_at":       time.Now().Unix(),
+			}).Error
+		if err != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to update backfill job %d: %s", job.ID, err.Error()))
+		}
+		log.Logrecord_no_rotate("INFO", fmt.Sprintf("Backfill job %d (%s) %s: %d/%d windows, %d rows", job.ID, job.Logname, status, job.CompletedWindows, job.TotalWindows, job.RowsWritten))
+	}
+
+	esClient, err := detectClient(index.ID)
+	if err != nil {
+		finish(entities.BackfillFailed, err.Error())
+		return
+	}
+
+	devices, err := GetDevicesDataByGroupName(index.DeviceGroup)
+	if err != nil || len(devices) == 0 {
+		finish(entities.BackfillFailed, fmt.Sprintf("device group %s has no devices to compare against", index.DeviceGroup))
+		return
+	}
+	var names []string
+	for _, device := range devices {
+		names = append(names, device.Name)
+	}
+	resolver, err := NewDeviceNameResolver(index, devices)
+	if err != nil {
+		finish(entities.BackfillFailed, err.Error())
+		return
+	}
+
+	replay := backfillReplay{
+		job:        job,
+		index:      index,
+		target:     entities.Target{ID: job.TargetID},
+		esClient:   esClient,
+		devices:    names,
+		names:      resolver,
+		thresholds: minDocCounts(index, devices),
+		misses:     map[string]int{},
+		loc:        indexLocation(index),
+	}
+
+	for _, fire := range fireTimes {
+		if ctx.Err() != nil {
+			finish(entities.BackfillCancelled, "")
+			return
+		}
+
+		written, err := replay.window(ctx, fire)
+		if err != nil {
+			log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Backfill job %d skipped window %s: %s", job.ID, fire.Format(time.RFC3339), err.Error()))
+			job.SkippedWindows++
+		}
+		job.CompletedWindows++
+		job.RowsWritten += written
+
+		// 狀態已被其他實例改為取消時停止
+		result := global.Mysql.Model(&entities.BackfillJob{}).
+			Where("id = ? AND status = ?", job.ID, entities.BackfillRunning).
+			Updates(map[string]interface{}{
+				"completed_windows": job.CompletedWindows,
+				"skipped_windows":   job.SkippedWindows,
+				"rows_written":      job.RowsWritten,
+			})
+		if result.Error == nil && result.RowsAffected == 0 {
+			finish(entities.BackfillCancelled, "")
+			return
+		}
+	}
+
+	finish(entities.BackfillCompleted, "")
+}
+
+// backfillReplay 單一回補工作的重播狀態（連續缺失次數跨區間累計）
+type backfillReplay struct {
+	job        entities.BackfillJob
+	index      entities.Index
+	target     entities.Target
+	esClient   *elasticsearch.Client
+	devices    []string
+	names      *DeviceNameResolver
+	thresholds func(name string) int64
+	misses     map[string]int
+	loc        *time.Location
+}
+
+// window 重播單一排程時間點的檢查並寫入 device_metrics（不通知、不更新事件與設備狀態）
+func (r *backfillReplay) window(ctx context.Context, fire time.Time) (int64, error) {
+	if r.job.SkipExisting {
+		var exists bool
+		err := global.TimescaleDB.QueryRow(
+			`SELECT EXISTS (SELECT 1 FROM device_metrics WHERE index_id = $1 AND time >= $2 AND time < $3)`,
+			r.index.ID, fire, fire.Add(time.Minute),
+		).Scan(&exists)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			return 0, fmt.Errorf("history already exists")
+		}
+	}
+
+	now := fire.In(r.loc)
+	from, to := detectWindow(now, r.index)
+	params, err := NewDeviceSearchParams(r.index, from.Format(esTimeLayout), to.Format(esTimeLayout))
+	if err != nil {
+		return 0, err
+	}
+	result, err := SearchRequestWithClient(ctx, r.esClient, params)
+	if err != nil && !result.Partial {
+		return 0, err
+	}
+
+	result, _ = r.names.Apply(result)
+	_, removed, intersection := ListCompare(r.devices, result.Keys())
+	counts := result.DocCounts()
+	maint := NewMaintenanceMatcher(r.target, r.index, fire)
+	missThreshold := r.index.MissThreshold
+	if missThreshold < 1 {
+		missThreshold = 1
+	}
+
+	base := entities.History{
+		Logname:     r.index.Logname,
+		DeviceGroup: r.index.DeviceGroup,
+		TargetID:    r.target.ID,
+		IndexID:     r.index.ID,
+		Date:        now.Format("2006-01-02"),
+		Time:        now.Format("15:04"),
+		DateTime:    now.Format("2006-01-02 15:04:05"),
+		Timestamp:   fire.Unix(),
+		Period:      r.index.Period,
+		Unit:        r.index.Unit,
+		Backfilled:  true,
+	}
+
+	var rows []entities.History
+	for _, device := range intersection {
+		r.misses[device] = 0
+		h := base
+		h.Name = device
+		h.Status = "online"
+		h.Lost = "false"
+		h.DataCount = counts[device]
+		if limit := r.thresholds(device); limit > 0 && counts[device] < limit {
+			h.Status = "warning"
+			h.ErrorMsg = fmt.Sprintf("Document count %d below threshold %d", counts[device], limit)
+			h.ErrorCode = "LOW_VOLUME"
+		}
+		rows = append(rows, h)
+	}
+
+	for _, device := range removed {
+		h := base
+		h.Name = device
+		h.Lost = "true"
+		switch {
+		case result.Partial:
+			h.Status = "warning"
+			h.ErrorMsg = fmt.Sprintf("Search result is partial (failed shards %d/%d, timed out %v)", result.Shards.Failed, result.Shards.Total, result.TimedOut)
+			h.ErrorCode = "PARTIAL_RESULT"
+		case r.misses[device]+1 < missThreshold:
+			r.misses[device]++
+			h.Status = "warning"
+			h.LostNum = r.misses[device]
+			h.ErrorMsg = fmt.Sprintf("Device not found in logs (%d/%d consecutive misses)", r.misses[device], missThreshold)
+			h.ErrorCode = "DEVICE_MISSED"
+		default:
+			r.misses[device]++
+			h.Status = "offline"
+			h.LostNum = r.misses[device]
+			h.ErrorMsg = "Device not found in logs"
+			h.ErrorCode = "DEVICE_OFFLINE"
+		}
+		rows = append(rows, h)
+	}
+
+	for i := range rows {
+		if maint.Covers(rows[i].Name) {
+			rows[i].Status = StatusMaintenance
+		}
+	}
+
+	return writeBackfillMetrics(rows)
+}
+
+// writeBackfillMetrics 將回補結果寫入 device_metrics（backfilled = true）
+func writeBackfillMetrics(rows []entities.History) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	tx, err := global.TimescaleDB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO device_metrics
+		(time, device_id, device_group, logname, status, lost, lost_num,
+		 date, hour_time, date_time, timestamp_unix, period, unit,
+		 target_id, index_id, response_time, data_count, error_msg, error_code, metadata, backfilled)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, h := range rows {
+		metadata := h.Metadata
+		if metadata == "" {
+			metadata = "{}"
+		}
+		_, err := stmt.Exec(
+			time.Unix(h.Timestamp, 0), h.Name, h.DeviceGroup, h.Logname,
+			h.Status, h.Lost == "true", h.LostNum,
+			h.Date, h.Time, h.DateTime, h.Timestamp, h.Period, h.Unit,
+			h.TargetID, h.IndexID, h.ResponseTime, h.DataCount,
+			h.ErrorMsg, h.ErrorCode, metadata, h.Backfilled,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(rows)), nil
+}
@@ -1,69 +1,302 @@
 package services
 
 import (
-	// "context"
+	"context"
+	"errors"
 	"fmt"
 	"log-detect/entities"
 	"log-detect/global"
 	"log-detect/log"
 	"log-detect/models"
+	"sync"
 	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
-func Detect(execute_time time.Time, indexID int, index string, field string, period string, unit int, receiver []string, subject string, logname string, device_group string) {
-	timenow := execute_time.Format("2006-01-02 15:04:05")
-	// var cronjob string
-	var time3_str string
-	date_time := execute_time.Format("2006-01-02")
-	hour_time := execute_time.Format("15:04")
+// esTimeLayout 送往 ES 的時間格式（含時區位移）
+const esTimeLayout = "2006-01-02T15:04:05.000Z07:00"
 
-	if period == "minutes" {
-		time3 := execute_time.Add(time.Minute * -time.Duration(unit))
-		time3_str = time3.Format("2006-01-02T15:04") + ":00.000+08:00"
-	} else if period == "hours" {
-		time3 := execute_time.Add(time.Hour * -time.Duration(unit))
-		time3_str = time3.Format("2006-01-02T15:04") + ":00.000+08:00"
+// StatusError ES 查詢失敗、無法判斷設備狀態時的檢查結果狀態（不計入可用率）
+const StatusError = "error"
+
+// 未設定時的預設逾時
+const (
+	defaultQueryTimeout = 30 * time.Second
+	defaultRunTimeout   = 5 * time.Minute
+)
+
+// QueryTimeout 取得 index 的 ES 查詢逾時：index 設定優先，其次為 ES 連線設定，最後使用全域設定
+func QueryTimeout(idx entities.Index) time.Duration {
+	if idx.QueryTimeout > 0 {
+		return time.Duration(idx.QueryTimeout) * time.Second
 	}
+	if idx.ESConnectionID != nil {
+		var conn entities.ESConnection
+		err := global.Mysql.Select("query_timeout").Where("id = ?", *idx.ESConnectionID).First(&conn).Error
+		if err == nil && conn.QueryTimeout > 0 {
+			return time.Duration(conn.QueryTimeout) * time.Second
+		}
+	}
+	return parseDurationOr(global.EnvConfig.Detect.QueryTimeout, defaultQueryTimeout)
+}
 
-	// 取得該 Index 對應的 ES 客戶端
+// detectRunTimeout 單次偵測（查詢、比對、狀態更新）的執行期限
+func detectRunTimeout() time.Duration {
+	return parseDurationOr(global.EnvConfig.Detect.RunTimeout, defaultRunTimeout)
+}
+
+// activeDetects 排入執行器或執行中的偵測（target:index -> 觸發時間），前一次尚未結束時略過本次；
+// 排程與手動觸發共用，同一次略過只記錄一次
+var activeDetects sync.Map
+
+// detectKey activeDetects 的鍵
+func detectKey(targetID, indexID int) string {
+	return fmt.Sprintf("%d:%d", targetID, indexID)
+}
+
+// claimDetect 標記 target / index 開始執行；前一次尚未結束時回傳其觸發時間與 false
+func claimDetect(key string, t time.Time) (time.Time, bool) {
+	if previous, loaded := activeDetects.LoadOrStore(key, t); loaded {
+		return previous.(time.Time), false
+	}
+	return t, true
+}
+
+// releaseDetect 解除 claimDetect 的標記
+func releaseDetect(key string) {
+	activeDetects.Delete(key)
+}
+
+// indexLocation 取得 index 設定的時區，設定錯誤時退回預設時區
+func indexLocation(idx entities.Index) *time.Location {
+	loc, err := idx.Location()
+	if err != nil {
+		log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Invalid timezone %q for %s, using %s", idx.Timezone, idx.Logname, entities.DefaultTimezone))
+		loc, err = time.LoadLocation(entities.DefaultTimezone)
+		if err != nil {
+			loc = time.Local
+		}
+	}
+	return loc
+}
+
+// detectWindow 依週期與寬限時間計算本次檢查區間，整個區間往前平移 GraceDelay 分鐘，起始時間取整到分鐘
+// cron 排程的區間為上一次執行到本次執行之間
+func detectWindow(now time.Time, idx entities.Index) (time.Time, time.Time) {
+	to := now.Add(-time.Duration(idx.GraceDelay) * time.Minute)
+	from := to
+	switch idx.Period {
+	case PeriodMinutes:
+		from = to.Add(time.Minute * -time.Duration(idx.Unit))
+	case PeriodHours:
+		from = to.Add(time.Hour * -time.Duration(idx.Unit))
+	case PeriodDays:
+		from = to.AddDate(0, 0, -idx.Unit)
+	case PeriodCron:
+		from = to.Add(-time.Hour)
+		if spec, err := IndexSchedule(idx); err == nil {
+			current := now.Truncate(time.Minute)
+			if last := LastFireTime(spec, current); !last.IsZero() {
+				from = to.Add(-current.Sub(last))
+			}
+		}
+	}
+	return from.Truncate(time.Minute), to
+}
+
+// detectTimeRange 回傳 index 時區的執行時間與查詢區間（有自訂區間時使用自訂區間）
+func detectTimeRange(execute_time time.Time, idx entities.Index, opts DetectOptions) (time.Time, time.Time, time.Time) {
+	now := execute_time.In(indexLocation(idx))
+	from, to := detectWindow(now, idx)
+	if opts.From != nil && opts.To != nil {
+		from, to = opts.From.In(now.Location()), opts.To.In(now.Location())
+	}
+	return now, from, to
+}
+
+// detectClient 取得 index 對應的 ES 客戶端，取得失敗時退回預設客戶端
+func detectClient(indexID int) (*elasticsearch.Client, error) {
 	manager := GetESConnectionManager()
 	esClient, err := manager.GetClientForIndex(indexID)
-	if err != nil {
-		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to get ES client for index %d: %s", indexID, err.Error()))
-		log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Falling back to default ES client for index %d", indexID))
-		// Fallback 到預設客戶端
-		esClient = manager.GetDefaultClient()
-		if esClient == nil {
-			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Default ES client is nil, cannot execute detect for index %d", indexID))
-			return
+	if err == nil {
+		return esClient, nil
+	}
+
+	log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to get ES client for index %d: %s", indexID, err.Error()))
+	log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Falling back to default ES client for index %d", indexID))
+	// Fallback 到預設客戶端
+	esClient = manager.GetDefaultClient()
+	if esClient == nil {
+		return nil, fmt.Errorf("default ES client is nil")
+	}
+	return esClient, nil
+}
+
+// DetectOptions 單次偵測的執行選項
+type DetectOptions struct {
+	Trigger string     // schedule, manual
+	From    *time.Time // 自訂查詢區間，未指定時依排程週期計算
+	To      *time.Time
+	DryRun  bool // 只查詢與比對：不建立設備、不寫歷史、不更新狀態與事件、不發通知
+
+	prefetch *prefetchedPage // 批次 _msearch 預先取得的第一頁
+	claimed  bool            // 執行器排入時已 claimDetect，由執行器在結束後解除
+}
+
+// DetectReport 單次偵測的結果
+type DetectReport struct {
+	RunID         int               `json:"run_id"`
+	Logname       string            `json:"logname"`
+	DryRun        bool              `json:"dry_run"`
+	WindowFrom    string            `json:"window_from"`
+	WindowTo      string            `json:"window_to"`
+	Partial       bool              `json:"partial"`
+	Buckets       []TermsBucket     `json:"buckets"`
+	Renamed       map[string]string `json:"renamed"`      // ES 原始名稱 -> 設備名稱（別名或正規化）
+	Added         []string          `json:"added"`        // ES 有、資產清單沒有
+	Discovered    []string          `json:"discovered"`   // 本次第一次出現、加入待審核佇列的設備
+	Removed       []string          `json:"removed"`      // 資產清單有、ES 沒有
+	Inactive      []string          `json:"inactive"`     // ES 沒有但已除役或預期缺席，不列入缺失
+	Intersection  []string          `json:"intersection"` // 兩邊都有
+	Online        []string          `json:"online"`
+	Pending       []string          `json:"pending"`
+	Offline       []string          `json:"offline"`
+	Flapping      []string          `json:"flapping"`
+	LowVolume     []string          `json:"low_volume"`
+	Anomalies     []VolumeAnomaly   `json:"anomalies"`
+	Unverified    []string          `json:"unverified"`
+	Maintenance   []string          `json:"maintenance"`
+	Notifications []Notification    `json:"notifications"` // 已發送（或 dry-run 時將會發送）的通知
+	Digested      []string          `json:"digested"`      // 低重要性設備的事件，併入下一次摘要通知
+	Error         string            `json:"error,omitempty"`
+}
+
+// Detect 由排程觸發，檢查單一 index 在本次週期內的設備是否都有日誌
+func Detect(ctx context.Context, execute_time time.Time, target entities.Target, idx entities.Index) {
+	RunDetect(ctx, execute_time, target, idx, DetectOptions{Trigger: "schedule"})
+}
+
+// RunDetect 檢查單一 index 在查詢區間內的設備是否都有日誌，並通知 target 設定的收件人與通道
+// ES 查詢受 ctx 與 detect.run_timeout 限制；同一 target / index 的前一次執行尚未結束時略過（dry-run 除外）
+func RunDetect(ctx context.Context, execute_time time.Time, target entities.Target, idx entities.Index, opts DetectOptions) DetectReport {
+	indexID := idx.ID
+	index := idx.Pattern
+	period := idx.Period
+	unit := idx.Unit
+	subject := target.Subject
+	logname := idx.Logname
+	device_group := idx.DeviceGroup
+
+	// 紀錄本次執行（開始時即寫入，確認排程確實有執行）
+	trigger := opts.Trigger
+	if opts.DryRun {
+		trigger = "dry_run"
+	}
+	run := StartDetectRun(target, idx, trigger, execute_time)
+	defer run.Finish()
+
+	report := DetectReport{RunID: run.ID(), Logname: logname, DryRun: opts.DryRun}
+	fail := func(err error) DetectReport {
+		run.Fail(err)
+		report.Error = err.Error()
+		return report
+	}
+
+	if !opts.DryRun && !opts.claimed {
+		key := detectKey(target.ID, indexID)
+		if previous, ok := claimDetect(key, execute_time); !ok {
+			msg := fmt.Sprintf("previous run at %s is still queued or running", previous.Format("2006-01-02 15:04:05"))
+			log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Skipping detect for %s (target %d): %s", logname, target.ID, msg))
+			run.Skip(msg)
+			report.Error = msg
+			return report
 		}
+		defer releaseDetect(key)
 	}
 
-	var result_list []string
-	result := SearchRequestWithClient(esClient, index, field, time3_str, timenow)
-	// fmt.Println("資料搜尋結果:",result)
+	runCtx, cancel := context.WithTimeout(ctx, detectRunTimeout())
+	defer cancel()
 
-	for i := range result.Aggregations.Num2.Buckets {
-		// fmt.Println("host", result.Aggregations.Num2.Buckets[i].Key)
-		// fmt.Println("doc_count", result.Aggregations.Num2.Buckets[i].DocCount)
-		result_list = append(result_list, result.Aggregations.Num2.Buckets[i].Key)
+	// 所有時間字串都以 index 設定的時區產生
+	now, time_from, time_to := detectTimeRange(execute_time, idx, opts)
+	timenow := now.Format("2006-01-02 15:04:05")
+	time3_str := time_from.Format(esTimeLayout)
+	date_time := now.Format("2006-01-02")
+	hour_time := now.Format("15:04")
+
+	// 紀錄檢查結果到歷史記錄中
+	base := entities.History{
+		Logname:     logname,
+		DeviceGroup: device_group,
+		TargetID:    target.ID,
+		IndexID:     indexID,
+		Date:        date_time,
+		Time:        hour_time,
+		DateTime:    timenow,
+		Timestamp:   execute_time.Unix(),
+		Period:      period,
+		Unit:        unit,
+	}
+
+	// 取得該 Index 對應的 ES 客戶端
+	report.WindowFrom, report.WindowTo = time3_str, time_to.Format(esTimeLayout)
+	run.SetWindow(report.WindowFrom, report.WindowTo)
+	esClient, err := detectClient(indexID)
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Cannot execute detect for index %d: %s", indexID, err.Error()))
+		return fail(err)
+	}
+
+	params, err := NewDeviceSearchParams(idx, report.WindowFrom, report.WindowTo)
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Invalid filter for %s: %s", logname, err.Error()))
+		return fail(err)
+	}
+	result, err := searchDevices(runCtx, esClient, params, opts.prefetch)
+	run.SetSearch(result)
+	report.Buckets = result.Buckets
+	report.Partial = result.Partial
+	if err != nil && !result.Partial {
+		// 查詢本身失敗時不能把所有設備視為離線，改記為 error，狀態與事件維持不變
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Search failed for %s (%s): %s", logname, index, err.Error()))
+		if !opts.DryRun {
+			recordQueryError(ctx, base, target, idx, err)
+		}
+		return fail(err)
+	}
+	if result.Partial {
+		log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Partial search result for %s (%s): timed_out=%v, failed shards=%d/%d, pages=%d",
+			logname, index, result.TimedOut, result.Shards.Failed, result.Shards.Total, result.Pages))
 	}
 
 	fmt.Println("執行時間:", timenow)
-	fmt.Println("檢查起始時間:", time3_str)
+	fmt.Println("檢查區間:", time3_str, "~", params.To)
 
+	// 預期缺席時間已過的設備恢復檢查
+	if !opts.DryRun {
+		reactivateDevices(device_group, execute_time)
+	}
 	deviceslist, err := GetDevicesDataByGroupName(device_group)
 	if err != nil {
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("get devices data error: %s", err.Error()))
 	}
+
+	// ES 的名稱依別名與正規化規則換成設備名稱後再比對
+	resolver, err := NewDeviceNameResolver(idx, deviceslist)
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Invalid name normalization for %s: %s", logname, err.Error()))
+		return fail(err)
+	}
+	result, report.Renamed = resolver.Apply(result)
+	result_list := result.Keys()
+	doc_counts := result.DocCounts()
 	var device_list []string
 	var origin_list []entities.Device
 	var new_list []entities.Device
 
-	// 資產管理清單未建立，自動把從 ES 撈出的設備加入群組中
-	if len(deviceslist) == 0 {
+	// 資產管理清單未建立，自動把從 ES 撈出的設備加入群組中（未啟用自動加入時全部進入待審核佇列）
+	if len(deviceslist) == 0 && idx.AutoAddDevices {
 		// fmt.Println("no data")
 		device_list = result_list
 
@@ -76,7 +309,9 @@ func Detect(execute_time time.Time, indexID int, index string, field string, per
 			origin_list = append(origin_list, newDevice)
 		}
 
-		CreateDevice(origin_list)
+		if !opts.DryRun {
+			CreateDevice(origin_list)
+		}
 
 	} else {
 		// db 中的 device list
@@ -89,8 +324,24 @@ func Detect(execute_time time.Time, indexID int, index string, field string, per
 	added, removed, intersection := ListCompare(device_list, result_list)
 	fmt.Println("新增的設備:", added)
 
-	// 將偵測到的新設備寫入 devices table 中
-	if len(added) != 0 {
+	// 除役與預期缺席中的設備不列入缺失清單
+	inactive := inactiveDevices(deviceslist, execute_time)
+	if len(inactive) > 0 {
+		var checked []string
+		for _, device := range removed {
+			if inactive[device] {
+				report.Inactive = append(report.Inactive, device)
+			} else {
+				checked = append(checked, device)
+			}
+		}
+		removed = checked
+	}
+	run.SetCompare(len(device_list), len(added), len(removed))
+	report.Added, report.Removed, report.Intersection = added, removed, intersection
+
+	// 將偵測到的新設備寫入 devices table 中，未啟用自動加入時改為待審核
+	if len(added) != 0 && idx.AutoAddDevices && !opts.DryRun {
 		for _, device := range added {
 			newDevice := entities.Device{
 				Common:      models.Common{},
@@ -101,13 +352,370 @@ func Detect(execute_time time.Time, indexID int, index string, field string, per
 		}
 		CreateDevice(new_list)
 	}
+	if len(added) != 0 && !idx.AutoAddDevices {
+		discovery := NewDiscoveredDeviceService()
+		discovery.DryRun = opts.DryRun
+		report.Discovered = discovery.Queue(idx, added, execute_time)
+	}
+
+	if !opts.DryRun {
+		if err := removeDuplicateDevices(); err != nil {
+			return fail(err)
+		}
+	}
+
+	fmt.Println("遺失的設備: ", removed)
+
+	// 結果不完整時（逾時 / shard 失敗），缺失的設備無法確認是否離線
+	var unverified []string
+	if result.Partial {
+		unverified = removed
+		removed = nil
+	}
 
+	// 依連續缺失門檻與抖動設定分類設備
+	deviceStates := NewDeviceStateService()
+	deviceStates.DryRun = opts.DryRun
+	eval := deviceStates.Evaluate(target, idx, intersection, removed, execute_time)
+
+	// 有資料但筆數低於門檻的設備標記為 warning
+	thresholds := minDocCounts(idx, deviceslist)
+	online, low_volume := splitLowVolume(eval.Online, doc_counts, thresholds)
+
+	// 與歷史基準比較日誌量，顯著偏離的設備標記為 warning
+	anomalies := NewVolumeAnomalyService().Detect(idx, online, doc_counts, execute_time, date_time, hour_time)
+	if len(anomalies) > 0 {
+		anomalous := map[string]bool{}
+		for _, anomaly := range anomalies {
+			anomalous[anomaly.Device] = true
+		}
+		var normal []string
+		for _, device := range online {
+			if !anomalous[device] {
+				normal = append(normal, device)
+			}
+		}
+		online = normal
+	}
+
+	dest := deviceNotificationDestination(target, idx)
+
+	run.SetCounts(len(online), len(anomalies)+len(low_volume)+len(eval.Pending)+len(eval.Flapping)+len(unverified), len(eval.Offline))
+	report.Online, report.Pending, report.Offline, report.Flapping = online, eval.Pending, eval.Offline, eval.Flapping
+	report.LowVolume, report.Anomalies, report.Unverified = low_volume, anomalies, unverified
+
+	// 維護時段內的設備照常記錄歷史（狀態為 maintenance），但不開啟事件、不通知
+	maint := NewMaintenanceMatcher(target, idx, execute_time)
+	offline, _ := maint.Filter(eval.Offline)
+
+	// 只有新開啟的離線事件才通知，持續離線的設備不重複寄送
+	incidents := NewDeviceIncidentService()
+	incidents.DryRun = opts.DryRun
+	notify := func(n Notification) {
+		report.Notifications = append(report.Notifications, n)
+		if !opts.DryRun {
+			run.AddNotification(DispatchNotification(dest, n))
+		}
+	}
+	// 設備事件依重要性分級：critical 另發緊急通知、low 併入摘要；names 與 n.Items 一一對應
+	deviceInfo := devicesByName(deviceslist)
+	notifyDevices := func(n Notification, names []string) {
+		immediate, digest := RouteDeviceNotification(n, names, deviceInfo)
+		for _, part := range immediate {
+			notify(part)
+		}
+		if len(digest.Items) > 0 {
+			report.Digested = append(report.Digested, digest.Items...)
+			if !opts.DryRun {
+				QueueNotificationDigest(dest, digest)
+			}
+		}
+	}
+	// 未登錄的設備第一次出現時通知，等待核准後才列入監控
+	if len(report.Discovered) > 0 {
+		notify(Notification{
+			Subject:  fmt.Sprintf("[新設備待審核] %s", subject),
+			Logname:  logname,
+			Event:    "device_discovered",
+			Severity: "low",
+			Summary:  fmt.Sprintf("%s 日誌，發現以下未登錄的主機（群組 %s），請確認是否加入監控：", logname, device_group),
+			Items:    report.Discovered,
+			Time:     execute_time,
+		})
+	}
+
+	// 除役或預期缺席的設備結束未結事件，不發送恢復通知
+	for _, incidentType := range []string{IncidentTypeOffline, IncidentTypeFlapping, IncidentTypeLowVolume, IncidentTypeVolumeAnomaly} {
+		incidents.Resolve(target, idx, incidentType, report.Inactive, execute_time)
+	}
+
+	opened := incidents.Open(target, idx, IncidentTypeOffline, "high", offline, execute_time)
+	if len(opened) > 0 {
+		notifyDevices(Notification{
+			Subject:  subject,
+			Logname:  logname,
+			Event:    "device_offline",
+			Severity: "high",
+			Summary:  fmt.Sprintf("%s 日誌，失聯主機如下：", logname),
+			Items:    opened,
+			Time:     execute_time,
+		}, opened)
+	}
+
+	// 重新出現在搜尋結果中的設備，結束離線事件並通知恢復
+	recovered := incidents.Resolve(target, idx, IncidentTypeOffline, intersection, execute_time)
+	var recoveredItems, recoveredNames []string
+	for _, device := range recovered {
+		if !maint.Covers(device.Name) {
+			recoveredItems = append(recoveredItems, fmt.Sprintf("%s（離線 %s）", device.Name, FormatOutageDuration(device.Duration)))
+			recoveredNames = append(recoveredNames, device.Name)
+		}
+	}
+	if len(recoveredItems) > 0 {
+		notifyDevices(Notification{
+			Subject:  fmt.Sprintf("[恢復] %s", subject),
+			Logname:  logname,
+			Event:    "device_recovered",
+			Severity: "low",
+			Summary:  fmt.Sprintf("%s 日誌，以下主機已恢復：", logname),
+			Items:    recoveredItems,
+			Time:     execute_time,
+		}, recoveredNames)
+	}
+
+	// 抖動中的設備開啟 warning 事件，停止抖動後自動結束
+	flappingNotify, _ := maint.Filter(eval.Flapping)
+	flapping := incidents.Open(target, idx, IncidentTypeFlapping, "medium", flappingNotify, execute_time)
+	if len(flapping) > 0 {
+		notifyDevices(Notification{
+			Subject:  fmt.Sprintf("[狀態不穩定] %s", subject),
+			Logname:  logname,
+			Event:    "device_flapping",
+			Severity: "medium",
+			Summary:  fmt.Sprintf("%s 日誌，以下主機在 %d 分鐘內頻繁上線/離線：", logname, idx.FlapWindow),
+			Items:    flapping,
+			Time:     execute_time,
+		}, flapping)
+	}
+	var stable []string
+	stable = append(stable, eval.Online...)
+	stable = append(stable, eval.Pending...)
+	stable = append(stable, eval.Offline...)
+	incidents.Resolve(target, idx, IncidentTypeFlapping, stable, execute_time)
+
+	// 日誌量過低的設備開啟 warning 事件，恢復正常量或轉為其他狀態時結束
+	lowNotify, _ := maint.Filter(low_volume)
+	lowOpened := incidents.Open(target, idx, IncidentTypeLowVolume, "medium", lowNotify, execute_time)
+	if len(lowOpened) > 0 {
+		var items []string
+		for _, device := range lowOpened {
+			items = append(items, fmt.Sprintf("%s（%d 筆，門檻 %d）", device, doc_counts[device], thresholds(device)))
+		}
+		notifyDevices(Notification{
+			Subject:  fmt.Sprintf("[日誌量過低] %s", subject),
+			Logname:  logname,
+			Event:    "device_low_volume",
+			Severity: "medium",
+			Summary:  fmt.Sprintf("%s 日誌，以下主機日誌量低於門檻：", logname),
+			Items:    items,
+			Time:     execute_time,
+		}, lowOpened)
+	}
+	var normal []string
+	normal = append(normal, online...)
+	normal = append(normal, eval.Pending...)
+	normal = append(normal, eval.Offline...)
+	normal = append(normal, eval.Flapping...)
+	incidents.Resolve(target, idx, IncidentTypeLowVolume, normal, execute_time)
+
+	// 日誌量異常（與歷史基準比較）的設備開啟 warning 事件
+	var anomalyDevices []string
+	anomalyMessages := map[string]string{}
+	for _, anomaly := range anomalies {
+		if maint.Covers(anomaly.Device) {
+			continue
+		}
+		anomalyDevices = append(anomalyDevices, anomaly.Device)
+		anomalyMessages[anomaly.Device] = anomaly.Summary()
+	}
+	anomalyOpened := incidents.OpenWithMessages(target, idx, IncidentTypeVolumeAnomaly, "medium", anomalyDevices, anomalyMessages, execute_time)
+	if len(anomalyOpened) > 0 {
+		var items []string
+		for _, device := range anomalyOpened {
+			items = append(items, anomalyMessages[device])
+		}
+		notifyDevices(Notification{
+			Subject:  fmt.Sprintf("[日誌量異常] %s", subject),
+			Logname:  logname,
+			Event:    "device_volume_anomaly",
+			Severity: "medium",
+			Summary:  fmt.Sprintf("%s 日誌，以下主機日誌量與歷史同時段差異過大：", logname),
+			Items:    items,
+			Time:     execute_time,
+		}, anomalyOpened)
+	}
+	normal = append(normal, low_volume...)
+	incidents.Resolve(target, idx, IncidentTypeVolumeAnomaly, normal, execute_time)
+
+	// 維護中的設備改記為 maintenance，原本的 ErrorCode / ErrorMsg 保留供查詢
+	record := func(historyData entities.History) {
+		if maint.Covers(historyData.Name) {
+			historyData.Status = StatusMaintenance
+			report.Maintenance = append(report.Maintenance, historyData.Name)
+		}
+		if !opts.DryRun {
+			writeDeviceHistory(ctx, historyData)
+		}
+	}
+
+	for _, device := range online {
+		historyData := base
+		historyData.Name = device
+		historyData.Status = "online"
+		historyData.Lost = "false"
+		historyData.ResponseTime = 100 // 模擬響應時間
+		historyData.DataCount = doc_counts[device]
+		record(historyData)
+	}
+
+	for _, anomaly := range anomalies {
+		historyData := base
+		historyData.Name = anomaly.Device
+		historyData.Status = "warning"
+		historyData.Lost = "false"
+		historyData.DataCount = anomaly.Actual
+		historyData.ErrorMsg = fmt.Sprintf("Document count %d deviates from baseline %.0f (z=%.2f)", anomaly.Actual, anomaly.Expected, anomaly.ZScore)
+		historyData.ErrorCode = "VOLUME_ANOMALY"
+		historyData.Metadata = anomaly.Metadata()
+		record(historyData)
+	}
+
+	for _, device := range low_volume {
+		historyData := base
+		historyData.Name = device
+		historyData.Status = "warning"
+		historyData.Lost = "false"
+		historyData.DataCount = doc_counts[device]
+		historyData.ErrorMsg = fmt.Sprintf("Document count %d below threshold %d", doc_counts[device], thresholds(device))
+		historyData.ErrorCode = "LOW_VOLUME"
+		record(historyData)
+	}
+
+	// 缺失但尚未達門檻：記錄為 warning，不通知
+	for _, device := range eval.Pending {
+		historyData := base
+		historyData.Name = device
+		historyData.Status = "warning"
+		historyData.Lost = "true"
+		historyData.LostNum = eval.Misses[device]
+		historyData.ErrorMsg = fmt.Sprintf("Device not found in logs (%d/%d consecutive misses)", eval.Misses[device], idx.MissThreshold)
+		historyData.ErrorCode = "DEVICE_MISSED"
+		record(historyData)
+	}
+
+	for _, device := range eval.Flapping {
+		historyData := base
+		historyData.Name = device
+		historyData.Status = "warning"
+		historyData.Lost = "false"
+		historyData.DataCount = doc_counts[device]
+		if misses, ok := eval.Misses[device]; ok {
+			historyData.Lost = "true"
+			historyData.LostNum = misses
+		}
+		historyData.ErrorMsg = fmt.Sprintf("Device status changed at least %d times in %d minutes", idx.FlapThreshold, idx.FlapWindow)
+		historyData.ErrorCode = "DEVICE_FLAPPING"
+		record(historyData)
+	}
+
+	for _, device := range unverified {
+		historyData := base
+		historyData.Name = device
+		historyData.Status = "warning"
+		historyData.Lost = "true"
+		historyData.ErrorMsg = fmt.Sprintf("Search result is partial (failed shards %d/%d, timed out %v)", result.Shards.Failed, result.Shards.Total, result.TimedOut)
+		historyData.ErrorCode = "PARTIAL_RESULT"
+		record(historyData)
+	}
+
+	// 紀錄缺失設備到 history table 中
+	log.Logrecord_no_rotate("INFO", fmt.Sprintf("Processing %d offline devices", len(eval.Offline)))
+	for _, device := range eval.Offline {
+		historyData := base
+		historyData.Name = device
+		historyData.Status = "offline"
+		historyData.Lost = "true"
+		historyData.LostNum = eval.Misses[device]
+		historyData.ErrorMsg = "Device not found in logs"
+		historyData.ErrorCode = "DEVICE_OFFLINE"
+		record(historyData)
+	}
+
+	// 缺失的設備在延遲後以相同區間重新查詢，資料只是晚到時撤銷告警
+	var missing []string
+	missing = append(missing, eval.Pending...)
+	missing = append(missing, eval.Offline...)
+	if !opts.DryRun {
+		scheduleRecheck(recheckTask{
+			target:    target,
+			index:     idx,
+			params:    params,
+			devices:   missing,
+			names:     resolver,
+			checkTime: execute_time,
+		})
+	}
+
+	return report
+
+}
+
+// deviceNotificationDestination 設備通知的收件對象：target 收件人加上 index 設備群組（含上層群組）的預設收件人
+func deviceNotificationDestination(target entities.Target, idx entities.Index) NotificationDestination {
+	return NotificationDestination{
+		Source:     "device",
+		SourceID:   target.ID,
+		Receivers:  appendUnique(append([]string{}, target.To...), DeviceGroupReceivers(idx.DeviceGroup)...),
+		ChannelIDs: target.ChannelIDs,
+	}
+}
+
+// minDocCounts 回傳設備的最低日誌筆數門檻（設備設定優先，否則使用 index 設定）
+func minDocCounts(idx entities.Index, devices []entities.Device) func(name string) int64 {
+	overrides := map[string]int64{}
+	for _, device := range devices {
+		if device.MinDocCount != nil {
+			overrides[device.Name] = *device.MinDocCount
+		}
+	}
+	return func(name string) int64 {
+		if threshold, ok := overrides[name]; ok {
+			return threshold
+		}
+		return idx.MinDocCount
+	}
+}
+
+// splitLowVolume 將日誌筆數低於門檻的設備分出來
+func splitLowVolume(devices []string, docCounts map[string]int64, threshold func(name string) int64) (normal []string, low []string) {
+	for _, device := range devices {
+		if limit := threshold(device); limit > 0 && docCounts[device] < limit {
+			low = append(low, device)
+			continue
+		}
+		normal = append(normal, device)
+	}
+	return normal, low
+}
+
+// removeDuplicateDevices 刪除同群組中名稱重複的設備
+func removeDuplicateDevices() error {
 	// 1. 找出要刪除的重複資料的 id
 	rows, err := global.Mysql.Raw("SELECT MIN(id) as id FROM devices GROUP BY name, device_group HAVING COUNT(*) > 1").Rows()
 	if err != nil {
 		// 處理錯誤
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("error querying duplicate devices: %s", err.Error()))
-		return
+		return err
 	}
 	defer rows.Close()
 
@@ -123,98 +731,42 @@ func Detect(execute_time time.Time, indexID int, index string, field string, per
 	if result_to.Error != nil {
 		// 處理錯誤
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("error deleting duplicate devices: %s", result_to.Error.Error()))
-		return
+		return result_to.Error
 	}
+	return nil
+}
 
-	fmt.Println("遺失的設備: ", removed)
-	cc := []string{""}
-	bcc := []string{""}
-	if removed != nil {
-		// SendEmail(receiver,subject,logname,removed)
-		Mail4(receiver, cc, bcc, subject, logname, removed)
-		mailHistory := entities.MailHistory{
-			Date:    date_time,
-			Time:    hour_time,
-			Logname: logname,
-			Sended:  true,
-		}
-		CreateMailHistory(mailHistory)
+// recordQueryError 查詢失敗時，將資產清單中的設備記錄為 error（逾時為 QUERY_TIMEOUT，其餘為 QUERY_FAILED）
+func recordQueryError(ctx context.Context, base entities.History, target entities.Target, idx entities.Index, queryErr error) {
+	devices, err := GetDevicesDataByGroupName(idx.DeviceGroup)
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("get devices data error: %s", err.Error()))
+		return
 	}
-	// 紀錄檢查結果到歷史記錄中
-	for _, device := range intersection {
-		historyData := entities.History{
-			// 基本信息
-			Logname:     logname,
-			DeviceGroup: device_group,
-			Name:        device,
-
-			// 檢查結果
-			Status:  "online",
-			Lost:    "false",
-			LostNum: 0,
-
-			// 時間信息
-			Date:      date_time,
-			Time:      hour_time,
-			DateTime:  timenow,
-			Timestamp: execute_time.Unix(),
 
-			// 檢查配置
-			Period: period,
-			Unit:   unit,
-
-			// 性能指標 (模擬數據)
-			ResponseTime: 100, // 模擬響應時間
-			DataCount:    1,   // 檢查到的數據量
-		}
-
-		Insert_HistoryData(historyData)
-		if err := global.BatchWriter.AddHistory(historyData); err != nil {
-			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to add history to batch: %s", err.Error()))
-		}
+	code := "QUERY_FAILED"
+	if errors.Is(queryErr, context.DeadlineExceeded) {
+		code = "QUERY_TIMEOUT"
 	}
-
-	// 紀錄缺失設備到 history table 中
-	log.Logrecord_no_rotate("INFO", fmt.Sprintf("Processing %d offline devices", len(removed)))
-	for _, device := range removed {
-		log.Logrecord_no_rotate("INFO", fmt.Sprintf("Creating history record for offline device: %s", device))
-		historyData := entities.History{
-			// 基本信息
-			Logname:     logname,
-			DeviceGroup: device_group,
-			Name:        device,
-
-			// 檢查結果
-			Status:  "offline",
-			Lost:    "true",
-			LostNum: 1,
-
-			// 時間信息
-			Date:      date_time,
-			Time:      hour_time,
-			DateTime:  timenow,
-			Timestamp: execute_time.Unix(),
-
-			// 檢查配置
-			Period: period,
-			Unit:   unit,
-
-			// 性能指標
-			ResponseTime: 0, // 離線設備無響應時間
-			DataCount:    0, // 離線設備無數據
-
-			// 錯誤信息
-			ErrorMsg:  "Device not found in logs",
-			ErrorCode: "DEVICE_OFFLINE",
-		}
-
-		log.Logrecord_no_rotate("INFO", fmt.Sprintf("About to add history to batch for device: %s", device))
-		Insert_HistoryData(historyData)
-		if err := global.BatchWriter.AddHistory(historyData); err != nil {
-			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to add history to batch for device %s: %s", device, err.Error()))
-		} else {
-			log.Logrecord_no_rotate("INFO", fmt.Sprintf("Successfully added history record for offline device: %s", device))
+	maint := NewMaintenanceMatcher(target, idx, time.Unix(base.Timestamp, 0))
+	for _, device := range devices {
+		historyData := base
+		historyData.Name = device.Name
+		historyData.Status = StatusError
+		historyData.Lost = "false"
+		historyData.ErrorMsg = queryErr.Error()
+		historyData.ErrorCode = code
+		if maint.Covers(device.Name) {
+			historyData.Status = StatusMaintenance
 		}
+		writeDeviceHistory(ctx, historyData)
 	}
+}
 
+// writeDeviceHistory 將單筆檢查結果寫入 ES 與 TimescaleDB
+func writeDeviceHistory(ctx context.Context, historyData entities.History) {
+	Insert_HistoryData(ctx, historyData)
+	if err := global.BatchWriter.AddHistory(historyData); err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to add history to batch for device %s: %s", historyData.Name, err.Error()))
+	}
 }
@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 偵測執行器預設值
+const (
+	defaultDetectWorkers      = 8
+	defaultPerConnectionLimit = 4
+	defaultBatchWindow        = 500 * time.Millisecond
+	defaultMaxBatchSize       = 50
+)
+
+// DetectExecutor 排程偵測的執行器：限制全域同時執行數與每個 ES 連線的同時執行數，
+// 並可在觸發後隨機延遲，避免所有排程在同一分鐘同時查詢同一個叢集
+type DetectExecutor struct {
+	workers   int
+	connLimit int
+	jitter    time.Duration
+	global    chan struct{}
+
+	// _msearch 批次
+	batchSearch bool
+	batchWindow time.Duration
+	maxBatch    int
+	batches     map[string]*detectBatch // connID:觸發分鐘 -> 收集中的批次
+
+	mutex  sync.Mutex
+	slots  map[int]*connectionSlot // ES 連線 ID（0 為預設連線）-> 並行控制
+	limits map[int]int             // ES 連線 ID -> 並行上限快取，連線設定更新時清除
+
+	queued    atomic.Int64
+	running   atomic.Int64
+	completed atomic.Int64
+	skipped   atomic.Int64
+	waitTotal atomic.Int64 // 累計等待毫秒數
+	waitMax   atomic.Int64
+
+	batchCount    atomic.Int64
+	batchedJobs   atomic.Int64
+	batchFailures atomic.Int64
+}
+
+// connectionSlot 單一 ES 連線的並行控制與統計
+type connectionSlot struct {
+	limit   int
+	sem     chan struct{}
+	queued  int
+	running int
+}
+
+// DetectExecutorStats 執行器的佇列統計
+type DetectExecutorStats struct {
+	Workers            int                       `json:"workers"`
+	PerConnectionLimit int                       `json:"per_connection_limit"`
+	StartJitter        string                    `json:"start_jitter"`
+	Queued             int64                     `json:"queued"`  // 等待中（含隨機延遲）
+	Running            int64                     `json:"running"` // 執行中
+	Completed          int64                     `json:"completed"`
+	Skipped            int64                     `json:"skipped"`     // 前一次尚未完成而略過
+	AvgWaitMs          int64                     `json:"avg_wait_ms"` // 觸發到開始執行（含隨機延遲）
+	MaxWaitMs          int64                     `json:"max_wait_ms"`
+	Connections        []ConnectionExecutorStats `json:"connections"`
+
+	BatchSearch   bool  `json:"batch_search"`
+	Batches       int64 `json:"batches"`        // 已送出的 _msearch 數
+	BatchedJobs   int64 `json:"batched_jobs"`   // 以 _msearch 查詢第一頁的工作數
+	BatchFailures int64 `json:"batch_failures"` // _msearch 整體失敗、改用一般查詢的次數
+}
+
+// ConnectionExecutorStats 單一 ES 連線的佇列統計
+type ConnectionExecutorStats struct {
+	ESConnectionID int `json:"es_connection_id"` // 0 表示預設連線
+	Limit          int `json:"limit"`
+	Queued         int `json:"queued"`
+	Running        int `json:"running"`
+}
+
+var (
+	detectExecutor     *DetectExecutor
+	detectExecutorOnce sync.Once
+)
+
+// GetDetectExecutor 取得偵測執行器單例（依 detect 設定建立）
+func GetDetectExecutor() *DetectExecutor {
+	detectExecutorOnce.Do(func() {
+		cfg := global.EnvConfig.Detect
+		e := &DetectExecutor{
+			workers:   cfg.Workers,
+			connLimit: cfg.PerConnectionLimit,
+			jitter:    parseDurationOr(cfg.StartJitter, 0),
+			slots:     make(map[int]*connectionSlot),
+			limits:    make(map[int]int),
+
+			batchSearch: cfg.BatchSearch,
+			batchWindow: parseDurationOr(cfg.BatchWindow, defaultBatchWindow),
+			maxBatch:    cfg.MaxBatchSize,
+			batches:     make(map[string]*detectBatch),
+		}
+		if e.maxBatch <= 0 {
+			e.maxBatch = defaultMaxBatchSize
+		}
+		if e.workers <= 0 {
+			e.workers = defaultDetectWorkers
+		}
+		if e.connLimit <= 0 {
+			e.connLimit = defaultPerConnectionLimit
+		}
+		e.global = make(chan struct{}, e.workers)
+		detectExecutor = e
+		log.Logrecord_no_rotate("INFO", fmt.Sprintf("Detect executor initialized: workers=%d, per-connection limit=%d, jitter=%s, batch search=%v", e.workers, e.connLimit, e.jitter, e.batchSearch))
+	})
+	return detectExecutor
+}
+
+// detectJob 一次排程偵測
+type detectJob struct {
+	key         string // target:index
+	target      entities.Target
+	index       entities.Index
+	connID      int
+	scheduledAt time.Time
+	prefetch    *prefetchedPage
+}
+
+// detectBatch 同一 ES 連線、同一分鐘觸發，等待合併為一次 _msearch 的工作
+type detectBatch struct {
+	connID int
+	jobs   []detectJob
+}
+
+// Submit 排入一次排程偵測；同一 target / index 的前一次尚未完成時略過並記錄
+// execute_time 使用觸發時間，查詢區間不受排隊與隨機延遲影響
+func (e *DetectExecutor) Submit(target entities.Target, index entities.Index, scheduledAt time.Time) {
+	// 與手動觸發共用 activeDetects，排入後即標記，直到偵測結束才解除
+	key := detectKey(target.ID, index.ID)
+	if previous, ok := claimDetect(key, scheduledAt); !ok {
+		e.skipped.Add(1)
+		msg := fmt.Sprintf("previous run at %s is still queued or running", previous.Format("2006-01-02 15:04:05"))
+		log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Skipping detect for %s (target %d): %s", index.Logname, target.ID, msg))
+		run := StartDetectRun(target, index, "schedule", scheduledAt)
+		run.Skip(msg)
+		run.Finish()
+		return
+	}
+
+	job := detectJob{key: key, target: target, index: index, scheduledAt: scheduledAt}
+	if index.ESConnectionID != nil {
+		job.connID = *index.ESConnectionID
+	}
+
+	if e.batchSearch {
+		e.addToBatch(job)
+		return
+	}
+	e.dispatch(job, true)
+}
+
+// dispatch 等待連線與全域名額後執行偵測
+func (e *DetectExecutor) dispatch(job detectJob, jitter bool) {
+	sem := e.semaphore(job.connID)
+	e.queued.Add(1)
+	e.mutex.Lock()
+	e.slots[job.connID].queued++
+	e.mutex.Unlock()
+
+	go func() {
+		defer releaseDetect(job.key)
+		defer func() {
+			if r := recover(); r != nil {
+				log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Detect for %s (target %d) panicked: %v", job.index.Logname, job.target.ID, r))
+			}
+		}()
+
+		if jitter {
+			e.sleepJitter()
+		}
+
+		// 先取得連線的名額再佔用全域名額，叢集滿載時不佔住其他叢集可用的 worker
+		sem <- struct{}{}
+		defer func() { <-sem }()
+		e.global <- struct{}{}
+		defer func() { <-e.global }()
+
+		e.started(job.connID, time.Since(job.scheduledAt))
+		defer e.finished(job.connID)
+
+		RunDetect(context.Background(), job.scheduledAt, job.target, job.index, DetectOptions{Trigger: "schedule", prefetch: job.prefetch, claimed: true})
+	}()
+}
+
+// addToBatch 將工作加入同一連線、同一分鐘的批次；批次滿了立即送出，否則等待 batchWindow 後送出
+func (e *DetectExecutor) addToBatch(job detectJob) {
+	key := fmt.Sprintf("%d:%d", job.connID, job.scheduledAt.Truncate(time.Minute).Unix())
+
+	e.mutex.Lock()
+	b, ok := e.batches[key]
+	if !ok {
+		b = &detectBatch{connID: job.connID}
+		e.batches[key] = b
+		time.AfterFunc(e.batchWindow, func() {
+			e.mutex.Lock()
+			current := e.batches[key] == b
+			if current {
+				delete(e.batches, key)
+			}
+			e.mutex.Unlock()
+			if current {
+				e.runBatch(b)
+			}
+		})
+	}
+	b.jobs = append(b.jobs, job)
+	full := len(b.jobs) >= e.maxBatch
+	if full {
+		delete(e.batches, key)
+	}
+	e.mutex.Unlock()
+
+	if full {
+		e.runBatch(b)
+	}
+}
+
+// runBatch 以單一 _msearch 取得批次內所有 index 的第一頁，再分別交給偵測流程
+// _msearch 失敗或個別查詢失敗時，該工作改用一般查詢
+func (e *DetectExecutor) runBatch(b *detectBatch) {
+	if len(b.jobs) == 1 {
+		e.dispatch(b.jobs[0], true)
+		return
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Detect batch on ES connection %d panicked: %v", b.connID, r))
+				for _, job := range b.jobs {
+					releaseDetect(job.key)
+				}
+			}
+		}()
+
+		e.sleepJitter()
+
+		var searchable []int
+		var params []DeviceSearchParams
+		for i, job := range b.jobs {
+			_, from, to := detectTimeRange(job.scheduledAt, job.index, DetectOptions{})
+			p, err := NewDeviceSearchParams(job.index, from.Format(esTimeLayout), to.Format(esTimeLayout))
+			if err != nil {
+				continue
+			}
+			searchable = append(searchable, i)
+			params = append(params, p)
+		}
+
+		if len(params) > 1 {
+			esClient, err := detectClient(b.jobs[searchable[0]].index.ID)
+			if err == nil {
+				sem := e.semaphore(b.connID)
+				sem <- struct{}{}
+				e.global <- struct{}{}
+				var pages []*prefetchedPage
+				pages, err = multiSearchFirstPages(context.Background(), esClient, params)
+				<-e.global
+				<-sem
+
+				if err == nil {
+					for n, i := range searchable {
+						b.jobs[i].prefetch = pages[n]
+					}
+				}
+			}
+			e.batchCount.Add(1)
+			e.batchedJobs.Add(int64(len(params)))
+			if err != nil {
+				e.batchFailures.Add(1)
+				log.Logrecord_no_rotate("WARNING", fmt.Sprintf("msearch for %d indices on ES connection %d failed, falling back to individual searches: %s", len(params), b.connID, err.Error()))
+			}
+		}
+
+		for _, job := range b.jobs {
+			e.dispatch(job, false)
+		}
+	}()
+}
+
+// sleepJitter 依設定隨機延遲
+func (e *DetectExecutor) sleepJitter() {
+	if e.jitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(e.jitter))))
+	}
+}
+
+// semaphore 取得 ES 連線的並行控制；連線的上限設定變更時建立新的 semaphore（執行中的工作仍釋放到舊的）
+func (e *DetectExecutor) semaphore(connID int) chan struct{} {
+	limit := e.connectionLimit(connID)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	s, ok := e.slots[connID]
+	if !ok {
+		s = &connectionSlot{}
+		e.slots[connID] = s
+	}
+	if s.sem == nil || s.limit != limit {
+		s.limit = limit
+		s.sem = make(chan struct{}, limit)
+	}
+	return s.sem
+}
+
+// connectionLimit ES 連線的並行上限（連線設定優先，否則使用全域設定）；讀取成功的結果會快取
+func (e *DetectExecutor) connectionLimit(connID int) int {
+	if connID <= 0 {
+		return e.connLimit
+	}
+
+	e.mutex.Lock()
+	limit, ok := e.limits[connID]
+	e.mutex.Unlock()
+	if ok {
+		return limit
+	}
+
+	var conn entities.ESConnection
+	if err := global.Mysql.Select("max_concurrency").Where("id = ?", connID).First(&conn).Error; err != nil {
+		return e.connLimit
+	}
+	limit = e.connLimit
+	if conn.MaxConcurrency > 0 {
+		limit = conn.MaxConcurrency
+	}
+
+	e.mutex.Lock()
+	e.limits[connID] = limit
+	e.mutex.Unlock()
+	return limit
+}
+
+// invalidateConnectionLimit ES 連線設定變更時清除並行上限快取，下次排入工作時重新讀取
+func invalidateConnectionLimit(connID int) {
+	if detectExecutor == nil {
+		return
+	}
+	detectExecutor.mutex.Lock()
+	delete(detectExecutor.limits, connID)
+	detectExecutor.mutex.Unlock()
+}
+
+// started 工作開始執行，更新佇列統計
+func (e *DetectExecutor) started(connID int, wait time.Duration) {
+	e.queued.Add(-1)
+	e.running.Add(1)
+
+	waitMs := wait.Milliseconds()
+	e.waitTotal.Add(waitMs)
+	for {
+		max := e.waitMax.Load()
+		if waitMs <= max || e.waitMax.CompareAndSwap(max, waitMs) {
+			break
+		}
+	}
+
+	e.mutex.Lock()
+	if s, ok := e.slots[connID]; ok {
+		s.queued--
+		s.running++
+	}
+	e.mutex.Unlock()
+}
+
+// finished 工作結束，更新佇列統計
+func (e *DetectExecutor) finished(connID int) {
+	e.running.Add(-1)
+	e.completed.Add(1)
+
+	e.mutex.Lock()
+	if s, ok := e.slots[connID]; ok {
+		s.running--
+	}
+	e.mutex.Unlock()
+}
+
+// Stats 目前的佇列統計
+func (e *DetectExecutor) Stats() DetectExecutorStats {
+	stats := DetectExecutorStats{
+		Workers:            e.workers,
+		PerConnectionLimit: e.connLimit,
+		StartJitter:        e.jitter.String(),
+		Queued:             e.queued.Load(),
+		Running:            e.running.Load(),
+		Completed:          e.completed.Load(),
+		Skipped:            e.skipped.Load(),
+		MaxWaitMs:          e.waitMax.Load(),
+		Connections:        []ConnectionExecutorStats{},
+		BatchSearch:        e.batchSearch,
+		Batches:            e.batchCount.Load(),
+		BatchedJobs:        e.batchedJobs.Load(),
+		BatchFailures:      e.batchFailures.Load(),
+	}
+	if started := stats.Running + stats.Completed; started > 0 {
+		stats.AvgWaitMs = e.waitTotal.Load() / started
+	}
+
+	e.mutex.Lock()
+	for connID, s := range e.slots {
+		stats.Connections = append(stats.Connections, ConnectionExecutorStats{
+			ESConnectionID: connID,
+			Limit:          s.limit,
+			Queued:         s.queued,
+			Running:        s.running,
+		})
+	}
+	e.mutex.Unlock()
+	sort.Slice(stats.Connections, func(i, j int) bool {
+		return stats.Connections[i].ESConnectionID < stats.Connections[j].ESConnectionID
+	})
+
+	return stats
+}
+
+// GetDetectExecutorStats 取得偵測執行器的佇列統計
+func GetDetectExecutorStats() models.Response {
+	res := models.Response{}
+	res.Success = true
+	res.Msg = "Get detect executor stats success"
+	res.Body = GetDetectExecutor().Stats()
+	return res
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"time"
+)
+
+// DetectRunRecorder 紀錄單次 Detect 執行過程，開始時寫入 running，結束時更新結果
+type DetectRunRecorder struct {
+	run entities.DetectRun
+}
+
+// StartDetectRun 建立執行紀錄
+func StartDetectRun(target entities.Target, index entities.Index, trigger string, startedAt time.Time) *DetectRunRecorder {
+	r := &DetectRunRecorder{run: entities.DetectRun{
+		TargetID:  target.ID,
+		IndexID:   index.ID,
+		Logname:   index.Logname,
+		Pattern:   index.Pattern,
+		Trigger:   trigger,
+		Status:    entities.DetectRunRunning,
+		StartedAt: startedAt.UnixMilli(),
+	}}
+	if err := global.Mysql.Create(&r.run).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to create detect run for %s: %s", index.Logname, err.Error()))
+	}
+	return r
+}
+
+// ID 執行紀錄 ID（寫入失敗時為 0）
+func (r *DetectRunRecorder) ID() int {
+	return r.run.ID
+}
+
+// SetWindow 紀錄查詢區間
+func (r *DetectRunRecorder) SetWindow(from string, to string) {
+	r.run.WindowFrom = from
+	r.run.WindowTo = to
+}
+
+// SetSearch 紀錄 ES 查詢診斷資訊
+func (r *DetectRunRecorder) SetSearch(result DeviceSearchResult) {
+	r.run.Took = result.Took
+	r.run.Pages = result.Pages
+	r.run.TimedOut = result.TimedOut
+	r.run.ShardsTotal = result.Shards.Total
+	r.run.ShardsFailed = result.Shards.Failed
+	r.run.BucketCount = len(result.Buckets)
+	r.run.ShardFailures = nil
+	for _, failure := range result.Shards.Failures {
+		r.run.ShardFailures = append(r.run.ShardFailures, fmt.Sprintf("%s[%d] %s: %s", failure.Index, failure.Shard, failure.Reason.Type, failure.Reason.Reason))
+	}
+	if result.Partial {
+		r.run.Status = entities.DetectRunPartial
+	}
+}
+
+// SetCompare 紀錄與資產清單的比對結果
+func (r *DetectRunRecorder) SetCompare(expected int, added int, removed int) {
+	r.run.ExpectedCount = expected
+	r.run.AddedCount = added
+	r.run.RemovedCount = removed
+}
+
+// SetCounts 紀錄分類後的設備數
+func (r *DetectRunRecorder) SetCounts(online int, warning int, offline int) {
+	r.run.OnlineCount = online
+	r.run.WarningCount = warning
+	r.run.OfflineCount = offline
+}
+
+// AddNotification 累計通知發送結果
+func (r *DetectRunRecorder) AddNotification(result NotificationResult) {
+	r.run.NotificationsSent += result.Sent
+	r.run.NotificationsFailed += result.Failed
+}
+
+// Skip 前一次執行尚未結束，本次略過
+func (r *DetectRunRecorder) Skip(reason string) {
+	r.run.Status = entities.DetectRunSkipped
+	r.run.Error = reason
+}
+
+// Fail 紀錄錯誤，執行狀態改為 failed
+func (r *DetectRunRecorder) Fail(err error) {
+	r.run.Status = entities.DetectRunFailed
+	r.run.Error = err.Error()
+}
+
+// Finish 結束執行並寫回紀錄
+func (r *DetectRunRecorder) Finish() {
+	if r.run.Status == entities.DetectRunRunning {
+		r.run.Status = entities.DetectRunSuccess
+	}
+	r.run.FinishedAt = time.Now().UnixMilli()
+	r.run.DurationMs = r.run.FinishedAt - r.run.StartedAt
+
+	if r.run.ID == 0 {
+		return
+	}
+	if err := global.Mysql.Select("*").Omit("created_at").Where("id = ?", r.run.ID).Updates(&r.run).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to update detect run %d: %s", r.run.ID, err.Error()))
+	}
+}
+
+// DetectRunQuery 執行紀錄查詢條件
+type DetectRunQuery struct {
+	TargetID int
+	IndexID  int
+	Logname  string
+	Status   string
+	From     int64 // Unix 秒（含）
+	To       int64 // Unix 秒（含）
+	Limit    int
+	Offset   int
+}
+
+// GetDetectRuns 查詢執行紀錄（依開始時間新到舊）
+func GetDetectRuns(q DetectRunQuery) models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = []entities.DetectRun{}
+
+	if q.Limit <= 0 || q.Limit > 1000 {
+		q.Limit = 100
+	}
+
+	query := global.Mysql.Model(&entities.DetectRun{})
+	if q.TargetID > 0 {
+		query = query.Where("target_id = ?", q.TargetID)
+	}
+	if q.IndexID > 0 {
+		query = query.Where("index_id = ?", q.IndexID)
+	}
+	if q.Logname != "" {
+		query = query.Where("logname = ?", q.Logname)
+	}
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	if q.From > 0 {
+		query = query.Where("started_at >= ?", q.From*1000)
+	}
+	if q.To > 0 {
+		query = query.Where("started_at < ?", (q.To+1)*1000)
+	}
+
+	var runs []entities.DetectRun
+	if err := query.Order("started_at DESC").Limit(q.Limit).Offset(q.Offset).Find(&runs).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to get detect runs: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Get detect runs success"
+	res.Body = runs
+	return res
+}
+
+// DetectRunDetail 執行紀錄與該次發送的通知
+type DetectRunDetail struct {
+	entities.DetectRun
+	Deliveries []entities.NotificationDelivery `json:"deliveries"`
+}
+
+// GetDetectRun 取得單筆執行紀錄
+func GetDetectRun(id int) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	var detail DetectRunDetail
+	if err := global.Mysql.Where("id = ?", id).First(&detail.DetectRun).Error; err != nil {
+		res.Msg = fmt.Sprintf("Detect run not found: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	// 執行期間由同一個 target / logname 發出的通知
+	detail.Deliveries = []entities.NotificationDelivery{}
+	if detail.FinishedAt > 0 {
+		err := global.Mysql.
+			Where("source = ? AND source_id = ? AND logname = ? AND created_at >= ? AND created_at <= ?",
+				"device", detail.TargetID, detail.Logname, detail.StartedAt/1000, detail.FinishedAt/1000+1).
+			Order("id ASC").Find(&detail.Deliveries).Error
+		if err != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to get deliveries for detect run %d: %s", id, err.Error()))
+		}
+	}
+
+	res.Success = true
+	res.Msg = "Get detect run success"
+	res.Body = detail
+	return res
+}
+
+// maxManualWindow 手動執行允許的最大查詢區間
+const maxManualWindow = 31 * 24 * time.Hour
+
+// RunDetectNow 立即對單一 index 執行偵測（可指定查詢區間與 dry-run）
+func RunDetectNow(req entities.RunDetectRequest) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	dryRun := req.DryRun == nil || *req.DryRun
+
+	var index entities.Index
+	if err := global.Mysql.Where("id = ?", req.IndexID).First(&index).Error; err != nil {
+		res.Msg = fmt.Sprintf("Index not found: %s", err.Error())
+		return res
+	}
+
+	var target entities.Target
+	if req.TargetID > 0 {
+		var err error
+		target, err = GetTargetByID(req.TargetID)
+		if err != nil {
+			res.Msg = fmt.Sprintf("Target not found: %s", err.Error())
+			return res
+		}
+		linked := false
+		for _, idx := range target.Indices {
+			linked = linked || idx.ID == index.ID
+		}
+		if !linked {
+			res.Msg = fmt.Sprintf("Index %d is not linked to target %d", index.ID, target.ID)
+			return res
+		}
+	} else if !dryRun {
+		res.Msg = "target_id is required when dry_run is false"
+		return res
+	}
+
+	opts := DetectOptions{Trigger: "manual", DryRun: dryRun}
+	if req.From != "" || req.To != "" {
+		from, err := time.Parse(time.RFC3339, req.From)
+		if err != nil {
+			res.Msg = fmt.Sprintf("Invalid from: %s", err.Error())
+			return res
+		}
+		to, err := time.Parse(time.RFC3339, req.To)
+		if err != nil {
+			res.Msg = fmt.Sprintf("Invalid to: %s", err.Error())
+			return res
+		}
+		if !from.Before(to) || to.Sub(from) > maxManualWindow {
+			res.Msg = "from must be before to and the window must not exceed 31 days"
+			return res
+		}
+		opts.From, opts.To = &from, &to
+	}
+
+	report := RunDetect(context.Background(), time.Now(), target, index, opts)
+	if report.Error != "" {
+		res.Msg = report.Error
+		res.Body = report
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Run detect success"
+	res.Body = report
+	return res
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClaimDetect(t *testing.T) {
+	key := detectKey(1, 2)
+	first := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+	t.Cleanup(func() { releaseDetect(key) })
+
+	if _, ok := claimDetect(key, first); !ok {
+		t.Fatal("first claim should succeed")
+	}
+	// 執行器排入後，手動觸發或下一次排程都看到同一筆標記
+	previous, ok := claimDetect(key, first.Add(time.Minute))
+	if ok || !previous.Equal(first) {
+		t.Fatalf("second claim = (%v, %v), want (%v, false)", previous, ok, first)
+	}
+	if _, ok := claimDetect(detectKey(1, 3), first); !ok {
+		t.Error("another index should not be blocked")
+	}
+	releaseDetect(detectKey(1, 3))
+
+	releaseDetect(key)
+	if _, ok := claimDetect(key, first.Add(2*time.Minute)); !ok {
+		t.Error("claim after release should succeed")
+	}
+}
@@ -21,6 +21,25 @@ func CreateDevice(device []entities.Device) models.Response {
 	// 	return res
 	// }
 
+	for _, d := range device {
+		if err := d.Validate(); err != nil {
+			res.Msg = err.Error()
+			return res
+		}
+		if err := checkDeviceAliases(d); err != nil {
+			res.Msg = err.Error()
+			return res
+		}
+	}
+
+	var groups []string
+	for _, d := range device {
+		groups = append(groups, d.DeviceGroup)
+	}
+	if err := ensureDeviceGroups(global.Mysql, groups...); err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Create device group Fail: %s", err.Error()))
+	}
+
 	err := global.Mysql.Create(&device).Error
 	if err != nil {
 		res.Msg = "Create Fail"
@@ -35,13 +54,45 @@ func CreateDevice(device []entities.Device) models.Response {
 	return res
 }
 
-func UpdateDevice(device entities.Device) models.Response {
+// deviceUpdateColumns 更新設備時允許寫入的欄位；status / absent_until 只能經由 SetDeviceStatus 驗證後修改
+var deviceUpdateColumns = []string{"device_group", "name", "min_doc_count", "tags", "owner", "site", "environment", "criticality", "aliases"}
+
+// UpdateDevice 更新設備；只寫入 fields 中送出的欄位，未送出的欄位保留原值（fields 為 nil 時寫入全部欄位）
+func UpdateDevice(update entities.Device, fields []string) models.Response {
 
 	res := models.Response{}
 	res.Success = false
 	res.Body = []entities.Device{}
 
-	err := global.Mysql.Select("*").Where("id = ?", device.ID).Updates(&device).Error
+	var device entities.Device
+	if err := global.Mysql.Where("id = ?", update.ID).First(&device).Error; err != nil {
+		res.Msg = "device ID does not exist"
+		return res
+	}
+	columns := selectUpdateColumns(deviceUpdateColumns, fields)
+	overlayColumns(&device, &update, columns)
+
+	if err := device.Validate(); err != nil {
+		res.Msg = err.Error()
+		return res
+	}
+	if err := checkDeviceAliases(device); err != nil {
+		res.Msg = err.Error()
+		return res
+	}
+
+	if err := ensureDeviceGroups(global.Mysql, device.DeviceGroup); err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Create device group Fail: %s", err.Error()))
+	}
+
+	if len(columns) == 0 {
+		res.Success = true
+		res.Body = device
+		res.Msg = "Update Success"
+		return res
+	}
+
+	err := global.Mysql.Model(&entities.Device{}).Where("id = ?", device.ID).Select(columns).Updates(&device).Error
 	if err != nil {
 		res.Msg = "Update Fail"
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Update Device Fail error: %s", err.Error()))
@@ -82,13 +133,14 @@ func DeleteDevice(id int) models.Response {
 
 }
 
-func GetAllDevices() models.Response {
+// GetAllDevices 取得設備列表，可依名稱、群組、標籤與屬性篩選
+func GetAllDevices(filter entities.DeviceFilter) models.Response {
 
 	res := models.Response{}
 	res.Success = false
 	res.Body = []entities.Device{}
 
-	err := global.Mysql.Find(&res.Body).Error
+	err := filterDevices(global.Mysql, filter).Find(&res.Body).Error
 	if err != nil {
 		res.Msg = fmt.Sprintf("Error Get All Devices: %s", err.Error())
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Error Get All Devices: %s", err.Error()))
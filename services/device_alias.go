@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// nameRewrite 已編譯的名稱改寫規則
+type nameRewrite struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+// DeviceNameResolver 將 ES bucket key 換成資產清單中的設備名稱：
+// 先比對別名原值，再依 index 的正規化規則處理後比對設備名稱與別名，都沒有對應時回傳正規化後的名稱
+type DeviceNameResolver struct {
+	lowercase   bool
+	stripDomain bool
+	rewrites    []nameRewrite
+	names       map[string]string // 原值或正規化後的名稱 / 別名 -> 設備名稱
+}
+
+// compileNameRewrites 編譯 index 的改寫規則
+func compileNameRewrites(rules []entities.NameRewriteRule) ([]nameRewrite, error) {
+	rewrites := make([]nameRewrite, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("rewrite pattern is required")
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rewrite pattern %q: %w", rule.Pattern, err)
+		}
+		rewrites = append(rewrites, nameRewrite{re: re, replace: rule.Replace})
+	}
+	return rewrites, nil
+}
+
+// ValidateNameNormalization 驗證 index 的名稱改寫規則
+func ValidateNameNormalization(index entities.Index) error {
+	_, err := compileNameRewrites(index.NormalizeRewrites)
+	return err
+}
+
+// NewDeviceNameResolver 依 index 的正規化規則與群組設備的別名建立對照
+func NewDeviceNameResolver(idx entities.Index, devices []entities.Device) (*DeviceNameResolver, error) {
+	rewrites, err := compileNameRewrites(idx.NormalizeRewrites)
+	if err != nil {
+		return nil, err
+	}
+	r := &DeviceNameResolver{
+		lowercase:   idx.NormalizeLowercase,
+		stripDomain: idx.NormalizeStripDomain,
+		rewrites:    rewrites,
+		names:       map[string]string{},
+	}
+
+	// 設備名稱優先於其他設備的別名
+	for _, device := range devices {
+		for _, alias := range device.Aliases {
+			r.names[alias] = device.Name
+			r.names[r.Normalize(alias)] = device.Name
+		}
+	}
+	for _, device := range devices {
+		r.names[r.Normalize(device.Name)] = device.Name
+		r.names[device.Name] = device.Name
+	}
+	return r, nil
+}
+
+// Normalize 依序轉小寫、去除網域後綴、套用改寫規則
+func (r *DeviceNameResolver) Normalize(name string) string {
+	name = strings.TrimSpace(name)
+	if r.lowercase {
+		name = strings.ToLower(name)
+	}
+	if r.stripDomain && net.ParseIP(name) == nil {
+		if i := strings.Index(name, "."); i > 0 {
+			name = name[:i]
+		}
+	}
+	for _, rewrite := range r.rewrites {
+		name = rewrite.re.ReplaceAllString(name, rewrite.replace)
+	}
+	return name
+}
+
+// Resolve 回傳 ES bucket key 對應的設備名稱
+func (r *DeviceNameResolver) Resolve(key string) string {
+	if name, ok := r.names[key]; ok {
+		return name
+	}
+	normalized := r.Normalize(key)
+	if name, ok := r.names[normalized]; ok {
+		return name
+	}
+	return normalized
+}
+
+// Apply 將聚合結果的 key 換成設備名稱，對應到同一台設備的 bucket 合併筆數；
+// 回傳的 renamed 為有改名的原始 key -> 設備名稱
+func (r *DeviceNameResolver) Apply(result DeviceSearchResult) (DeviceSearchResult, map[string]string) {
+	if r == nil {
+		return result, nil
+	}
+	renamed := map[string]string{}
+	merged := make([]TermsBucket, 0, len(result.Buckets))
+	position := map[string]int{}
+	for _, bucket := range result.Buckets {
+		name := r.Resolve(bucket.Key)
+		if name == "" {
+			continue
+		}
+		if name != bucket.Key {
+			renamed[bucket.Key] = name
+		}
+		if i, ok := position[name]; ok {
+			merged[i].DocCount += bucket.DocCount
+			continue
+		}
+		position[name] = len(merged)
+		merged = append(merged, TermsBucket{Key: name, DocCount: bucket.DocCount})
+	}
+	result.Buckets = merged
+	return result, renamed
+}
+
+// checkDeviceAliases 檢查別名是否與同群組其他設備的名稱或別名重複
+func checkDeviceAliases(device entities.Device) error {
+	if len(device.Aliases) == 0 {
+		return nil
+	}
+	var others []entities.Device
+	if err := global.Mysql.Where("device_group = ? AND id <> ?", device.DeviceGroup, device.ID).Find(&others).Error; err != nil {
+		return fmt.Errorf("failed to check aliases: %w", err)
+	}
+	used := map[string]string{}
+	for _, other := range others {
+		used[other.Name] = other.Name
+		for _, alias := range other.Aliases {
+			used[alias] = other.Name
+		}
+	}
+	for _, alias := range device.Aliases {
+		if owner, ok := used[alias]; ok {
+			return fmt.Errorf("alias %s is already used by device %s", alias, owner)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"log-detect/entities"
+	"reflect"
+	"testing"
+)
+
+func TestDeviceNameResolverNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		index entities.Index
+		in    string
+		want  string
+	}{
+		{name: "no rules", index: entities.Index{}, in: " FW01.corp.local ", want: "FW01.corp.local"},
+		{name: "lowercase", index: entities.Index{NormalizeLowercase: true}, in: "FW01", want: "fw01"},
+		{name: "strip domain", index: entities.Index{NormalizeStripDomain: true}, in: "fw01.corp.local", want: "fw01"},
+		{name: "ipv4 kept", index: entities.Index{NormalizeStripDomain: true}, in: "10.0.0.1", want: "10.0.0.1"},
+		{name: "ipv6 kept", index: entities.Index{NormalizeStripDomain: true}, in: "fe80::1", want: "fe80::1"},
+		{
+			name:  "rewrites after lowercase and domain",
+			index: entities.Index{NormalizeLowercase: true, NormalizeStripDomain: true, NormalizeRewrites: []entities.NameRewriteRule{{Pattern: `^(fw\d+)-mgmt$`, Replace: "$1"}}},
+			in:    "FW01-MGMT.corp.local",
+			want:  "fw01",
+		},
+		{
+			name:  "rewrites applied in order",
+			index: entities.Index{NormalizeRewrites: []entities.NameRewriteRule{{Pattern: `_`, Replace: "-"}, {Pattern: `-+`, Replace: "-"}}},
+			in:    "sw__01",
+			want:  "sw-01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewDeviceNameResolver(tt.index, nil)
+			if err != nil {
+				t.Fatalf("NewDeviceNameResolver() error = %v", err)
+			}
+			if got := r.Normalize(tt.in); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceNameResolverResolve(t *testing.T) {
+	index := entities.Index{NormalizeLowercase: true, NormalizeStripDomain: true}
+	devices := []entities.Device{
+		{Name: "FW01", Aliases: []string{"10.0.0.1", "edge-fw"}},
+		{Name: "sw01"},
+		// 別名與其他設備名稱相同時以設備名稱為準
+		{Name: "sw02", Aliases: []string{"sw01"}},
+	}
+	r, err := NewDeviceNameResolver(index, devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "FW01", want: "FW01"},
+		{key: "fw01.corp.local", want: "FW01"},
+		{key: "10.0.0.1", want: "FW01"},
+		{key: "EDGE-FW.corp.local", want: "FW01"},
+		{key: "SW01.corp.local", want: "sw01"},
+		{key: "sw01", want: "sw01"},
+		{key: "Unknown.corp.local", want: "unknown"},
+	}
+	for _, tt := range tests {
+		if got := r.Resolve(tt.key); got != tt.want {
+			t.Errorf("Resolve(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestDeviceNameResolverApply(t *testing.T) {
+	index := entities.Index{NormalizeLowercase: true, NormalizeStripDomain: true}
+	r, err := NewDeviceNameResolver(index, []entities.Device{{Name: "fw01", Aliases: []string{"10.0.0.1"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := DeviceSearchResult{
+		Buckets: []TermsBucket{
+			{Key: "fw01", DocCount: 5},
+			{Key: "FW01.corp.local", DocCount: 3},
+			{Key: "10.0.0.1", DocCount: 2},
+			{Key: "SW01", DocCount: 7},
+		},
+		Pages: 1,
+	}
+
+	got, renamed := r.Apply(result)
+	wantBuckets := []TermsBucket{{Key: "fw01", DocCount: 10}, {Key: "sw01", DocCount: 7}}
+	if !reflect.DeepEqual(got.Buckets, wantBuckets) {
+		t.Errorf("buckets = %+v, want %+v", got.Buckets, wantBuckets)
+	}
+	wantRenamed := map[string]string{"FW01.corp.local": "fw01", "10.0.0.1": "fw01", "SW01": "sw01"}
+	if !reflect.DeepEqual(renamed, wantRenamed) {
+		t.Errorf("renamed = %v, want %v", renamed, wantRenamed)
+	}
+	if got.Pages != 1 {
+		t.Errorf("pages = %d, other fields should be kept", got.Pages)
+	}
+
+	var nilResolver *DeviceNameResolver
+	same, renamed := nilResolver.Apply(result)
+	if !reflect.DeepEqual(same, result) || renamed != nil {
+		t.Errorf("nil resolver changed the result: %+v, %v", same, renamed)
+	}
+}
+
+func TestValidateNameNormalization(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []entities.NameRewriteRule
+		wantErr bool
+	}{
+		{name: "no rules"},
+		{name: "valid", rules: []entities.NameRewriteRule{{Pattern: `-mgmt$`, Replace: ""}}},
+		{name: "empty pattern", rules: []entities.NameRewriteRule{{Pattern: "", Replace: "x"}}, wantErr: true},
+		{name: "invalid regexp", rules: []entities.NameRewriteRule{{Pattern: `(fw`, Replace: "$1"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNameNormalization(entities.Index{NormalizeRewrites: tt.rules})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateNameNormalization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,568 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deviceGroupTables 以 device_group 欄位存放群組名稱的資料表（改名與合併時一併更新）
+var deviceGroupTables = []string{"devices", "indices", "discovered_devices", "histories", "history_archives", "alert_histories"}
+
+// GetDeviceGroups 取得所有設備群組
+func GetDeviceGroups() models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = []entities.DeviceGroup{}
+
+	var groups []entities.DeviceGroup
+	if err := global.Mysql.Order("name").Find(&groups).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to get device groups: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Get device groups success"
+	res.Body = groups
+	return res
+}
+
+// GetDeviceGroupTree 以樹狀結構取得設備群組（上層群組不存在的視為最上層）
+func GetDeviceGroupTree() models.Response {
+	res := GetDeviceGroups()
+	if !res.Success {
+		return res
+	}
+	groups := res.Body.([]entities.DeviceGroup)
+
+	ids := map[int]bool{}
+	children := map[int][]entities.DeviceGroup{}
+	for _, group := range groups {
+		ids[group.ID] = true
+	}
+	var roots []entities.DeviceGroup
+	for _, group := range groups {
+		if group.ParentID != nil && ids[*group.ParentID] {
+			children[*group.ParentID] = append(children[*group.ParentID], group)
+		} else {
+			roots = append(roots, group)
+		}
+	}
+
+	// visited 避免資料中的循環造成無窮遞迴
+	visited := map[int]bool{}
+	var build func(group entities.DeviceGroup) entities.DeviceGroup
+	build = func(group entities.DeviceGroup) entities.DeviceGroup {
+		visited[group.ID] = true
+		for _, child := range children[group.ID] {
+			if !visited[child.ID] {
+				group.Children = append(group.Children, build(child))
+			}
+		}
+		return group
+	}
+	tree := []entities.DeviceGroup{}
+	for _, root := range roots {
+		tree = append(tree, build(root))
+	}
+
+	res.Body = tree
+	return res
+}
+
+// CreateDeviceGroup 新增設備群組
+func CreateDeviceGroup(group entities.DeviceGroup) models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = entities.DeviceGroup{}
+
+	group.ID = 0
+	group.FormerNames = nil
+	group.Name = strings.TrimSpace(group.Name)
+	if err := validateDeviceGroupName(global.Mysql, group.Name, 0); err != nil {
+		res.Msg = err.Error()
+		return res
+	}
+	if err := validateDeviceGroupParent(group); err != nil {
+		res.Msg = err.Error()
+		return res
+	}
+
+	if err := global.Mysql.Create(&group).Error; err != nil {
+		res.Msg = "Create device group Fail"
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Create device group Fail: %s", err.Error()))
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Create device group Success"
+	res.Body = group
+	return res
+}
+
+// UpdateDeviceGroup 更新群組說明、上層群組、預設收件人與標籤；名稱請用 RenameDeviceGroup 修改
+func UpdateDeviceGroup(group entities.DeviceGroup) models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = entities.DeviceGroup{}
+
+	var existing entities.DeviceGroup
+	if err := global.Mysql.Where("id = ?", group.ID).First(&existing).Error; err != nil {
+		res.Msg = "device group ID does not exist"
+		return res
+	}
+	if err := validateDeviceGroupParent(group); err != nil {
+		res.Msg = err.Error()
+		return res
+	}
+
+	err := global.Mysql.Model(&existing).Select("description", "parent_id", "receivers", "tags").Updates(&group).Error
+	if err != nil {
+		res.Msg = "Update device group Fail"
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Update device group Fail: %s", err.Error()))
+		return res
+	}
+	global.Mysql.Where("id = ?", group.ID).First(&existing)
+
+	res.Success = true
+	res.Msg = "Update device group Success"
+	res.Body = existing
+	return res
+}
+
+// DeleteDeviceGroup 刪除沒有子群組、設備與 index 使用的群組
+func DeleteDeviceGroup(id int) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	var group entities.DeviceGroup
+	if err := global.Mysql.Where("id = ?", id).First(&group).Error; err != nil {
+		res.Msg = "device group ID does not exist"
+		return res
+	}
+
+	var children, devices, indices int64
+	global.Mysql.Model(&entities.DeviceGroup{}).Where("parent_id = ?", id).Count(&children)
+	global.Mysql.Model(&entities.Device{}).Where("device_group = ?", group.Name).Count(&devices)
+	global.Mysql.Model(&entities.Index{}).Where("device_group = ?", group.Name).Count(&indices)
+	if children > 0 || devices > 0 || indices > 0 {
+		res.Msg = fmt.Sprintf("device group %s is still in use (%d child groups, %d devices, %d indices)", group.Name, children, devices, indices)
+		return res
+	}
+
+	if err := global.Mysql.Delete(&group).Error; err != nil {
+		res.Msg = fmt.Sprintf("Error when deleting device group: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Delete Success"
+	return res
+}
+
+// RenameDeviceGroup 群組改名：更新所有參照的資料表，舊名稱記入 FormerNames，TimescaleDB 歷史改以新名稱查詢；
+// TimescaleDB 對應失敗時仍回傳成功，並在結果中帶上警告
+func RenameDeviceGroup(req entities.DeviceGroupRenameRequest) models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = entities.DeviceGroupChangeResult{}
+
+	name := strings.TrimSpace(req.Name)
+	var group entities.DeviceGroup
+	var oldName string
+	err := global.Mysql.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", req.ID).First(&group).Error; err != nil {
+			return fmt.Errorf("device group ID does not exist")
+		}
+		if group.Name == name {
+			return fmt.Errorf("device group is already named %s", name)
+		}
+		if err := validateDeviceGroupName(tx, name, group.ID); err != nil {
+			return err
+		}
+
+		oldName = group.Name
+		group.Name = name
+		group.FormerNames = appendUnique(removeGroupName(group.FormerNames, name), oldName)
+		if err := tx.Model(&group).Select("name", "former_names").Updates(&group).Error; err != nil {
+			return err
+		}
+		return moveDeviceGroupReferences(tx, oldName, name)
+	})
+	if err != nil {
+		res.Msg = fmt.Sprintf("Rename device group Fail: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	result := remapDeviceGroupHistoryResult(group, oldName)
+	reloadDeviceGroupSchedules(group.Name)
+	log.Logrecord_no_rotate("INFO", fmt.Sprintf("Device group %s renamed to %s", oldName, group.Name))
+
+	res.Success = true
+	res.Msg = "Rename device group Success"
+	res.Body = result
+	return res
+}
+
+// MergeDeviceGroups 將來源群組併入目標群組：設備（同名時保留目標群組的設備）、index、子群組、
+// 預設收件人、標籤與歷史資料移到目標群組後刪除來源群組；TimescaleDB 對應失敗時在結果中帶上警告
+func MergeDeviceGroups(req entities.DeviceGroupMergeRequest) models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = entities.DeviceGroupChangeResult{}
+
+	if req.SourceID == req.TargetID {
+		res.Msg = "source_id and target_id must be different"
+		return res
+	}
+
+	var source, target entities.DeviceGroup
+	err := global.Mysql.Transaction(func(tx *gorm.DB) error {
+		lock := clause.Locking{Strength: "UPDATE"}
+		if err := tx.Clauses(lock).Where("id = ?", req.SourceID).First(&source).Error; err != nil {
+			return fmt.Errorf("source device group does not exist")
+		}
+		if err := tx.Clauses(lock).Where("id = ?", req.TargetID).First(&target).Error; err != nil {
+			return fmt.Errorf("target device group does not exist")
+		}
+		for _, ancestor := range deviceGroupAncestors(tx, target) {
+			if ancestor.ID == source.ID {
+				return fmt.Errorf("cannot merge %s into its descendant %s", source.Name, target.Name)
+			}
+		}
+
+		// 目標群組已有的同名設備與待審核設備優先保留
+		for _, model := range []interface{}{&entities.Device{}, &entities.DiscoveredDevice{}} {
+			var names []string
+			if err := tx.Model(model).Where("device_group = ?", target.Name).Pluck("name", &names).Error; err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				continue
+			}
+			if err := tx.Where("device_group = ? AND name IN ?", source.Name, names).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := moveDeviceGroupReferences(tx, source.Name, target.Name); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&entities.DeviceGroup{}).Where("parent_id = ?", source.ID).Update("parent_id", target.ID).Error; err != nil {
+			return err
+		}
+		target.Receivers = appendUnique(target.Receivers, source.Receivers...)
+		target.Tags = appendUnique(target.Tags, source.Tags...)
+		target.FormerNames = appendUnique(target.FormerNames, source.FormerNames...)
+		target.FormerNames = appendUnique(target.FormerNames, source.Name)
+		if err := tx.Model(&target).Select("receivers", "tags", "former_names").Updates(&target).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&source).Error
+	})
+	if err != nil {
+		res.Msg = fmt.Sprintf("Merge device groups Fail: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	result := remapDeviceGroupHistoryResult(target, source.Name)
+	reloadDeviceGroupSchedules(target.Name)
+	log.Logrecord_no_rotate("INFO", fmt.Sprintf("Device group %s merged into %s", source.Name, target.Name))
+
+	res.Success = true
+	res.Msg = "Merge device groups Success"
+	res.Body = result
+	return res
+}
+
+// RemapDeviceGroupHistory 依群組的 FormerNames 重新建立 TimescaleDB 中舊名稱的對應，用於改名或合併後對應失敗時重試
+func RemapDeviceGroupHistory(req entities.DeviceGroupRemapRequest) models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = entities.DeviceGroupChangeResult{}
+
+	var group entities.DeviceGroup
+	if err := global.Mysql.Where("id = ?", req.ID).First(&group).Error; err != nil {
+		res.Msg = "device group ID does not exist"
+		return res
+	}
+
+	result := remapDeviceGroupHistoryResult(group, group.FormerNames...)
+	if !result.HistoryRemapped {
+		res.Msg = result.Warning
+		res.Body = result
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Remap device group history Success"
+	res.Body = result
+	return res
+}
+
+// remapDeviceGroupHistoryResult 將 formerNames 對應到 group 目前的名稱，失敗時記錄並回傳警告
+func remapDeviceGroupHistoryResult(group entities.DeviceGroup, formerNames ...string) entities.DeviceGroupChangeResult {
+	result := entities.DeviceGroupChangeResult{Group: group, HistoryRemapped: true}
+	for _, name := range formerNames {
+		if err := remapDeviceGroupHistory(name, group.Name); err != nil {
+			result.HistoryRemapped = false
+			result.Warning = fmt.Sprintf("history recorded under %s is not queryable as %s until /DeviceGroup/RemapHistory succeeds: %s", name, group.Name, err.Error())
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to remap TimescaleDB history from %s to %s: %s", name, group.Name, err.Error()))
+			break
+		}
+	}
+	return result
+}
+
+// validateDeviceGroupName 名稱不可空白、超過 50 字，或與其他群組的名稱及舊名稱重複（避免歷史資料對應到兩個群組）
+func validateDeviceGroupName(db *gorm.DB, name string, id int) error {
+	if name == "" || len(name) > 50 {
+		return fmt.Errorf("device group name must be 1-50 characters")
+	}
+	var count int64
+	db.Model(&entities.DeviceGroup{}).Where("id <> ? AND (name = ? OR JSON_CONTAINS(former_names, JSON_QUOTE(?)))", id, name, name).Count(&count)
+	if count > 0 {
+		return fmt.Errorf("device group name %s is already used", name)
+	}
+	return nil
+}
+
+// validateDeviceGroupParent 上層群組必須存在，且不能是自己或自己的下層群組
+func validateDeviceGroupParent(group entities.DeviceGroup) error {
+	if group.ParentID == nil {
+		return nil
+	}
+	var parent entities.DeviceGroup
+	if err := global.Mysql.Where("id = ?", *group.ParentID).First(&parent).Error; err != nil {
+		return fmt.Errorf("parent device group %d does not exist", *group.ParentID)
+	}
+	if group.ID == 0 {
+		return nil
+	}
+	if parent.ID == group.ID {
+		return fmt.Errorf("device group cannot be its own parent")
+	}
+	for _, ancestor := range deviceGroupAncestors(global.Mysql, parent) {
+		if ancestor.ID == group.ID {
+			return fmt.Errorf("parent device group %s is a descendant of this group", parent.Name)
+		}
+	}
+	return nil
+}
+
+// moveDeviceGroupReferences 將所有參照 from 的資料改為 to（daily stats 同日期同日誌時合併數量）
+func moveDeviceGroupReferences(tx *gorm.DB, from, to string) error {
+	for _, table := range deviceGroupTables {
+		if err := tx.Table(table).Where("device_group = ?", from).Update("device_group", to).Error; err != nil {
+			return fmt.Errorf("update %s: %w", table, err)
+		}
+	}
+
+	err := tx.Model(&entities.MaintenanceWindow{}).
+		Where("scope_type = ? AND scope_value = ?", entities.MaintenanceScopeDeviceGroup, from).
+		Update("scope_value", to).Error
+	if err != nil {
+		return fmt.Errorf("update maintenance_windows: %w", err)
+	}
+
+	// 可用率與平均回應時間依檢查次數加權；MySQL 依序套用，比率需在次數相加前計算
+	err = tx.Exec(`
+		INSERT INTO history_daily_stats (
+			date, logname, device_group,
+			total_checks, online_count, offline_count, warning_count, error_count,
+			uptime_rate, avg_response_time, created_at, updated_at
+		)
+		SELECT date, logname, ?,
+			total_checks, online_count, offline_count, warning_count, error_count,
+			uptime_rate, avg_response_time, created_at, updated_at
+		FROM history_daily_stats
+		WHERE device_group = ?
+		ON DUPLICATE KEY UPDATE
+			uptime_rate = (uptime_rate * total_checks + VALUES(uptime_rate) * VALUES(total_checks)) / NULLIF(total_checks + VALUES(total_checks), 0),
+			avg_response_time = (avg_response_time * total_checks + VALUES(avg_response_time) * VALUES(total_checks)) / NULLIF(total_checks + VALUES(total_checks), 0),
+			total_checks = total_checks + VALUES(total_checks),
+			online_count = online_count + VALUES(online_count),
+			offline_count = offline_count + VALUES(offline_count),
+			warning_count = warning_count + VALUES(warning_count),
+			error_count = error_count + VALUES(error_count)
+	`, to, from).Error
+	if err != nil {
+		return fmt.Errorf("merge history_daily_stats: %w", err)
+	}
+	if err := tx.Exec("DELETE FROM history_daily_stats WHERE device_group = ?", from).Error; err != nil {
+		return fmt.Errorf("delete history_daily_stats: %w", err)
+	}
+	return nil
+}
+
+// remapDeviceGroupHistory 在 TimescaleDB 記錄舊名稱對應的群組，device_metrics 不需重寫即可以新名稱查詢
+func remapDeviceGroupHistory(from, to string) error {
+	if global.TimescaleDB == nil {
+		return nil
+	}
+	tx, err := global.TimescaleDB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		// to 成為目前的名稱，不再對應到其他群組
+		{`DELETE FROM device_group_names WHERE name = $1`, []any{to}},
+		{`UPDATE device_group_names SET group_name = $2 WHERE group_name = $1`, []any{from, to}},
+		{`INSERT INTO device_group_names (name, group_name) VALUES ($1, $2)
+			ON CONFLICT (name) DO UPDATE SET group_name = EXCLUDED.group_name`, []any{from, to}},
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// reloadDeviceGroupSchedules 重新排程使用該群組的 index，讓排程中的設定帶入新的群組名稱
+func reloadDeviceGroupSchedules(name string) {
+	var ids []int
+	if err := global.Mysql.Model(&entities.Index{}).Where("device_group = ?", name).Pluck("id", &ids).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to get indices of device group %s: %s", name, err.Error()))
+		return
+	}
+	for _, id := range ids {
+		Control_center_by_IndiceID(id)
+	}
+}
+
+// ensureDeviceGroups 建立尚未存在的群組（設備與 index 仍以名稱指定群組）
+func ensureDeviceGroups(db *gorm.DB, names ...string) error {
+	var groups []entities.DeviceGroup
+	seen := map[string]bool{}
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		groups = append(groups, entities.DeviceGroup{Name: name})
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&groups).Error
+}
+
+// deviceGroupAncestors 由近到遠回傳上層群組
+func deviceGroupAncestors(db *gorm.DB, group entities.DeviceGroup) []entities.DeviceGroup {
+	var ancestors []entities.DeviceGroup
+	visited := map[int]bool{group.ID: true}
+	for group.ParentID != nil && !visited[*group.ParentID] {
+		var parent entities.DeviceGroup
+		if err := db.Where("id = ?", *group.ParentID).First(&parent).Error; err != nil {
+			break
+		}
+		visited[parent.ID] = true
+		ancestors = append(ancestors, parent)
+		group = parent
+	}
+	return ancestors
+}
+
+// deviceGroupLineage 回傳群組本身與所有上層群組，群組未建立時只有名稱本身
+func deviceGroupLineage(name string) []entities.DeviceGroup {
+	var group entities.DeviceGroup
+	if err := global.Mysql.Where("name = ?", name).First(&group).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to get device group %s: %s", name, err.Error()))
+		}
+		return []entities.DeviceGroup{{Name: name}}
+	}
+	return append([]entities.DeviceGroup{group}, deviceGroupAncestors(global.Mysql, group)...)
+}
+
+// DeviceGroupReceivers 群組與上層群組的預設收件人
+func DeviceGroupReceivers(name string) []string {
+	var receivers []string
+	for _, group := range deviceGroupLineage(name) {
+		receivers = appendUnique(receivers, group.Receivers...)
+	}
+	return receivers
+}
+
+// deviceGroupTreeNames 群組本身與所有下層群組的名稱，用於歷史查詢；群組未建立時只有名稱本身
+func deviceGroupTreeNames(name string) []string {
+	var groups []entities.DeviceGroup
+	if err := global.Mysql.Select("id", "name", "parent_id").Find(&groups).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to get device groups: %s", err.Error()))
+		return []string{name}
+	}
+
+	children := map[int][]entities.DeviceGroup{}
+	root := -1
+	for _, group := range groups {
+		if group.ParentID != nil {
+			children[*group.ParentID] = append(children[*group.ParentID], group)
+		}
+		if group.Name == name {
+			root = group.ID
+		}
+	}
+
+	names := []string{name}
+	visited := map[int]bool{root: true}
+	queue := []int{root}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, child := range children[id] {
+			if visited[child.ID] {
+				continue
+			}
+			visited[child.ID] = true
+			names = append(names, child.Name)
+			queue = append(queue, child.ID)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// appendUnique 附加清單中尚未出現且非空白的值
+func appendUnique(list []string, values ...string) []string {
+	seen := map[string]bool{}
+	for _, value := range list {
+		seen[value] = true
+	}
+	for _, value := range values {
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		list = append(list, value)
+	}
+	return list
+}
+
+// removeGroupName 移除清單中的指定值
+func removeGroupName(list []string, value string) []string {
+	var kept []string
+	for _, item := range list {
+		if item != value {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,553 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// 匯入 / 匯出的檔案格式
+const (
+	DeviceFileCSV  = "csv"
+	DeviceFileXLSX = "xlsx"
+)
+
+// deviceColumns 匯入 / 匯出支援的欄位（依匯出順序）
+var deviceColumns = []string{"device_group", "name", "tags", "aliases", "owner", "site", "environment", "criticality", "status", "absent_until", "min_doc_count"}
+
+// deviceTimeLayouts absent_until 可接受的時間格式（依伺服器時區解析）
+var deviceTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// DeviceFileFormat 依副檔名判斷檔案格式
+func DeviceFileFormat(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return DeviceFileCSV, nil
+	case ".xlsx":
+		return DeviceFileXLSX, nil
+	}
+	return "", fmt.Errorf("unsupported file type: %s (use .csv or .xlsx)", filename)
+}
+
+// ImportDevices 匯入設備清單；有任何一列無效時不寫入，回傳檢查結果
+func ImportDevices(file io.Reader, format string, opts entities.DeviceImportOptions) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if opts.Mode == "" {
+		opts.Mode = entities.DeviceImportUpsert
+	}
+	if opts.Mode != entities.DeviceImportUpsert && opts.Mode != entities.DeviceImportReplaceGroup {
+		res.Msg = fmt.Sprintf("Invalid import mode: %s", opts.Mode)
+		return res
+	}
+
+	records, err := readDeviceFile(file, format, opts.Sheet)
+	if err != nil {
+		res.Msg = fmt.Sprintf("Failed to read file: %s", err.Error())
+		return res
+	}
+	if len(records) == 0 {
+		res.Msg = "File is empty"
+		return res
+	}
+
+	columns, err := mapDeviceColumns(records[0], opts.Mapping)
+	if err != nil {
+		res.Msg = err.Error()
+		return res
+	}
+	if _, ok := columns["device_group"]; !ok && opts.DeviceGroup == "" {
+		res.Msg = "device_group column or device_group parameter is required"
+		return res
+	}
+
+	report := entities.DeviceImportReport{
+		Mode:          opts.Mode,
+		Preview:       opts.Preview,
+		Columns:       map[string]string{},
+		Duplicates:    []string{},
+		UnknownGroups: []string{},
+		Removed:       []entities.Device{},
+	}
+	for field, index := range columns {
+		report.Columns[field] = records[0][index]
+	}
+
+	rows := parseDeviceRows(records[1:], columns, opts.DeviceGroup)
+	report.Total = len(rows)
+
+	if err := planDeviceImport(&report, rows, opts.Mode, columns); err != nil {
+		res.Msg = fmt.Sprintf("Failed to check devices: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	if report.Invalid > 0 {
+		res.Msg = fmt.Sprintf("%d invalid rows, nothing imported", report.Invalid)
+		res.Body = report
+		return res
+	}
+
+	if !opts.Preview {
+		if err := applyDeviceImport(report, columns); err != nil {
+			res.Msg = fmt.Sprintf("Import devices Fail: %s", err.Error())
+			log.Logrecord_no_rotate("ERROR", res.Msg)
+			return res
+		}
+		log.Logrecord_no_rotate("INFO", fmt.Sprintf("Devices imported (%s): %d created, %d updated, %d deleted", opts.Mode, report.Created, report.Updated, report.Deleted))
+	}
+
+	res.Success = true
+	res.Msg = "Import devices success"
+	res.Body = report
+	return res
+}
+
+// readDeviceFile 讀取 CSV 或 XLSX 的所有列
+func readDeviceFile(file io.Reader, format string, sheet string) ([][]string, error) {
+	switch format {
+	case DeviceFileCSV:
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		// Excel 另存的 CSV 會帶 UTF-8 BOM
+		if len(records) > 0 && len(records[0]) > 0 {
+			records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+		}
+		return records, nil
+	case DeviceFileXLSX:
+		workbook, err := excelize.OpenReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer workbook.Close()
+		if sheet == "" {
+			sheet = workbook.GetSheetName(0)
+		}
+		return workbook.GetRows(sheet)
+	}
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+// mapDeviceColumns 依 mapping（設備欄位 -> 檔案標題）或欄位名稱找出各欄位的位置
+func mapDeviceColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	positions := map[string]int{}
+	for i, title := range header {
+		positions[strings.ToLower(strings.TrimSpace(title))] = i
+	}
+
+	supported := map[string]bool{}
+	for _, field := range deviceColumns {
+		supported[field] = true
+	}
+	for field := range mapping {
+		if !supported[field] {
+			return nil, fmt.Errorf("unknown device field in mapping: %s", field)
+		}
+	}
+
+	columns := map[string]int{}
+	for _, field := range deviceColumns {
+		title := field
+		if mapped, ok := mapping[field]; ok {
+			title = mapped
+		}
+		index, ok := positions[strings.ToLower(strings.TrimSpace(title))]
+		if !ok {
+			if _, mapped := mapping[field]; mapped {
+				return nil, fmt.Errorf("column %q mapped to %s not found", title, field)
+			}
+			continue
+		}
+		columns[field] = index
+	}
+
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("name column is required")
+	}
+	return columns, nil
+}
+
+// parseDeviceRows 將每一列轉為設備並檢查欄位格式
+func parseDeviceRows(records [][]string, columns map[string]int, defaultGroup string) []entities.DeviceImportRow {
+	var rows []entities.DeviceImportRow
+	for i, record := range records {
+		value := func(field string) string {
+			index, ok := columns[field]
+			if !ok || index >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[index])
+		}
+
+		// 略過空白列
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row := entities.DeviceImportRow{Row: i + 2}
+		device := entities.Device{
+			DeviceGroup: value("device_group"),
+			Name:        value("name"),
+			Owner:       value("owner"),
+			Site:        value("site"),
+			Environment: value("environment"),
+			Criticality: strings.ToLower(value("criticality")),
+			Status:      strings.ToLower(value("status")),
+			Tags:        splitDeviceTags(value("tags")),
+			Aliases:     splitDeviceTags(value("aliases")),
+		}
+		if device.DeviceGroup == "" {
+			device.DeviceGroup = defaultGroup
+		}
+		if device.Status == "" {
+			device.Status = entities.DeviceActive
+		}
+
+		if device.Name == "" {
+			row.Errors = append(row.Errors, "name is required")
+		}
+		if device.DeviceGroup == "" {
+			row.Errors = append(row.Errors, "device_group is required")
+		}
+		if len(device.Name) > 50 || len(device.DeviceGroup) > 50 {
+			row.Errors = append(row.Errors, "name and device_group must be at most 50 characters")
+		}
+		if err := device.Validate(); err != nil {
+			row.Errors = append(row.Errors, err.Error())
+		}
+
+		if raw := value("min_doc_count"); raw != "" {
+			count, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || count < 0 {
+				row.Errors = append(row.Errors, fmt.Sprintf("invalid min_doc_count: %s", raw))
+			} else {
+				device.MinDocCount = &count
+			}
+		}
+
+		switch device.Status {
+		case entities.DeviceActive, entities.DeviceDecommissioned:
+		case entities.DeviceExpectedAbsent:
+			raw := value("absent_until")
+			until, err := parseDeviceTime(raw)
+			if err != nil {
+				row.Errors = append(row.Errors, fmt.Sprintf("expected_absent requires a valid absent_until: %q", raw))
+			} else {
+				unix := until.Unix()
+				device.AbsentUntil = &unix
+			}
+		default:
+			row.Errors = append(row.Errors, fmt.Sprintf("invalid status: %s", device.Status))
+		}
+
+		row.Device = device
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// planDeviceImport 比對資料庫，決定每一列的動作並整理重複、別名衝突與未知群組
+func planDeviceImport(report *entities.DeviceImportReport, rows []entities.DeviceImportRow, mode string, columns map[string]int) error {
+	groups := map[string]bool{}
+	for _, row := range rows {
+		if row.Device.DeviceGroup != "" {
+			groups[row.Device.DeviceGroup] = true
+		}
+	}
+	var groupNames []string
+	for group := range groups {
+		groupNames = append(groupNames, group)
+	}
+	sort.Strings(groupNames)
+
+	existing := map[string]entities.Device{}
+	if len(groupNames) > 0 {
+		var devices []entities.Device
+		if err := global.Mysql.Where("device_group IN ?", groupNames).Find(&devices).Error; err != nil {
+			return err
+		}
+		for _, device := range devices {
+			existing[device.DeviceGroup+"/"+device.Name] = device
+		}
+	}
+
+	// 已知群組：device_groups 中已建立的群組
+	known := map[string]bool{}
+	var existingGroups []string
+	if err := global.Mysql.Model(&entities.DeviceGroup{}).Pluck("name", &existingGroups).Error; err != nil {
+		return err
+	}
+	for _, group := range existingGroups {
+		known[group] = true
+	}
+	for _, group := range groupNames {
+		if !known[group] {
+			report.UnknownGroups = append(report.UnknownGroups, group)
+		}
+	}
+
+	_, hasAliases := columns["aliases"]
+	checkImportAliases(rows, existing, mode == entities.DeviceImportReplaceGroup, hasAliases)
+
+	seen := map[string]int{}
+	listed := map[string]bool{}
+	for i := range rows {
+		row := &rows[i]
+		key := row.Device.DeviceGroup + "/" + row.Device.Name
+
+		if first, ok := seen[key]; ok && row.Device.Name != "" {
+			row.Errors = append(row.Errors, fmt.Sprintf("duplicate of row %d", first))
+			report.Duplicates = append(report.Duplicates, key)
+		} else {
+			seen[key] = row.Row
+		}
+		if row.Device.DeviceGroup != "" && !known[row.Device.DeviceGroup] {
+			row.Warnings = append(row.Warnings, fmt.Sprintf("device group %s does not exist and will be created", row.Device.DeviceGroup))
+		}
+
+		switch {
+		case len(row.Errors) > 0:
+			row.Action = "invalid"
+			report.Invalid++
+		case existing[key].ID != 0:
+			row.Action = "update"
+			row.Device.ID = existing[key].ID
+			report.Updated++
+		default:
+			row.Action = "create"
+			report.Created++
+		}
+		listed[key] = true
+	}
+
+	if mode == entities.DeviceImportReplaceGroup {
+		for key, device := range existing {
+			if !listed[key] {
+				report.Removed = append(report.Removed, device)
+			}
+		}
+		sort.Slice(report.Removed, func(i, j int) bool { return report.Removed[i].ID < report.Removed[j].ID })
+		report.Deleted = len(report.Removed)
+	}
+
+	report.Rows = rows
+	return nil
+}
+
+// checkImportAliases 檢查別名是否與匯入後同群組其他設備的名稱或別名重複（與 checkDeviceAliases 相同規則），
+// 比對對象包含檔案中的其他列與保留下來的既有設備；檔案沒有 aliases 欄位時，更新的設備沿用原本的別名
+func checkImportAliases(rows []entities.DeviceImportRow, existing map[string]entities.Device, replace, hasAliases bool) {
+	listed := map[string]bool{}
+	used := map[string]map[string]string{} // 群組 -> 名稱或別名 -> 設備名稱
+	use := func(group, value, owner string) {
+		if used[group] == nil {
+			used[group] = map[string]string{}
+		}
+		used[group][value] = owner
+	}
+	for _, row := range rows {
+		if row.Device.Name == "" {
+			continue
+		}
+		listed[row.Device.DeviceGroup+"/"+row.Device.Name] = true
+		use(row.Device.DeviceGroup, row.Device.Name, row.Device.Name)
+	}
+
+	keys := make([]string, 0, len(existing))
+	for key := range existing {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		device := existing[key]
+		if listed[key] {
+			if hasAliases {
+				continue
+			}
+		} else if replace {
+			// 取代模式下檔案中沒有的設備會被刪除
+			continue
+		} else {
+			use(device.DeviceGroup, device.Name, device.Name)
+		}
+		for _, alias := range device.Aliases {
+			use(device.DeviceGroup, alias, device.Name)
+		}
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		group := row.Device.DeviceGroup
+		for _, alias := range row.Device.Aliases {
+			if owner, ok := used[group][alias]; ok && owner != row.Device.Name {
+				row.Errors = append(row.Errors, fmt.Sprintf("alias %s is already used by device %s", alias, owner))
+				continue
+			}
+			use(group, alias, row.Device.Name)
+		}
+	}
+}
+
+// applyDeviceImport 在同一個交易中寫入檢查結果；更新時只覆寫檔案中有的欄位
+func applyDeviceImport(report entities.DeviceImportReport, columns map[string]int) error {
+	// absent_until 只隨 status 一起更新（狀態不是 expected_absent 時清除）
+	_, hasStatus := columns["status"]
+	var updateColumns []string
+	for _, field := range deviceColumns {
+		switch field {
+		case "name", "device_group", "status", "absent_until":
+			continue
+		}
+		if _, ok := columns[field]; ok {
+			updateColumns = append(updateColumns, field)
+		}
+	}
+	if hasStatus {
+		updateColumns = append(updateColumns, "status", "absent_until")
+	}
+
+	return global.Mysql.Transaction(func(tx *gorm.DB) error {
+		if err := ensureDeviceGroups(tx, report.UnknownGroups...); err != nil {
+			return err
+		}
+		for _, row := range report.Rows {
+			device := row.Device
+			switch row.Action {
+			case "create":
+				if err := tx.Create(&device).Error; err != nil {
+					return fmt.Errorf("row %d: %w", row.Row, err)
+				}
+			case "update":
+				if len(updateColumns) == 0 {
+					continue
+				}
+				if err := tx.Model(&entities.Device{}).Where("id = ?", device.ID).Select(updateColumns).Updates(&device).Error; err != nil {
+					return fmt.Errorf("row %d: %w", row.Row, err)
+				}
+			}
+		}
+
+		if len(report.Removed) > 0 {
+			var ids []int
+			for _, device := range report.Removed {
+				ids = append(ids, device.ID)
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&entities.Device{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ExportDevices 依篩選條件匯出設備清單
+func ExportDevices(filter entities.DeviceFilter, format string) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	var devices []entities.Device
+	if err := filterDevices(global.Mysql, filter).Order("device_group, name").Find(&devices).Error; err != nil {
+		res.Msg = fmt.Sprintf("Error Get All Devices: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	records := [][]string{deviceColumns}
+	for _, device := range devices {
+		absentUntil := ""
+		if device.AbsentUntil != nil {
+			absentUntil = time.Unix(*device.AbsentUntil, 0).Format(deviceTimeLayouts[0])
+		}
+		minDocCount := ""
+		if device.MinDocCount != nil {
+			minDocCount = strconv.FormatInt(*device.MinDocCount, 10)
+		}
+		status := device.Status
+		if status == "" {
+			status = entities.DeviceActive
+		}
+		records = append(records, []string{
+			device.DeviceGroup, device.Name, strings.Join(device.Tags, ","), strings.Join(device.Aliases, ","), device.Owner, device.Site,
+			device.Environment, device.Criticality, status, absentUntil, minDocCount,
+		})
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case DeviceFileCSV:
+		// 加上 BOM 讓 Excel 正確辨識 UTF-8
+		buf.WriteString("\ufeff")
+		writer := csv.NewWriter(&buf)
+		if err := writer.WriteAll(records); err != nil {
+			res.Msg = fmt.Sprintf("Failed to write csv: %s", err.Error())
+			return res
+		}
+	case DeviceFileXLSX:
+		workbook := excelize.NewFile()
+		defer workbook.Close()
+		sheet := workbook.GetSheetName(0)
+		for i, record := range records {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			row := make([]interface{}, len(record))
+			for j, value := range record {
+				row[j] = value
+			}
+			if err := workbook.SetSheetRow(sheet, cell, &row); err != nil {
+				res.Msg = fmt.Sprintf("Failed to write xlsx: %s", err.Error())
+				return res
+			}
+		}
+		if err := workbook.Write(&buf); err != nil {
+			res.Msg = fmt.Sprintf("Failed to write xlsx: %s", err.Error())
+			return res
+		}
+	default:
+		res.Msg = fmt.Sprintf("Unsupported export format: %s", format)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Export devices success"
+	res.Body = buf.Bytes()
+	return res
+}
+
+// splitDeviceTags 標籤與別名以逗號或分號分隔
+func splitDeviceTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseDeviceTime 解析匯入檔案中的時間
+func parseDeviceTime(value string) (time.Time, error) {
+	for _, layout := range deviceTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", value)
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"bytes"
+	"log-detect/entities"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestDeviceFileFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+		wantErr  bool
+	}{
+		{filename: "devices.csv", want: DeviceFileCSV},
+		{filename: "Devices.XLSX", want: DeviceFileXLSX},
+		{filename: "devices.xls", wantErr: true},
+		{filename: "devices", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := DeviceFileFormat(tt.filename)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("DeviceFileFormat(%q) = %q, %v", tt.filename, got, err)
+		}
+	}
+}
+
+func TestReadDeviceFile(t *testing.T) {
+	want := [][]string{{"name", "device_group"}, {"fw01", "firewall"}, {"fw02", "firewall"}}
+
+	t.Run("csv with BOM", func(t *testing.T) {
+		csv := "\uFEFFname, device_group\nfw01,firewall\nfw02,firewall\n"
+		got, err := readDeviceFile(strings.NewReader(csv), DeviceFileCSV, "")
+		if err != nil {
+			t.Fatalf("readDeviceFile: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("records = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		workbook := excelize.NewFile()
+		defer workbook.Close()
+		if _, err := workbook.NewSheet("Devices"); err != nil {
+			t.Fatal(err)
+		}
+		for i, row := range want {
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := workbook.SetSheetRow("Devices", cell, &row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var buf bytes.Buffer
+		if err := workbook.Write(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := readDeviceFile(bytes.NewReader(buf.Bytes()), DeviceFileXLSX, "Devices")
+		if err != nil {
+			t.Fatalf("readDeviceFile: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("records = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		if _, err := readDeviceFile(strings.NewReader(""), "xls", ""); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestMapDeviceColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []string
+		mapping map[string]string
+		want    map[string]int
+		wantErr bool
+	}{
+		{
+			name:   "field names, case insensitive",
+			header: []string{" Name ", "DEVICE_GROUP", "unrelated", "tags"},
+			want:   map[string]int{"name": 0, "device_group": 1, "tags": 3},
+		},
+		{
+			name:    "custom mapping",
+			header:  []string{"Hostname", "Group", "Contact"},
+			mapping: map[string]string{"name": "hostname", "device_group": "Group", "owner": "Contact"},
+			want:    map[string]int{"name": 0, "device_group": 1, "owner": 2},
+		},
+		{name: "name required", header: []string{"device_group"}, wantErr: true},
+		{name: "unknown mapped field", header: []string{"name"}, mapping: map[string]string{"serial": "SN"}, wantErr: true},
+		{name: "mapped title missing", header: []string{"name"}, mapping: map[string]string{"owner": "Contact"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mapDeviceColumns(tt.header, tt.mapping)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("mapDeviceColumns() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mapDeviceColumns() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mapDeviceColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDeviceRows(t *testing.T) {
+	header := []string{"device_group", "name", "tags", "aliases", "owner", "criticality", "status", "absent_until", "min_doc_count"}
+	columns, err := mapDeviceColumns(header, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	absent, _ := time.ParseInLocation("2006-01-02 15:04", "2026-12-01 08:30", time.Local)
+
+	tests := []struct {
+		name      string
+		record    []string
+		wantErr   bool
+		check     func(t *testing.T, device entities.Device)
+		defGroup  string
+		skipEmpty bool
+	}{
+		{
+			name:   "full row",
+			record: []string{"firewall", "fw01", "edge; dmz,", "fw01.corp.local,10.0.0.1", "ops@example.com", "Critical", "", "", "100"},
+			check: func(t *testing.T, d entities.Device) {
+				if d.Name != "fw01" || d.DeviceGroup != "firewall" || d.Criticality != "critical" || d.Status != entities.DeviceActive {
+					t.Errorf("device = %+v", d)
+				}
+				if !reflect.DeepEqual(d.Tags, []string{"edge", "dmz"}) || !reflect.DeepEqual(d.Aliases, []string{"fw01.corp.local", "10.0.0.1"}) {
+					t.Errorf("tags = %v, aliases = %v", d.Tags, d.Aliases)
+				}
+				if d.MinDocCount == nil || *d.MinDocCount != 100 {
+					t.Errorf("min_doc_count = %v", d.MinDocCount)
+				}
+			},
+		},
+		{
+			name:     "default group and short row",
+			record:   []string{"", "sw01"},
+			defGroup: "switch",
+			check: func(t *testing.T, d entities.Device) {
+				if d.DeviceGroup != "switch" || d.MinDocCount != nil {
+					t.Errorf("device = %+v", d)
+				}
+			},
+		},
+		{
+			name:   "expected absent",
+			record: []string{"firewall", "fw02", "", "", "", "", "Expected_Absent", "2026-12-01 08:30", ""},
+			check: func(t *testing.T, d entities.Device) {
+				if d.Status != entities.DeviceExpectedAbsent || d.AbsentUntil == nil || *d.AbsentUntil != absent.Unix() {
+					t.Errorf("status = %s, absent_until = %v", d.Status, d.AbsentUntil)
+				}
+			},
+		},
+		{name: "missing name", record: []string{"firewall", "", "", "", "", "", "", "", ""}, wantErr: true},
+		{name: "missing group", record: []string{"", "fw03"}, wantErr: true},
+		{name: "invalid owner", record: []string{"firewall", "fw04", "", "", "not-an-email"}, wantErr: true},
+		{name: "invalid criticality", record: []string{"firewall", "fw05", "", "", "", "urgent"}, wantErr: true},
+		{name: "alias equals name", record: []string{"firewall", "fw06", "", "fw06"}, wantErr: true},
+		{name: "invalid status", record: []string{"firewall", "fw07", "", "", "", "", "retired"}, wantErr: true},
+		{name: "absent without time", record: []string{"firewall", "fw08", "", "", "", "", "expected_absent", ""}, wantErr: true},
+		{name: "negative min_doc_count", record: []string{"firewall", "fw09", "", "", "", "", "", "", "-1"}, wantErr: true},
+		{name: "blank row skipped", record: []string{" ", "", ""}, skipEmpty: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := parseDeviceRows([][]string{tt.record}, columns, tt.defGroup)
+			if tt.skipEmpty {
+				if len(rows) != 0 {
+					t.Fatalf("rows = %+v, want blank row skipped", rows)
+				}
+				return
+			}
+			if len(rows) != 1 {
+				t.Fatalf("got %d rows, want 1", len(rows))
+			}
+			row := rows[0]
+			if row.Row != 2 {
+				t.Errorf("row number = %d, want 2 (after the header)", row.Row)
+			}
+			if (len(row.Errors) > 0) != tt.wantErr {
+				t.Fatalf("errors = %v, want error %v", row.Errors, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, row.Device)
+			}
+		})
+	}
+}
+
+func TestCheckImportAliases(t *testing.T) {
+	row := func(name string, aliases ...string) entities.DeviceImportRow {
+		return entities.DeviceImportRow{Device: entities.Device{DeviceGroup: "firewall", Name: name, Aliases: aliases}}
+	}
+	existing := map[string]entities.Device{
+		"firewall/fw01": {ID: 1, DeviceGroup: "firewall", Name: "fw01", Aliases: []string{"10.0.0.1"}},
+		"firewall/fw02": {ID: 2, DeviceGroup: "firewall", Name: "fw02", Aliases: []string{"edge-fw"}},
+		"switch/sw01":   {ID: 3, DeviceGroup: "switch", Name: "sw01", Aliases: []string{"core"}},
+	}
+
+	tests := []struct {
+		name       string
+		rows       []entities.DeviceImportRow
+		replace    bool
+		hasAliases bool
+		wantErrors []int // 有錯誤的列（rows 的位置）
+	}{
+		{name: "no conflicts", rows: []entities.DeviceImportRow{row("fw03", "10.0.0.3"), row("fw04", "core")}, hasAliases: true},
+		{name: "alias of another row", rows: []entities.DeviceImportRow{row("fw03", "shared"), row("fw04", "shared")}, hasAliases: true, wantErrors: []int{1}},
+		{name: "alias equals another row name", rows: []entities.DeviceImportRow{row("fw03", "fw04"), row("fw04")}, hasAliases: true, wantErrors: []int{0}},
+		{name: "alias of existing device", rows: []entities.DeviceImportRow{row("fw03", "edge-fw")}, hasAliases: true, wantErrors: []int{0}},
+		{name: "alias equals existing device name", rows: []entities.DeviceImportRow{row("fw03", "fw01")}, hasAliases: true, wantErrors: []int{0}},
+		{
+			// 檔案中的 fw01 以新的別名取代原本的 10.0.0.1
+			name:       "updated device releases old alias",
+			rows:       []entities.DeviceImportRow{row("fw01", "fw01.corp"), row("fw03", "10.0.0.1")},
+			hasAliases: true,
+		},
+		{
+			name: "updated device keeps aliases without aliases column",
+			rows: []entities.DeviceImportRow{row("fw01"), row("fw03")},
+		},
+		{
+			// 取代模式下 fw02 會被刪除，別名可以沿用
+			name:       "replace mode drops unlisted devices",
+			rows:       []entities.DeviceImportRow{row("fw01"), row("fw03", "edge-fw", "fw02")},
+			replace:    true,
+			hasAliases: true,
+		},
+		{name: "own alias of existing device", rows: []entities.DeviceImportRow{row("fw02", "edge-fw")}, hasAliases: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkImportAliases(tt.rows, existing, tt.replace, tt.hasAliases)
+			var got []int
+			for i, row := range tt.rows {
+				if len(row.Errors) > 0 {
+					got = append(got, i)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantErrors) {
+				t.Errorf("rows with errors = %v, want %v (%+v)", got, tt.wantErrors, tt.rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+	"log-detect/entities"
+
+	"gorm.io/gorm"
+)
+
+// filterDevices 套用設備列表的篩選條件
+func filterDevices(db *gorm.DB, filter entities.DeviceFilter) *gorm.DB {
+	if filter.Q != "" {
+		db = db.Where("name LIKE ?", "%"+filter.Q+"%")
+	}
+	if filter.DeviceGroup != "" {
+		db = db.Where("device_group = ?", filter.DeviceGroup)
+	}
+	if filter.Tag != "" {
+		db = db.Where("JSON_CONTAINS(tags, JSON_QUOTE(?))", filter.Tag)
+	}
+	if filter.Owner != "" {
+		db = db.Where("owner = ?", filter.Owner)
+	}
+	if filter.Site != "" {
+		db = db.Where("site = ?", filter.Site)
+	}
+	if filter.Environment != "" {
+		db = db.Where("environment = ?", filter.Environment)
+	}
+	if filter.Criticality != "" {
+		db = db.Where("criticality = ?", filter.Criticality)
+	}
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	return db
+}
+
+// notificationDevice 轉為通知中的設備屬性，未登錄的設備只有名稱
+func notificationDevice(name string, devices map[string]entities.Device) NotificationDevice {
+	device, ok := devices[name]
+	if !ok {
+		return NotificationDevice{Name: name}
+	}
+	return NotificationDevice{
+		Name:        name,
+		Owner:       device.Owner,
+		Site:        device.Site,
+		Environment: device.Environment,
+		Criticality: device.Criticality,
+		Tags:        device.Tags,
+	}
+}
+
+// devicesByName 以設備名稱建立對照表
+func devicesByName(devices []entities.Device) map[string]entities.Device {
+	byName := make(map[string]entities.Device, len(devices))
+	for _, device := range devices {
+		byName[device.Name] = device
+	}
+	return byName
+}
+
+// RouteDeviceNotification 依設備重要性拆分通知：critical 設備另發 critical 等級的通知，
+// low 設備併入摘要，其餘維持原本等級立即發送；names 與 n.Items 一一對應
+func RouteDeviceNotification(n Notification, names []string, devices map[string]entities.Device) (immediate []Notification, digest Notification) {
+	critical, normal := n, n
+	digest = n
+	for _, part := range []*Notification{&critical, &normal, &digest} {
+		part.Items, part.Devices = nil, nil
+	}
+
+	for i, item := range n.Items {
+		info := NotificationDevice{}
+		if i < len(names) {
+			info = notificationDevice(names[i], devices)
+		}
+		switch info.Criticality {
+		case entities.DeviceCriticalityCritical:
+			critical.Items = append(critical.Items, item)
+			critical.Devices = append(critical.Devices, info)
+		case entities.DeviceCriticalityLow:
+			digest.Items = append(digest.Items, item)
+			digest.Devices = append(digest.Devices, info)
+		default:
+			normal.Items = append(normal.Items, item)
+			normal.Devices = append(normal.Devices, info)
+		}
+	}
+
+	if len(critical.Items) > 0 {
+		critical.Subject = fmt.Sprintf("[緊急] %s", n.Subject)
+		critical.Severity = entities.DeviceCriticalityCritical
+		immediate = append(immediate, critical)
+	}
+	if len(normal.Items) > 0 {
+		immediate = append(immediate, normal)
+	}
+	return immediate, digest
+}
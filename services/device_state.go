@@ -0,0 +1,180 @@
+package services
+
+import (
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"time"
+)
+
+// DeviceEvaluation 依容忍度與抖動設定分類後的設備
+type DeviceEvaluation struct {
+	Online   []string       // 有資料且未抖動
+	Pending  []string       // 缺失但尚未達到連續缺失門檻
+	Offline  []string       // 連續缺失達門檻，視為離線
+	Flapping []string       // 視窗內切換過於頻繁，標記為 warning
+	Misses   map[string]int // 每台缺失設備目前的連續缺失次數
+}
+
+// DeviceStateService 維護每台設備的連續缺失次數與狀態切換紀錄
+type DeviceStateService struct {
+	DryRun bool // 只依目前狀態計算分類結果，不寫回資料庫
+}
+
+// NewDeviceStateService 建立設備狀態服務
+func NewDeviceStateService() *DeviceStateService {
+	return &DeviceStateService{}
+}
+
+// Evaluate 更新本次檢查結果並依 index 設定分類設備
+func (s *DeviceStateService) Evaluate(target entities.Target, index entities.Index, present []string, missing []string, checkTime time.Time) DeviceEvaluation {
+	eval := DeviceEvaluation{Misses: map[string]int{}}
+
+	states, err := s.loadStates(target.ID, index.ID)
+	if err != nil {
+		// 狀態讀取失敗時退回原本「缺失即離線」的行為
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to load device states for %s: %s", index.Logname, err.Error()))
+		eval.Online = present
+		eval.Offline = missing
+		return eval
+	}
+
+	eval = classifyDevices(states, target, index, present, missing, checkTime)
+	if !s.DryRun {
+		s.saveStates(present, missing, states)
+	}
+	return eval
+}
+
+// classifyDevices 以本次檢查結果更新 states（連續缺失次數與視窗內的切換紀錄）並分類設備
+func classifyDevices(states map[string]*entities.DeviceState, target entities.Target, index entities.Index, present []string, missing []string, checkTime time.Time) DeviceEvaluation {
+	eval := DeviceEvaluation{Misses: map[string]int{}}
+
+	missThreshold := index.MissThreshold
+	if missThreshold < 1 {
+		missThreshold = 1
+	}
+	flapEnabled := index.FlapWindow > 0 && index.FlapThreshold > 0
+	windowStart := checkTime.Add(-time.Duration(index.FlapWindow) * time.Minute).Unix()
+	now := checkTime.Unix()
+
+	update := func(name string, seen bool) *entities.DeviceState {
+		state, ok := states[name]
+		if !ok {
+			// 第一次出現的設備不算切換
+			state = &entities.DeviceState{TargetID: target.ID, IndexID: index.ID, DeviceName: name, Present: seen}
+			states[name] = state
+		}
+		if state.Present != seen {
+			state.Transitions = append(state.Transitions, now)
+		}
+		state.Present = seen
+		state.LastCheckedAt = now
+		if seen {
+			state.ConsecutiveMisses = 0
+		} else {
+			state.ConsecutiveMisses++
+		}
+
+		// 只保留抖動視窗內的切換紀錄
+		var kept []int64
+		if flapEnabled {
+			for _, t := range state.Transitions {
+				if t >= windowStart {
+					kept = append(kept, t)
+				}
+			}
+		}
+		state.Transitions = kept
+		return state
+	}
+
+	for _, name := range present {
+		state := update(name, true)
+		if flapEnabled && len(state.Transitions) >= index.FlapThreshold {
+			eval.Flapping = append(eval.Flapping, name)
+			continue
+		}
+		eval.Online = append(eval.Online, name)
+	}
+
+	for _, name := range missing {
+		state := update(name, false)
+		eval.Misses[name] = state.ConsecutiveMisses
+		if flapEnabled && len(state.Transitions) >= index.FlapThreshold {
+			eval.Flapping = append(eval.Flapping, name)
+			continue
+		}
+		if state.ConsecutiveMisses < missThreshold {
+			eval.Pending = append(eval.Pending, name)
+			continue
+		}
+		eval.Offline = append(eval.Offline, name)
+	}
+	return eval
+}
+
+// MarkPresent 重新檢查發現資料延遲到達時，將設備改回有資料、清除連續缺失次數，並移除 checkTime 那次誤判造成的狀態切換
+func (s *DeviceStateService) MarkPresent(targetID int, indexID int, devices []string, checkTime time.Time) {
+	if len(devices) == 0 {
+		return
+	}
+	states, err := s.loadStates(targetID, indexID)
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to load device states for index %d: %s", indexID, err.Error()))
+		return
+	}
+
+	var updated []string
+	for _, name := range devices {
+		state, ok := states[name]
+		if !ok || state.LastCheckedAt != checkTime.Unix() {
+			// 之後的檢查已經更新過狀態，以較新的結果為準
+			continue
+		}
+		var kept []int64
+		for _, t := range state.Transitions {
+			if t != checkTime.Unix() {
+				kept = append(kept, t)
+			}
+		}
+		state.Transitions = kept
+		state.Present = true
+		state.ConsecutiveMisses = 0
+		updated = append(updated, name)
+	}
+	s.saveStates(updated, nil, states)
+}
+
+// ResetStates 刪除 index 對應的設備狀態（index 刪除時呼叫）
+func (s *DeviceStateService) ResetStates(indexID int) {
+	if err := global.Mysql.Where("index_id = ?", indexID).Delete(&entities.DeviceState{}).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to delete device states for index %d: %s", indexID, err.Error()))
+	}
+}
+
+// loadStates 讀取指定 target/index 的所有設備狀態
+func (s *DeviceStateService) loadStates(targetID int, indexID int) (map[string]*entities.DeviceState, error) {
+	var rows []entities.DeviceState
+	if err := global.Mysql.Where("target_id = ? AND index_id = ?", targetID, indexID).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]*entities.DeviceState, len(rows))
+	for i := range rows {
+		states[rows[i].DeviceName] = &rows[i]
+	}
+	return states, nil
+}
+
+// saveStates 寫回本次檢查到的設備狀態
+func (s *DeviceStateService) saveStates(present []string, missing []string, states map[string]*entities.DeviceState) {
+	for _, names := range [][]string{present, missing} {
+		for _, name := range names {
+			if err := global.Mysql.Save(states[name]).Error; err != nil {
+				log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to save device state for %s: %s", name, err.Error()))
+			}
+		}
+	}
+}
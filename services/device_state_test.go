@@ -0,0 +1,102 @@
+package services
+
+import (
+	"log-detect/entities"
+	"testing"
+	"time"
+)
+
+func TestClassifyDevices(t *testing.T) {
+	type step struct {
+		seen  bool   // host-a 本次是否有資料
+		want  string // online, pending, offline, flapping
+		miss  int    // 缺失時預期的連續缺失次數
+		after time.Duration
+	}
+	tests := []struct {
+		name  string
+		index entities.Index
+		steps []step
+	}{
+		{
+			name:  "immediate alert by default",
+			index: entities.Index{MissThreshold: 0},
+			steps: []step{{seen: true, want: "online"}, {seen: false, want: "offline", miss: 1}},
+		},
+		{
+			name:  "miss threshold",
+			index: entities.Index{MissThreshold: 3},
+			steps: []step{
+				{seen: true, want: "online"},
+				{seen: false, want: "pending", miss: 1},
+				{seen: false, want: "pending", miss: 2},
+				{seen: false, want: "offline", miss: 3},
+				{seen: false, want: "offline", miss: 4},
+			},
+		},
+		{
+			name:  "presence resets misses",
+			index: entities.Index{MissThreshold: 2},
+			steps: []step{
+				{seen: false, want: "pending", miss: 1},
+				{seen: true, want: "online"},
+				{seen: false, want: "pending", miss: 1},
+				{seen: false, want: "offline", miss: 2},
+			},
+		},
+		{
+			name:  "flapping within window",
+			index: entities.Index{MissThreshold: 1, FlapWindow: 30, FlapThreshold: 3},
+			steps: []step{
+				{seen: true, want: "online"},
+				{seen: false, want: "offline", miss: 1, after: 5 * time.Minute},
+				{seen: true, want: "online", after: 5 * time.Minute},
+				{seen: false, want: "flapping", miss: 1, after: 5 * time.Minute},
+				{seen: true, want: "flapping", after: 5 * time.Minute},
+			},
+		},
+		{
+			name:  "transitions outside window expire",
+			index: entities.Index{MissThreshold: 1, FlapWindow: 10, FlapThreshold: 3},
+			steps: []step{
+				{seen: true, want: "online"},
+				{seen: false, want: "offline", miss: 1, after: 5 * time.Minute},
+				{seen: true, want: "online", after: 5 * time.Minute},
+				{seen: false, want: "offline", miss: 1, after: 20 * time.Minute},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			states := map[string]*entities.DeviceState{}
+			target := entities.Target{ID: 1}
+			tt.index.ID = 2
+			now := time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC)
+
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				var present, missing []string
+				if s.seen {
+					present = []string{"host-a"}
+				} else {
+					missing = []string{"host-a"}
+				}
+
+				eval := classifyDevices(states, target, tt.index, present, missing, now)
+				got := ""
+				for class, list := range map[string][]string{"online": eval.Online, "pending": eval.Pending, "offline": eval.Offline, "flapping": eval.Flapping} {
+					if len(list) == 1 && list[0] == "host-a" {
+						got = class
+					}
+				}
+				if got != s.want {
+					t.Fatalf("step %d: host-a is %s, want %s", i, got, s.want)
+				}
+				if !s.seen && eval.Misses["host-a"] != s.miss {
+					t.Fatalf("step %d: misses = %d, want %d", i, eval.Misses["host-a"], s.miss)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"time"
+)
+
+// SetDeviceStatus 批次設定設備狀態（除役、預期缺席到指定時間、恢復檢查）
+func SetDeviceStatus(req entities.DeviceStatusRequest) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if len(req.IDs) == 0 {
+		res.Msg = "ids is required"
+		return res
+	}
+
+	updates := map[string]interface{}{"status": req.Status, "absent_until": nil}
+	switch req.Status {
+	case entities.DeviceActive, entities.DeviceDecommissioned:
+	case entities.DeviceExpectedAbsent:
+		if req.AbsentUntil == nil || *req.AbsentUntil <= time.Now().Unix() {
+			res.Msg = "absent_until must be a future Unix timestamp"
+			return res
+		}
+		updates["absent_until"] = *req.AbsentUntil
+	default:
+		res.Msg = fmt.Sprintf("Invalid device status: %s", req.Status)
+		return res
+	}
+
+	result := global.Mysql.Model(&entities.Device{}).Where("id IN ?", req.IDs).Updates(updates)
+	if result.Error != nil {
+		res.Msg = fmt.Sprintf("Failed to update device status: %s", result.Error.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	var devices []entities.Device
+	if err := global.Mysql.Where("id IN ?", req.IDs).Find(&devices).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to get devices: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = fmt.Sprintf("Updated %d devices", result.RowsAffected)
+	res.Body = devices
+	return res
+}
+
+// reactivateDevices 預期缺席時間已過的設備恢復為 active
+func reactivateDevices(deviceGroup string, now time.Time) {
+	result := global.Mysql.Model(&entities.Device{}).
+		Where("device_group = ? AND status = ? AND absent_until <= ?", deviceGroup, entities.DeviceExpectedAbsent, now.Unix()).
+		Updates(map[string]interface{}{"status": entities.DeviceActive, "absent_until": nil})
+	if result.Error != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to reactivate devices in %s: %s", deviceGroup, result.Error.Error()))
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Logrecord_no_rotate("INFO", fmt.Sprintf("%d devices in %s are no longer expected absent, checking resumed", result.RowsAffected, deviceGroup))
+	}
+}
+
+// inactiveDevices 回傳 now 時不需檢查的設備名稱
+func inactiveDevices(devices []entities.Device, now time.Time) map[string]bool {
+	inactive := map[string]bool{}
+	for _, device := range devices {
+		if !device.Monitored(now) {
+			inactive[device.Name] = true
+		}
+	}
+	return inactive
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DiscoveredDeviceService 維護偵測時新出現設備的待審核佇列
+type DiscoveredDeviceService struct {
+	DryRun bool // 只回傳將會加入佇列的設備，不寫入資料庫
+}
+
+// NewDiscoveredDeviceService 建立待審核設備服務
+func NewDiscoveredDeviceService() *DiscoveredDeviceService {
+	return &DiscoveredDeviceService{}
+}
+
+// Queue 將未登錄的設備加入 index 設備群組的待審核佇列，回傳本次第一次出現的設備；
+// 已在佇列中（含已拒絕）的設備只更新最後出現時間與次數
+func (s *DiscoveredDeviceService) Queue(index entities.Index, names []string, seenAt time.Time) []string {
+	if len(names) == 0 {
+		return nil
+	}
+
+	var existing []entities.DiscoveredDevice
+	if err := global.Mysql.Where("device_group = ? AND name IN ?", index.DeviceGroup, names).Find(&existing).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to load discovered devices for %s: %s", index.Logname, err.Error()))
+		return nil
+	}
+
+	known := map[string]bool{}
+	var ids []int
+	for _, device := range existing {
+		known[device.Name] = true
+		ids = append(ids, device.ID)
+	}
+
+	var queued []string
+	for _, name := range names {
+		if !known[name] {
+			queued = append(queued, name)
+		}
+	}
+	if s.DryRun {
+		return queued
+	}
+
+	now := seenAt.Unix()
+	if len(ids) > 0 {
+		err := global.Mysql.Model(&entities.DiscoveredDevice{}).Where("id IN ?", ids).
+			Updates(map[string]interface{}{"last_seen": now, "seen_count": gorm.Expr("seen_count + 1")}).Error
+		if err != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to update discovered devices for %s: %s", index.Logname, err.Error()))
+		}
+	}
+
+	// 同一群組可能由多個 index 同時偵測，重複的設備略過，只通知實際新增的
+	var inserted []string
+	for _, name := range queued {
+		device := entities.DiscoveredDevice{
+			DeviceGroup: index.DeviceGroup,
+			Name:        name,
+			IndexID:     index.ID,
+			Logname:     index.Logname,
+			Status:      entities.DiscoveredDevicePending,
+			FirstSeen:   now,
+			LastSeen:    now,
+			SeenCount:   1,
+		}
+		result := global.Mysql.Clauses(clause.OnConflict{DoNothing: true}).Create(&device)
+		if result.Error != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to queue discovered device %s (%s): %s", name, index.DeviceGroup, result.Error.Error()))
+			continue
+		}
+		if result.RowsAffected > 0 {
+			inserted = append(inserted, name)
+		}
+	}
+
+	return inserted
+}
+
+// GetDiscoveredDevices 取得待審核佇列，可依狀態、設備群組與 index 篩選
+func GetDiscoveredDevices(status string, deviceGroup string, indexID int) models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = []entities.DiscoveredDevice{}
+
+	query := global.Mysql.Order("first_seen DESC")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if deviceGroup != "" {
+		query = query.Where("device_group = ?", deviceGroup)
+	}
+	if indexID > 0 {
+		query = query.Where("index_id = ?", indexID)
+	}
+
+	var devices []entities.DiscoveredDevice
+	if err := query.Find(&devices).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to get discovered devices: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Get discovered devices success"
+	res.Body = devices
+	return res
+}
+
+// ApproveDiscoveredDevices 核准設備（含先前拒絕的），加入 devices 並移出佇列
+func ApproveDiscoveredDevices(ids []int, reviewer string) models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = []entities.Device{}
+
+	if len(ids) == 0 {
+		res.Msg = "ids is required"
+		return res
+	}
+
+	var approved []entities.Device
+	err := global.Mysql.Transaction(func(tx *gorm.DB) error {
+		var queued []entities.DiscoveredDevice
+		if err := tx.Where("id IN ?", ids).Find(&queued).Error; err != nil {
+			return err
+		}
+
+		for _, item := range queued {
+			// 已由其他方式登錄的設備不重複建立
+			var count int64
+			if err := tx.Model(&entities.Device{}).Where("device_group = ? AND name = ?", item.DeviceGroup, item.Name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				device := entities.Device{DeviceGroup: item.DeviceGroup, Name: item.Name}
+				if err := tx.Create(&device).Error; err != nil {
+					return err
+				}
+				approved = append(approved, device)
+			}
+			if err := tx.Delete(&entities.DiscoveredDevice{}, item.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		res.Msg = fmt.Sprintf("Failed to approve discovered devices: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	log.Logrecord_no_rotate("INFO", fmt.Sprintf("%d discovered devices approved by %s", len(approved), reviewer))
+
+	res.Success = true
+	res.Msg = fmt.Sprintf("Approved %d devices", len(approved))
+	if approved != nil {
+		res.Body = approved
+	}
+	return res
+}
+
+// RejectDiscoveredDevices 拒絕待審核設備，之後再出現不會通知也不會加入 devices
+func RejectDiscoveredDevices(ids []int, reviewer string) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if len(ids) == 0 {
+		res.Msg = "ids is required"
+		return res
+	}
+
+	result := global.Mysql.Model(&entities.DiscoveredDevice{}).
+		Where("id IN ? AND status = ?", ids, entities.DiscoveredDevicePending).
+		Updates(map[string]interface{}{
+			"status":      entities.DiscoveredDeviceRejected,
+			"reviewed_by": reviewer,
+			"reviewed_at": time.Now().Unix(),
+		})
+	if result.Error != nil {
+		res.Msg = fmt.Sprintf("Failed to reject discovered devices: %s", result.Error.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = fmt.Sprintf("Rejected %d devices", result.RowsAffected)
+	res.Body = result.RowsAffected
+	return res
+}
@@ -149,8 +149,12 @@ func UpdateESConnection(connection entities.ESConnection) models.Response {
 		}
 	}
 
-	// 更新連線
-	err := global.Mysql.Model(&entities.ESConnection{}).Where("id = ?", connection.ID).Updates(&connection).Error
+	// 更新連線：明確指定欄位，讓 query_timeout 等零值也能被更新；未提供密碼時保留原密碼
+	columns := []string{"name", "host", "port", "username", "enable_auth", "use_tls", "is_default", "description", "query_timeout", "max_concurrency"}
+	if connection.Password != "" {
+		columns = append(columns, "password")
+	}
+	err := global.Mysql.Model(&entities.ESConnection{}).Where("id = ?", connection.ID).Select(columns).Updates(&connection).Error
 	if err != nil {
 		res.Msg = fmt.Sprintf("Failed to update ES connection: %s", err.Error())
 		log.Logrecord_no_rotate("ERROR", res.Msg)
@@ -162,6 +166,7 @@ func UpdateESConnection(connection entities.ESConnection) models.Response {
 	if err := manager.ReloadConnection(connection.ID); err != nil {
 		log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Failed to reload connection manager after update: %s", err.Error()))
 	}
+	invalidateConnectionLimit(connection.ID)
 
 	res.Success = true
 	res.Msg = "Update ES connection success"
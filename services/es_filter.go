@@ -0,0 +1,171 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log-detect/entities"
+	"regexp"
+	"strings"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// Index 過濾條件類型
+const (
+	FilterTypeLucene = "lucene"
+	FilterTypeKQL    = "kql"
+	FilterTypeDSL    = "dsl"
+)
+
+var (
+	kqlBooleanPattern  = regexp.MustCompile(`(?i)\b(and|or|not)\b`)
+	kqlRangePattern    = regexp.MustCompile(`([\w.@]+)\s*(>=|<=|>|<)\s*`)
+	kqlNotEqualPattern = regexp.MustCompile(`([\w.@]+)\s*!=\s*`)
+)
+
+// BuildFilterClause 將 Index 的過濾設定轉為可放入 bool.filter 的查詢，未設定時回傳 nil
+func BuildFilterClause(filterType string, filterQuery string) (map[string]interface{}, error) {
+	filterQuery = strings.TrimSpace(filterQuery)
+	if filterQuery == "" {
+		return nil, nil
+	}
+
+	switch filterType {
+	case FilterTypeLucene, "":
+		return queryStringClause(filterQuery), nil
+	case FilterTypeKQL:
+		return queryStringClause(kqlToLucene(filterQuery)), nil
+	case FilterTypeDSL:
+		var clause map[string]interface{}
+		if err := json.Unmarshal([]byte(filterQuery), &clause); err != nil {
+			return nil, fmt.Errorf("filter DSL 不是合法的 JSON 物件: %w", err)
+		}
+		// 允許直接貼上 {"query": {...}}
+		if inner, ok := clause["query"].(map[string]interface{}); ok && len(clause) == 1 {
+			clause = inner
+		}
+		if len(clause) != 1 {
+			return nil, fmt.Errorf("filter DSL 必須是單一查詢子句，例如 {\"term\": {...}}")
+		}
+		return clause, nil
+	}
+	return nil, fmt.Errorf("不支援的 filter 類型: %s", filterType)
+}
+
+// queryStringClause 組裝 query_string 查詢
+func queryStringClause(query string) map[string]interface{} {
+	return map[string]interface{}{
+		"query_string": map[string]interface{}{
+			"query":            query,
+			"analyze_wildcard": true,
+		},
+	}
+}
+
+// kqlToLucene 將常用的 KQL 語法轉為 Lucene query string
+// 支援 and / or / not（不分大小寫）、field >= value 範圍寫法與 field != value（轉為 NOT field:value），引號內的文字不轉換
+func kqlToLucene(kql string) string {
+	var sb strings.Builder
+	inQuote := false
+	start := 0
+	flush := func(end int) {
+		segment := kql[start:end]
+		if !inQuote {
+			segment = kqlNotEqualPattern.ReplaceAllString(segment, "NOT $1:")
+			segment = kqlRangePattern.ReplaceAllString(segment, "$1:$2")
+			segment = kqlBooleanPattern.ReplaceAllStringFunc(segment, strings.ToUpper)
+		}
+		sb.WriteString(segment)
+		start = end
+	}
+
+	for i := 0; i < len(kql); i++ {
+		if kql[i] == '"' && (i == 0 || kql[i-1] != '\\') {
+			if inQuote {
+				flush(i + 1)
+			} else {
+				flush(i)
+			}
+			inQuote = !inQuote
+		}
+	}
+	flush(len(kql))
+	return sb.String()
+}
+
+// ValidateIndexFilter 檢查 Index 的過濾條件語法，並以 _validate/query 在目標叢集上驗證
+func ValidateIndexFilter(index entities.Index) error {
+	clause, err := BuildFilterClause(index.FilterType, index.FilterQuery)
+	if err != nil || clause == nil {
+		return err
+	}
+
+	esClient, err := clientForConnection(index.ESConnectionID)
+	if err != nil {
+		return fmt.Errorf("無法取得 ES 連線以驗證 filter: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"query": clause})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeout(index))
+	defer cancel()
+
+	res, err := esClient.Indices.ValidateQuery(
+		esClient.Indices.ValidateQuery.WithContext(ctx),
+		esClient.Indices.ValidateQuery.WithIndex(index.Pattern),
+		esClient.Indices.ValidateQuery.WithBody(bytes.NewReader(body)),
+		esClient.Indices.ValidateQuery.WithExplain(true),
+	)
+	if err != nil {
+		return fmt.Errorf("驗證 filter 失敗: %w", err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.IsError() {
+		return fmt.Errorf("驗證 filter 失敗 [%d]: %s", res.StatusCode, truncateBody(resBody))
+	}
+
+	var validation struct {
+		Valid        bool `json:"valid"`
+		Explanations []struct {
+			Index string `json:"index"`
+			Valid bool   `json:"valid"`
+			Error string `json:"error"`
+		} `json:"explanations"`
+	}
+	if err := json.Unmarshal(resBody, &validation); err != nil {
+		return fmt.Errorf("decode validate response: %w", err)
+	}
+	if !validation.Valid {
+		for _, explanation := range validation.Explanations {
+			if explanation.Error != "" {
+				return fmt.Errorf("filter 語法錯誤: %s", explanation.Error)
+			}
+		}
+		return fmt.Errorf("filter 語法錯誤")
+	}
+	return nil
+}
+
+// clientForConnection 依 ES 連線 ID 取得客戶端，未指定時使用預設連線
+func clientForConnection(connectionID *int) (*elasticsearch.Client, error) {
+	manager := GetESConnectionManager()
+	if connectionID != nil {
+		return manager.GetClient(*connectionID)
+	}
+	client := manager.GetDefaultClient()
+	if client == nil {
+		return nil, fmt.Errorf("default ES client is nil")
+	}
+	return client, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKqlToLucene(t *testing.T) {
+	tests := []struct {
+		name string
+		kql  string
+		want string
+	}{
+		{name: "field match", kql: "log.level: error", want: "log.level: error"},
+		{name: "boolean operators", kql: "log.level: error and not host.name: test or tags: prod", want: "log.level: error AND NOT host.name: test OR tags: prod"},
+		{name: "not equal", kql: "log.level != debug", want: "NOT log.level:debug"},
+		{name: "not equal without spaces", kql: "event.code!=4625 and host.name: fw01", want: "NOT event.code:4625 AND host.name: fw01"},
+		{name: "not equal quoted value", kql: `service.name != "log detect"`, want: `NOT service.name:"log detect"`},
+		{name: "range", kql: "http.status >= 500 and bytes < 1024", want: "http.status:>=500 AND bytes:<1024"},
+		{name: "timestamp field", kql: "@timestamp > now-5m", want: "@timestamp:>now-5m"},
+		{name: "quoted text untouched", kql: `message: "a and b != c" or message: "x > 1"`, want: `message: "a and b != c" OR message: "x > 1"`},
+		{name: "escaped quote", kql: `message: "say \"and\"" and x: 1`, want: `message: "say \"and\"" AND x: 1`},
+		{name: "words containing operators", kql: "brand: android and order: north", want: "brand: android AND order: north"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := kqlToLucene(tt.kql); got != tt.want {
+				t.Errorf("kqlToLucene(%q) = %q, want %q", tt.kql, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildFilterClause(t *testing.T) {
+	queryString := func(query string) map[string]interface{} {
+		return map[string]interface{}{
+			"query_string": map[string]interface{}{"query": query, "analyze_wildcard": true},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		filterType  string
+		filterQuery string
+		want        map[string]interface{}
+		wantErr     bool
+	}{
+		{name: "empty", filterType: FilterTypeKQL, filterQuery: "  ", want: nil},
+		{name: "lucene", filterType: FilterTypeLucene, filterQuery: "log.level:error", want: queryString("log.level:error")},
+		{name: "default is lucene", filterType: "", filterQuery: "log.level:error", want: queryString("log.level:error")},
+		{name: "kql", filterType: FilterTypeKQL, filterQuery: "log.level != debug", want: queryString("NOT log.level:debug")},
+		{
+			name:        "dsl",
+			filterType:  FilterTypeDSL,
+			filterQuery: `{"term": {"log.level": "error"}}`,
+			want:        map[string]interface{}{"term": map[string]interface{}{"log.level": "error"}},
+		},
+		{
+			name:        "dsl wrapped in query",
+			filterType:  FilterTypeDSL,
+			filterQuery: `{"query": {"exists": {"field": "host.name"}}}`,
+			want:        map[string]interface{}{"exists": map[string]interface{}{"field": "host.name"}},
+		},
+		{name: "dsl invalid json", filterType: FilterTypeDSL, filterQuery: `{"term":`, wantErr: true},
+		{name: "dsl multiple clauses", filterType: FilterTypeDSL, filterQuery: `{"term": {}, "match": {}}`, wantErr: true},
+		{name: "unknown type", filterType: "sql", filterQuery: "select 1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildFilterClause(tt.filterType, tt.filterQuery)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("BuildFilterClause() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildFilterClause() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildFilterClause() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -14,8 +14,11 @@ import (
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
+// historyInsertTimeout 單筆歷史資料寫入 ES 的逾時
+const historyInsertTimeout = 10 * time.Second
+
 // Insert_HistoryDataWithClient 使用指定的 ES 客戶端插入歷史資料（支援多連線）
-func Insert_HistoryDataWithClient(esClient *elasticsearch.Client, historyData entities.History) {
+func Insert_HistoryDataWithClient(ctx context.Context, esClient *elasticsearch.Client, historyData entities.History) {
 
 	// 將資料轉換為 JSON
 	var buf bytes.Buffer
@@ -36,10 +39,12 @@ func Insert_HistoryDataWithClient(esClient *elasticsearch.Client, historyData en
 	}
 
 	// 執行請求
-	res, err := req.Do(context.Background(), esClient)
+	ctx, cancel := context.WithTimeout(ctx, historyInsertTimeout)
+	defer cancel()
+	res, err := req.Do(ctx, esClient)
 	if err != nil {
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("es insert request error,%s", err.Error()))
-
+		return
 	}
 	defer res.Body.Close()
 
@@ -59,6 +64,6 @@ func Insert_HistoryDataWithClient(esClient *elasticsearch.Client, historyData en
 }
 
 // Insert_HistoryData 使用預設 ES 客戶端插入歷史資料（向後兼容）
-func Insert_HistoryData(historyData entities.History) {
-	Insert_HistoryDataWithClient(global.Elasticsearch, historyData)
+func Insert_HistoryData(ctx context.Context, historyData entities.History) {
+	Insert_HistoryDataWithClient(ctx, global.Elasticsearch, historyData)
 }
@@ -487,7 +487,7 @@ func (s *ESMonitorService) MonitorESCluster(monitor entities.ElasticsearchMonito
 			// 寫入告警記錄（帶去重邏輯）
 			created := s.CreateAlert(monitor, alert)
 			// 只有成功創建新告警時才發送通知（避免重複通知）
-			if created && len(monitor.Receivers) > 0 {
+			if created && (len(monitor.Receivers) > 0 || len(monitor.ChannelIDs) > 0) {
 				s.SendAlertNotification(monitor, alert)
 			}
 		}
@@ -764,8 +764,8 @@ func (s *ESMonitorService) isDuplicateAlert(monitor entities.ElasticsearchMonito
 
 // SendAlertNotification 發送告警通知
 func (s *ESMonitorService) SendAlertNotification(monitor entities.ElasticsearchMonitor, alert entities.ESAlert) {
-	if len(monitor.Receivers) == 0 {
-		log.Logrecord_no_rotate("WARN", fmt.Sprintf("No receivers configured for monitor: %s", monitor.Name))
+	if len(monitor.Receivers) == 0 && len(monitor.ChannelIDs) == 0 {
+		log.Logrecord_no_rotate("WARN", fmt.Sprintf("No receivers or channels configured for monitor: %s", monitor.Name))
 		return
 	}
 
@@ -803,11 +803,25 @@ func (s *ESMonitorService) SendAlertNotification(monitor entities.ElasticsearchM
 		details = append(details, fmt.Sprintf("說明: %s", monitor.Description))
 	}
 
-	// 發送郵件給所有收件人
-	Mail4(monitor.Receivers, nil, nil, subject, monitor.Name, details)
-
-	log.Logrecord_no_rotate("INFO", fmt.Sprintf("Alert notification sent to %d receivers for monitor: %s",
-		len(monitor.Receivers), monitor.Name))
+	// 發送給所有收件人與通知通道
+	dest := NotificationDestination{
+		Source:     "es_monitor",
+		SourceID:   monitor.ID,
+		Receivers:  monitor.Receivers,
+		ChannelIDs: monitor.ChannelIDs,
+	}
+	result := DispatchNotification(dest, Notification{
+		Subject:  subject,
+		Logname:  monitor.Name,
+		Event:    "es_" + alert.AlertType,
+		Severity: alert.Severity,
+		Summary:  fmt.Sprintf("%s 告警內容如下：", monitor.Name),
+		Items:    details,
+		Time:     alert.Time,
+	})
+
+	log.Logrecord_no_rotate("INFO", fmt.Sprintf("Alert notification for monitor %s: %d sent, %d failed",
+		monitor.Name, result.Sent, result.Failed))
 }
 
 // 輔助函數
@@ -1,125 +1,398 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log-detect/entities"
 	"log-detect/global"
 	"log-detect/log"
-	"strings"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/elastic/go-elasticsearch/v8/esapi"
 )
 
+// defaultCompositePageSize composite aggregation 預設每頁 bucket 數
+const defaultCompositePageSize = 1000
 
-// SearchRequestWithClient 使用指定的 ES 客戶端執行查詢（支援多連線）
-func SearchRequestWithClient(esClient *elasticsearch.Client, index string, field string, timefrom string, timeto string) Search_Request {
-	querybody := fmt.Sprintf(`{
-		"aggs": {
-		  "2": {
-			"terms": {
-			  "field": "%s",
-			  "order": {
-				"_count": "desc"
-			  },
-			  "size": 100,
-			  "shard_size": 25
-			}
-		  }
-		},
-		"size": 0,
-		"fields": [
-		  {
-			"field": "@timestamp",
-			"format": "date_time"
-		  }
-		],
-		"script_fields": {},
-		"stored_fields": [
-		  "*"
-		],
-		"runtime_mappings": {},
-		"_source": {
-		  "excludes": []
+// maxCompositePages 分頁上限，避免欄位選錯（例如 message）時無止盡翻頁
+const maxCompositePages = 1000
+
+// TermsBucket 單一設備的聚合結果
+type TermsBucket struct {
+	Key      string `json:"key"`
+	DocCount int64  `json:"doc_count"`
+}
+
+// ShardFailure ES 回傳的 shard 失敗資訊
+type ShardFailure struct {
+	Index  string `json:"index"`
+	Shard  int    `json:"shard"`
+	Node   string `json:"node"`
+	Reason struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"reason"`
+}
+
+// ShardsInfo ES 回傳的 _shards 統計
+type ShardsInfo struct {
+	Total      int            `json:"total"`
+	Successful int            `json:"successful"`
+	Skipped    int            `json:"skipped"`
+	Failed     int            `json:"failed"`
+	Failures   []ShardFailure `json:"failures,omitempty"`
+}
+
+// DeviceSearchResult 走完所有分頁後的設備聚合結果
+type DeviceSearchResult struct {
+	Buckets  []TermsBucket `json:"buckets"`
+	Took     int           `json:"took"`  // 所有分頁累計耗時（毫秒）
+	Pages    int           `json:"pages"` // 實際查詢的分頁數
+	TimedOut bool          `json:"timed_out"`
+	Shards   ShardsInfo    `json:"shards"`
+
+	// composite aggregation 逐頁取得所有 bucket，筆數為精確值（沒有 terms aggregation 的 doc_count_error_upper_bound）；
+	// 任一分頁逾時、shard 失敗或分頁中斷時為 true，此時缺失的設備不能直接視為離線
+	Partial bool `json:"partial"`
+}
+
+// Keys 回傳所有 bucket 的 key
+func (r DeviceSearchResult) Keys() []string {
+	keys := make([]string, 0, len(r.Buckets))
+	for _, bucket := range r.Buckets {
+		keys = append(keys, bucket.Key)
+	}
+	return keys
+}
+
+// DocCounts 回傳 key 對應的日誌筆數
+func (r DeviceSearchResult) DocCounts() map[string]int64 {
+	counts := make(map[string]int64, len(r.Buckets))
+	for _, bucket := range r.Buckets {
+		counts[bucket.Key] = bucket.DocCount
+	}
+	return counts
+}
+
+// compositeResponse composite aggregation 單頁回應
+type compositeResponse struct {
+	Took         int        `json:"took"`
+	TimedOut     bool       `json:"timed_out"`
+	Shards       ShardsInfo `json:"_shards"`
+	Aggregations struct {
+		Devices struct {
+			AfterKey map[string]interface{} `json:"after_key"`
+			Buckets  []struct {
+				Key      map[string]interface{} `json:"key"`
+				DocCount int64                  `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"devices"`
+	} `json:"aggregations"`
+}
+
+// DeviceSearchParams 設備聚合查詢參數
+type DeviceSearchParams struct {
+	Index          string                 // index pattern
+	Field          string                 // 設備名稱欄位
+	TimestampField string                 // 時間欄位
+	From           string                 // 起始時間（含）
+	To             string                 // 結束時間（含）
+	Filter         map[string]interface{} // 額外過濾條件（BuildFilterClause 的結果），可為 nil
+	Timeout        time.Duration          // 整次查詢（含所有分頁）的逾時，0 表示不限制
+}
+
+// NewDeviceSearchParams 依 Index 設定建立查詢參數
+func NewDeviceSearchParams(idx entities.Index, timefrom string, timeto string) (DeviceSearchParams, error) {
+	filter, err := BuildFilterClause(idx.FilterType, idx.FilterQuery)
+	if err != nil {
+		return DeviceSearchParams{}, err
+	}
+	return DeviceSearchParams{
+		Index:          idx.Pattern,
+		Field:          idx.Field,
+		TimestampField: idx.GetTimestampField(),
+		From:           timefrom,
+		To:             timeto,
+		Filter:         filter,
+		Timeout:        QueryTimeout(idx),
+	}, nil
+}
+
+// SearchRequestWithClient 使用指定的 ES 客戶端，以 composite aggregation 分頁取得時間區間內所有設備（支援多連線）
+// ctx 取消或超過 params.Timeout 時中斷查詢
+func SearchRequestWithClient(ctx context.Context, esClient *elasticsearch.Client, params DeviceSearchParams) (DeviceSearchResult, error) {
+	return searchDevices(ctx, esClient, params, nil)
+}
+
+// prefetchedPage 由 _msearch 預先取得的第一頁，body 用於確認與實際查詢相同
+type prefetchedPage struct {
+	body []byte
+	page compositeResponse
+}
+
+// searchDevices 分頁查詢；prefetch 與本次第一頁的查詢相同時直接使用，其餘分頁照常查詢
+func searchDevices(ctx context.Context, esClient *elasticsearch.Client, params DeviceSearchParams, prefetch *prefetchedPage) (DeviceSearchResult, error) {
+	result := DeviceSearchResult{}
+	index, field := params.Index, params.Field
+
+	if params.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, params.Timeout)
+		defer cancel()
+	}
+
+	pageSize := compositePageSize()
+
+	var afterKey map[string]interface{}
+	for result.Pages < maxCompositePages {
+		body, err := buildCompositeQuery(params, pageSize, afterKey)
+		if err != nil {
+			return result, err
+		}
+
+		var page compositeResponse
+		if result.Pages == 0 && prefetch != nil && bytes.Equal(body, prefetch.body) {
+			page = prefetch.page
+		} else {
+			page, err = doCompositeSearch(ctx, esClient, index, body)
+		}
+		if err != nil {
+			// 已取得部分分頁時仍回傳，由呼叫端依 Partial 判斷
+			result.Partial = result.Pages > 0
+			return result, err
+		}
+
+		result.Pages++
+		result.Took += page.Took
+		result.TimedOut = result.TimedOut || page.TimedOut
+		mergeShards(&result.Shards, page.Shards)
+
+		for _, bucket := range page.Aggregations.Devices.Buckets {
+			result.Buckets = append(result.Buckets, TermsBucket{
+				Key:      fmt.Sprintf("%v", bucket.Key["device"]),
+				DocCount: bucket.DocCount,
+			})
+		}
+
+		afterKey = page.Aggregations.Devices.AfterKey
+		if afterKey == nil || len(page.Aggregations.Devices.Buckets) < pageSize {
+			break
+		}
+	}
+
+	if result.Pages >= maxCompositePages && afterKey != nil {
+		log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Composite aggregation on %s.%s stopped after %d pages", index, field, maxCompositePages))
+		result.Partial = true
+	}
+	if result.TimedOut || result.Shards.Failed > 0 {
+		result.Partial = true
+	}
+
+	return result, nil
+}
+
+// compositePageSize composite aggregation 每頁 bucket 數
+func compositePageSize() int {
+	if pageSize := global.EnvConfig.Detect.PageSize; pageSize > 0 {
+		return pageSize
+	}
+	return defaultCompositePageSize
+}
+
+// multiSearchResponse _msearch 回應，responses 與送出的查詢順序相同
+type multiSearchResponse struct {
+	Responses []struct {
+		compositeResponse
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"responses"`
+}
+
+// multiSearchFirstPages 以單一 _msearch 取得多個設備查詢的第一頁
+// 整個請求失敗時回傳 error；個別查詢失敗時對應位置為 nil，由呼叫端改用一般查詢
+func multiSearchFirstPages(ctx context.Context, esClient *elasticsearch.Client, params []DeviceSearchParams) ([]*prefetchedPage, error) {
+	pageSize := compositePageSize()
+	pages := make([]*prefetchedPage, len(params))
+	bodies := make([][]byte, len(params))
+
+	var timeout time.Duration
+	var buf bytes.Buffer
+	for i, p := range params {
+		body, err := buildCompositeQuery(p, pageSize, nil)
+		if err != nil {
+			return nil, err
+		}
+		header, err := json.Marshal(map[string]interface{}{"index": p.Index})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(header)
+		buf.WriteByte('\n')
+		buf.Write(body)
+		buf.WriteByte('\n')
+		bodies[i] = body
+		if p.Timeout > timeout {
+			timeout = p.Timeout
+		}
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	req := esapi.MsearchRequest{Body: &buf}
+	res, err := req.Do(ctx, esClient)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("es msearch: %w", ctx.Err())
+		}
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("es msearch error [%d]: %s", res.StatusCode, truncateBody(resBody))
+	}
+
+	var parsed multiSearchResponse
+	if err := json.Unmarshal(resBody, &parsed); err != nil {
+		return nil, fmt.Errorf("decode msearch response: %w", err)
+	}
+	if len(parsed.Responses) != len(params) {
+		return nil, fmt.Errorf("msearch returned %d responses for %d queries", len(parsed.Responses), len(params))
+	}
+
+	for i, item := range parsed.Responses {
+		if len(item.Error) > 0 || item.Status >= 300 {
+			log.Logrecord_no_rotate("WARNING", fmt.Sprintf("msearch item for %s failed [%d]: %s", params[i].Index, item.Status, truncateBody(item.Error)))
+			continue
+		}
+		pages[i] = &prefetchedPage{body: bodies[i], page: item.compositeResponse}
+	}
+	return pages, nil
+}
+
+// SearchRequest 使用預設 ES 客戶端執行查詢（向後兼容）
+func SearchRequest(ctx context.Context, params DeviceSearchParams) (DeviceSearchResult, error) {
+	return SearchRequestWithClient(ctx, global.Elasticsearch, params)
+}
+
+// buildCompositeQuery 組裝單頁的 composite aggregation 查詢
+func buildCompositeQuery(params DeviceSearchParams, pageSize int, afterKey map[string]interface{}) ([]byte, error) {
+	composite := map[string]interface{}{
+		"size": pageSize,
+		"sources": []interface{}{
+			map[string]interface{}{
+				"device": map[string]interface{}{
+					"terms": map[string]interface{}{"field": params.Field},
+				},
+			},
 		},
-		"query": {
-		  "bool": {
-			"must": [],
-			"filter": [
-			  {
-				"range": {
-				  "@timestamp": {
+	}
+	if afterKey != nil {
+		composite["after"] = afterKey
+	}
+
+	timestampField := params.TimestampField
+	if timestampField == "" {
+		timestampField = entities.DefaultTimestampField
+	}
+	filters := []interface{}{
+		map[string]interface{}{
+			"range": map[string]interface{}{
+				timestampField: map[string]interface{}{
 					"format": "strict_date_optional_time",
-					"gte": "%s",
-					"lte": "%s"
-				  }
-				}
-			  }
-			],
-			"should": [],
-			"must_not": []
-		  }
-		}
-	  }`, field, timefrom, timeto)
-	// fmt.Println(querybody)
+					"gte":    params.From,
+					"lte":    params.To,
+				},
+			},
+		},
+	}
+	if params.Filter != nil {
+		filters = append(filters, params.Filter)
+	}
+
+	query := map[string]interface{}{
+		"size":             0,
+		"track_total_hits": false,
+		"aggs": map[string]interface{}{
+			"devices": map[string]interface{}{"composite": composite},
+		},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": filters,
+			},
+		},
+	}
+
+	return json.Marshal(query)
+}
+
+// doCompositeSearch 執行單頁查詢並解析回應
+func doCompositeSearch(ctx context.Context, esClient *elasticsearch.Client, index string, body []byte) (compositeResponse, error) {
+	var page compositeResponse
+
 	req := esapi.SearchRequest{
 		Index: []string{index},
-		Body:  strings.NewReader(querybody),
+		Body:  bytes.NewReader(body),
 	}
-	res, err := req.Do(context.Background(), esClient)
+	res, err := req.Do(ctx, esClient)
 	if err != nil {
+		// 逾時 / 取消時回傳 context 錯誤，讓呼叫端可用 errors.Is 判斷
+		if ctx.Err() != nil {
+			err = fmt.Errorf("es search on %s: %w", index, ctx.Err())
+		}
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("es connect error: %s", err.Error()))
-
+		return page, err
 	}
+	defer res.Body.Close()
 
-	resString, err := io.ReadAll(res.Body)
+	resBody, err := io.ReadAll(res.Body)
 	if err != nil {
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("read res.body error: %s", err.Error()))
+		return page, err
+	}
+	if res.IsError() {
+		return page, fmt.Errorf("es search error [%d]: %s", res.StatusCode, truncateBody(resBody))
+	}
 
+	if err := json.Unmarshal(resBody, &page); err != nil {
+		return page, fmt.Errorf("decode search response: %w", err)
 	}
-	var s Search_Request
-	json.Unmarshal(resString, &s)
-	// // log.Println(res)
-	defer res.Body.Close()
+	return page, nil
+}
 
-	// fmt.Println(resString)
-	return s
+// mergeShards 合併各分頁的 shard 統計（取最差的情況）
+func mergeShards(total *ShardsInfo, page ShardsInfo) {
+	if page.Total > total.Total {
+		total.Total = page.Total
+	}
+	if total.Successful == 0 || page.Successful < total.Successful {
+		total.Successful = page.Successful
+	}
+	if page.Skipped > total.Skipped {
+		total.Skipped = page.Skipped
+	}
+	if page.Failed > total.Failed {
+		total.Failed = page.Failed
+	}
+	total.Failures = append(total.Failures, page.Failures...)
 }
 
-// SearchRequest 使用預設 ES 客戶端執行查詢（向後兼容）
-// dsl 比實際時間再減八小時
-func SearchRequest(index string, field string, timefrom string, timeto string) Search_Request {
-	return SearchRequestWithClient(global.Elasticsearch, index, field, timefrom, timeto)
-}
-
-
-type Search_Request struct {
-	Took     int  `json:"took"`
-	TimedOut bool `json:"timed_out"`
-	Shards   struct {
-		Total      int `json:"total"`
-		Successful int `json:"successful"`
-		Skipped    int `json:"skipped"`
-		Failed     int `json:"failed"`
-	} `json:"_shards"`
-	Hits struct {
-		Total struct {
-			Value    int    `json:"value"`
-			Relation string `json:"relation"`
-		} `json:"total"`
-		MaxScore interface{}   `json:"max_score"`
-		Hits     []interface{} `json:"hits"`
-	} `json:"hits"`
-	Aggregations struct {
-		Num2 struct {
-			DocCountErrorUpperBound int `json:"doc_count_error_upper_bound"`
-			SumOtherDocCount        int `json:"sum_other_doc_count"`
-			Buckets                 []struct {
-				Key      string `json:"key"`
-				DocCount int    `json:"doc_count"`
-			} `json:"buckets"`
-		} `json:"2"`
-	} `json:"aggregations"`
-}
\ No newline at end of file
+// truncateBody 截斷 ES 錯誤回應用於錯誤訊息
+func truncateBody(body []byte) string {
+	if len(body) > 500 {
+		return string(body[:500]) + "..."
+	}
+	return string(body)
+}
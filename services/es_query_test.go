@@ -0,0 +1,254 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log-detect/global"
+	"log-detect/structs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// setupTestEnv 測試用設定，log 寫到暫存目錄
+func setupTestEnv(t *testing.T) {
+	t.Helper()
+	previous := global.EnvConfig
+	global.EnvConfig = &structs.EnviromentModel{}
+	global.EnvConfig.Path.Log_record = t.TempDir()
+	t.Cleanup(func() { global.EnvConfig = previous })
+}
+
+// newTestESClient 以 httptest 模擬 ES，回應帶上 client 需要的 product header
+func newTestESClient(t *testing.T, handler http.HandlerFunc) *elasticsearch.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}})
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	return client
+}
+
+// compositePageJSON 組出單頁 composite 回應
+func compositePageJSON(keys ...string) map[string]interface{} {
+	buckets := []interface{}{}
+	for _, key := range keys {
+		buckets = append(buckets, map[string]interface{}{
+			"key":       map[string]interface{}{"device": key},
+			"doc_count": 1,
+		})
+	}
+	return map[string]interface{}{
+		"took":      1,
+		"timed_out": false,
+		"_shards":   map[string]interface{}{"total": 1, "successful": 1},
+		"aggregations": map[string]interface{}{
+			"devices": map[string]interface{}{"buckets": buckets},
+		},
+	}
+}
+
+func TestSearchDevicesReusesPrefetchedFirstPage(t *testing.T) {
+	setupTestEnv(t)
+
+	var searches, msearches int32
+	client := newTestESClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_msearch"):
+			atomic.AddInt32(&msearches, 1)
+			body, _ := io.ReadAll(r.Body)
+			lines := bytes.Split(bytes.TrimSpace(body), []byte("\n"))
+			if len(lines) != 4 {
+				t.Errorf("msearch body has %d lines, want 4", len(lines))
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"responses": []interface{}{
+					mergeStatus(compositePageJSON("host-a", "host-b"), 200),
+					map[string]interface{}{"status": 404, "error": map[string]interface{}{"type": "index_not_found_exception"}},
+				},
+			})
+		case strings.HasSuffix(r.URL.Path, "/_search"):
+			atomic.AddInt32(&searches, 1)
+			json.NewEncoder(w).Encode(compositePageJSON("host-c"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	params := []DeviceSearchParams{
+		{Index: "logs-a-*", Field: "host.name", From: "2026-10-17T00:00:00Z", To: "2026-10-17T00:05:00Z"},
+		{Index: "logs-b-*", Field: "host.name", From: "2026-10-17T00:00:00Z", To: "2026-10-17T00:05:00Z"},
+	}
+	pages, err := multiSearchFirstPages(context.Background(), client, params)
+	if err != nil {
+		t.Fatalf("multiSearchFirstPages: %v", err)
+	}
+	if len(pages) != 2 || pages[0] == nil || pages[1] != nil {
+		t.Fatalf("pages = %+v, want first page only for the first query", pages)
+	}
+
+	// 查詢相同時直接使用預先取得的第一頁
+	result, err := searchDevices(context.Background(), client, params[0], pages[0])
+	if err != nil {
+		t.Fatalf("searchDevices: %v", err)
+	}
+	if got := strings.Join(result.Keys(), ","); got != "host-a,host-b" {
+		t.Errorf("keys = %s, want host-a,host-b", got)
+	}
+	if result.Pages != 1 || atomic.LoadInt32(&searches) != 0 {
+		t.Errorf("pages = %d, searches = %d, want 1 page and no _search", result.Pages, atomic.LoadInt32(&searches))
+	}
+
+	// 個別查詢失敗（nil）時改用一般查詢
+	result, err = searchDevices(context.Background(), client, params[1], pages[1])
+	if err != nil {
+		t.Fatalf("searchDevices: %v", err)
+	}
+	if got := strings.Join(result.Keys(), ","); got != "host-c" || atomic.LoadInt32(&searches) != 1 {
+		t.Errorf("keys = %s, searches = %d, want host-c from one _search", got, atomic.LoadInt32(&searches))
+	}
+
+	// 查詢條件與預先取得時不同（例如時間區間已變）時不可沿用
+	changed := params[0]
+	changed.To = "2026-10-17T00:06:00Z"
+	result, err = searchDevices(context.Background(), client, changed, pages[0])
+	if err != nil {
+		t.Fatalf("searchDevices: %v", err)
+	}
+	if got := strings.Join(result.Keys(), ","); got != "host-c" || atomic.LoadInt32(&searches) != 2 {
+		t.Errorf("keys = %s, searches = %d, want host-c from a new _search", got, atomic.LoadInt32(&searches))
+	}
+	if atomic.LoadInt32(&msearches) != 1 {
+		t.Errorf("msearches = %d, want 1", atomic.LoadInt32(&msearches))
+	}
+}
+
+func TestMultiSearchFirstPagesRequestError(t *testing.T) {
+	setupTestEnv(t)
+
+	client := newTestESClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"unavailable"}`))
+	})
+
+	params := []DeviceSearchParams{{Index: "logs-*", Field: "host.name"}}
+	if _, err := multiSearchFirstPages(context.Background(), client, params); err == nil {
+		t.Fatal("expected error when the whole _msearch fails")
+	}
+}
+
+// mergeStatus 加上 _msearch 個別回應的 status
+func mergeStatus(page map[string]interface{}, status int) map[string]interface{} {
+	page["status"] = status
+	return page
+}
+
+func TestBuildCompositeQuery(t *testing.T) {
+	filter := map[string]interface{}{"term": map[string]interface{}{"log.level": "error"}}
+	tests := []struct {
+		name      string
+		params    DeviceSearchParams
+		afterKey  map[string]interface{}
+		wantTS    string
+		wantAfter bool
+		wantCount int // bool.filter 子句數
+	}{
+		{name: "default timestamp", params: DeviceSearchParams{Field: "host.name", From: "a", To: "b"}, wantTS: "@timestamp", wantCount: 1},
+		{name: "custom timestamp", params: DeviceSearchParams{Field: "host.name", TimestampField: "event.created"}, wantTS: "event.created", wantCount: 1},
+		{name: "with filter", params: DeviceSearchParams{Field: "host.name", Filter: filter}, wantTS: "@timestamp", wantCount: 2},
+		{name: "next page", params: DeviceSearchParams{Field: "host.name"}, afterKey: map[string]interface{}{"device": "host-b"}, wantTS: "@timestamp", wantAfter: true, wantCount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := buildCompositeQuery(tt.params, 200, tt.afterKey)
+			if err != nil {
+				t.Fatalf("buildCompositeQuery: %v", err)
+			}
+			var query struct {
+				Size           int  `json:"size"`
+				TrackTotalHits bool `json:"track_total_hits"`
+				Aggs           struct {
+					Devices struct {
+						Composite struct {
+							Size    int                      `json:"size"`
+							After   map[string]interface{}   `json:"after"`
+							Sources []map[string]interface{} `json:"sources"`
+						} `json:"composite"`
+					} `json:"devices"`
+				} `json:"aggs"`
+				Query struct {
+					Bool struct {
+						Filter []map[string]map[string]interface{} `json:"filter"`
+					} `json:"bool"`
+				} `json:"query"`
+			}
+			if err := json.Unmarshal(body, &query); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+
+			composite := query.Aggs.Devices.Composite
+			if query.Size != 0 || query.TrackTotalHits || composite.Size != 200 {
+				t.Errorf("size = %d, track_total_hits = %v, composite size = %d", query.Size, query.TrackTotalHits, composite.Size)
+			}
+			terms := composite.Sources[0]["device"].(map[string]interface{})["terms"].(map[string]interface{})
+			if terms["field"] != tt.params.Field {
+				t.Errorf("terms field = %v, want %s", terms["field"], tt.params.Field)
+			}
+			if (composite.After != nil) != tt.wantAfter {
+				t.Errorf("after = %v, want present %v", composite.After, tt.wantAfter)
+			}
+			filters := query.Query.Bool.Filter
+			if len(filters) != tt.wantCount {
+				t.Fatalf("filter has %d clauses, want %d", len(filters), tt.wantCount)
+			}
+			if _, ok := filters[0]["range"][tt.wantTS]; !ok {
+				t.Errorf("range clause = %v, want field %s", filters[0]["range"], tt.wantTS)
+			}
+		})
+	}
+}
+
+func TestSearchDevicesFollowsAfterKey(t *testing.T) {
+	setupTestEnv(t)
+	global.EnvConfig.Detect.PageSize = 2
+
+	var requests int32
+	client := newTestESClient(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		var page map[string]interface{}
+		switch n {
+		case 1:
+			page = compositePageJSON("host-a", "host-b")
+			page["aggregations"].(map[string]interface{})["devices"].(map[string]interface{})["after_key"] = map[string]interface{}{"device": "host-b"}
+		default:
+			page = compositePageJSON("host-c")
+			page["_shards"] = map[string]interface{}{"total": 2, "successful": 1, "failed": 1}
+		}
+		json.NewEncoder(w).Encode(page)
+	})
+
+	result, err := SearchRequestWithClient(context.Background(), client, DeviceSearchParams{Index: "logs-*", Field: "host.name"})
+	if err != nil {
+		t.Fatalf("SearchRequestWithClient: %v", err)
+	}
+	if got := strings.Join(result.Keys(), ","); got != "host-a,host-b,host-c" {
+		t.Errorf("keys = %s, want all pages", got)
+	}
+	if result.Pages != 2 || !result.Partial {
+		t.Errorf("pages = %d, partial = %v, want 2 pages marked partial after the shard failure", result.Pages, result.Partial)
+	}
+}
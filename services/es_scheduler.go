@@ -93,6 +93,10 @@ func (s *ESMonitorScheduler) StartMonitor(monitor entities.ElasticsearchMonitor)
 
 	// 立即執行一次（不等待第一個 tick）
 	go func() {
+		// 多實例部署時只有 leader 執行監控
+		if !IsLeader() {
+			return
+		}
 		esService := NewESMonitorService()
 		log.Logrecord_no_rotate("INFO", fmt.Sprintf("Initial monitoring check for ES: %s (ID: %d)", monitor.Name, monitor.ID))
 		esService.MonitorESCluster(monitor)
@@ -104,6 +108,9 @@ func (s *ESMonitorScheduler) StartMonitor(monitor entities.ElasticsearchMonitor)
 		for {
 			select {
 			case <-ticker.C:
+				if !IsLeader() {
+					continue
+				}
 				log.Logrecord_no_rotate("DEBUG", fmt.Sprintf("Scheduled monitoring check for ES: %s (ID: %d)", monitor.Name, monitor.ID))
 				esService.MonitorESCluster(monitor)
 			case <-stopChan:
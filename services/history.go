@@ -34,25 +34,6 @@ func CreateHistory(hisroty entities.History) models.Response {
 	return res
 }
 
-// 新增 mail history
-func CreateMailHistory(mail_hisroty entities.MailHistory) models.Response {
-
-	res := models.Response{}
-	res.Success = false
-	res.Body = entities.Index{}
-
-	err := global.Mysql.Create(&mail_hisroty).Error
-	if err != nil {
-		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Create mail history Fail: %s", err.Error()))
-		res.Msg = "Create mail history Fail"
-		return res
-	}
-	res.Success = true
-	res.Body = mail_hisroty
-	res.Msg = "Create mail hisroty Success"
-	return res
-}
-
 func GetIndicesDataByLogname(logname string) (entities.Index, error) {
 
 	indices := entities.Index{}
@@ -69,26 +50,28 @@ func GetHistoryDataByDeviceName(logname string, name string) []entities.History
 	return GetHistoryDataByDeviceName_TS(logname, name)
 }
 
-func GenerateTimeArray(period string, unit int) []string {
+// GenerateTimeArray 依 index 的排程產生當天 00:00 到目前為止的所有檢查時間點
+func GenerateTimeArray(index entities.Index) []string {
 	var timeArray []string
 
-	now := time.Now()
+	spec, err := IndexSchedule(index)
+	if err != nil {
+		fmt.Println("Invalid schedule:", err)
+		return nil
+	}
+
+	now := time.Now().In(indexLocation(index))
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 
-	// 计算时间间隔
-	var duration time.Duration
-	switch period {
-	case "minutes":
-		duration = time.Minute * time.Duration(unit)
-	case "hours":
-		duration = time.Hour * time.Duration(unit)
-	default:
-		fmt.Println("Invalid period")
+	// 從當天 00:00 開始（含），根據排程生成時間數據數组
+	times, err := NextFireTimes(spec, startOfDay.Add(-time.Second), 24*60)
+	if err != nil {
 		return nil
 	}
-
-	// 從當天 00:00 開始，根據時間間隔生成時間數據數组
-	for t := startOfDay; t.Before(now); t = t.Add(duration) {
+	for _, t := range times {
+		if t.After(now) {
+			break
+		}
 		timeArray = append(timeArray, t.Format("15:04"))
 	}
 
@@ -112,11 +95,16 @@ func DataDealing(logname string) models.Response {
 	}
 	var history_final_data []entities.HistoryData
 
-	timeArray := GenerateTimeArray(indicesData.Period, indicesData.Unit)
+	timeArray := GenerateTimeArray(indicesData)
 	// fmt.Println(len(timeArray))
 
 	for _, device := range device_list {
 		history_data := GetHistoryDataByDeviceName(logname, device.Name)
+		// 除役與預期缺席中的設備由前端分開顯示
+		status := entities.DeviceActive
+		if !device.Monitored(time.Now()) {
+			status = device.Status
+		}
 		var history_tmp_data []entities.HistoryData
 		// fmt.Println("device.Name",device.Name)
 		// fmt.Println(history_data)
@@ -124,7 +112,7 @@ func DataDealing(logname string) models.Response {
 		historyMap := make(map[string]bool)
 
 		for _, data := range history_data {
-			history_tmp_data = append(history_tmp_data, entities.HistoryData{Name: data.Name, Time: data.Time, Lost: data.Lost})
+			history_tmp_data = append(history_tmp_data, entities.HistoryData{Name: data.Name, Time: data.Time, Lost: data.Lost, Status: status})
 			historyMap[data.Time] = true
 		}
 
@@ -133,7 +121,7 @@ func DataDealing(logname string) models.Response {
 			// 如果時間點不在歷史資料中，則添加新的记录
 			if _, ok := historyMap[timePoint]; !ok {
 				// history_tmp_data = append(history_tmp_data, entities.HistoryData{Name: device.Name, Time: timePoint, Lost: "false"})
-				history_tmp_data = append(history_tmp_data, entities.HistoryData{Name: device.Name, Time: timePoint, Lost: "none"})
+				history_tmp_data = append(history_tmp_data, entities.HistoryData{Name: device.Name, Time: timePoint, Lost: "none", Status: status})
 			}
 		}
 		// 扁平化 Array 將 history_tmp_data 中的每個元件塞入 history_final_data 中
@@ -157,26 +145,19 @@ func GetLognameData() models.Response {
 	return GetLognameData_TS()
 }
 
-func GetLastCrontabTime(now time.Time, period string, unit int) string {
-	var lastCrontabTime string
-
-	switch period {
-	case "minutes":
-		// 將當前時間調整到最接近的上一個符合條件的時間點
-		minutes := now.Minute()
-		adjustedMinutes := minutes - (minutes % unit)
-		lastCrontabTime = now.Add(-time.Duration(minutes-adjustedMinutes) * time.Minute).Format("15:04")
-	case "hours":
-		hour := now.Hour()
-		// fmt.Println("hour:", hour)
-		adjustedHour := hour - (hour % unit)
-		// fmt.Println("adjustedHour:", adjustedHour)
-		lastCrontabTime = time.Date(now.Year(), now.Month(), now.Day(), adjustedHour, 0, 0, 0, now.Location()).Format("15:04")
-		// default:
-		// 	lastCrontabTime = now
+// GetLastCrontabTime 取得 index 在 now 之前（含）最近一次的排程時間（HH:MM）
+func GetLastCrontabTime(now time.Time, index entities.Index) string {
+	spec, err := IndexSchedule(index)
+	if err != nil {
+		return ""
 	}
 
-	return lastCrontabTime
+	now = now.In(indexLocation(index))
+	last := LastFireTime(spec, now.Truncate(time.Minute).Add(time.Minute))
+	if last.IsZero() {
+		return ""
+	}
+	return last.Format("15:04")
 }
 
 // GetDashboardData 獲取儀表板數據
@@ -314,7 +295,8 @@ func CreateDailyAggregates(targetDate string) models.Response {
 			SUM(CASE WHEN status = 'warning' THEN 1 ELSE 0 END) as warning_count,
 			SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) as error_count,
 			ROUND(
-				(SUM(CASE WHEN status = 'online' THEN 1 ELSE 0 END) * 100.0) / COUNT(*),
+				(SUM(CASE WHEN status = 'online' THEN 1 ELSE 0 END) * 100.0) /
+				NULLIF(SUM(CASE WHEN status NOT IN ('maintenance', 'error') THEN 1 ELSE 0 END), 0),
 				2
 			) as uptime_rate,
 			ROUND(AVG(response_time), 2) as avg_response_time
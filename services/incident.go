@@ -0,0 +1,260 @@
+package services
+
+import (
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 設備事件類型與狀態
+const (
+	IncidentTypeOffline       = "offline"
+	IncidentTypeFlapping      = "flapping"
+	IncidentTypeLowVolume     = "low_volume"
+	IncidentTypeVolumeAnomaly = "volume_anomaly"
+
+	IncidentStatusActive       = "active"
+	IncidentStatusAcknowledged = "acknowledged"
+	IncidentStatusResolved     = "resolved"
+)
+
+// RecoveredDevice 本次檢查恢復的設備
+type RecoveredDevice struct {
+	Name      string        `json:"name"`
+	StartedAt int64         `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// DeviceIncidentService 設備事件生命週期（開啟 / 持續 / 恢復）
+// 事件以 (target, index, device) 為單位，同一個 index 掛在多個 target 時各自通知
+type DeviceIncidentService struct {
+	DryRun bool // 只計算會開啟 / 結束哪些事件，不寫入資料庫
+}
+
+// NewDeviceIncidentService 建立設備事件服務
+func NewDeviceIncidentService() *DeviceIncidentService {
+	return &DeviceIncidentService{}
+}
+
+// Open 記錄本次離線的設備，回傳新開啟事件的設備（已開啟的事件只更新檢查時間，不重複通知）
+func (s *DeviceIncidentService) Open(target entities.Target, index entities.Index, alertType string, severity string, devices []string, checkTime time.Time) []string {
+	messages := make(map[string]string, len(devices))
+	for _, device := range devices {
+		messages[device] = incidentMessage(alertType, index, device)
+	}
+	return s.OpenWithMessages(target, index, alertType, severity, devices, messages, checkTime)
+}
+
+// OpenWithMessages 同 Open，但由呼叫端提供每台設備的告警訊息（例如預期值與實際值）
+func (s *DeviceIncidentService) OpenWithMessages(target entities.Target, index entities.Index, alertType string, severity string, devices []string, messages map[string]string, checkTime time.Time) []string {
+	if len(devices) == 0 {
+		return nil
+	}
+
+	openIncidents, err := s.getOpenIncidents(target.ID, index.ID, alertType, devices)
+	if err != nil {
+		// 查詢失敗時寧可重複通知，也不要漏掉
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to load open incidents for %s: %s", index.Logname, err.Error()))
+		return devices
+	}
+
+	var opened []string
+	now := checkTime.Unix()
+	for _, device := range devices {
+		if s.DryRun {
+			if _, ok := openIncidents[device]; !ok {
+				opened = append(opened, device)
+			}
+			continue
+		}
+		if incident, ok := openIncidents[device]; ok {
+			err := global.Mysql.Model(&entities.AlertHistory{}).Where("id = ?", incident.ID).
+				Updates(map[string]interface{}{
+					"last_checked_at": now,
+					"check_count":     gorm.Expr("check_count + 1"),
+				}).Error
+			if err != nil {
+				log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to update incident %d: %s", incident.ID, err.Error()))
+			}
+			continue
+		}
+
+		incident := entities.AlertHistory{
+			Logname:       index.Logname,
+			DeviceGroup:   index.DeviceGroup,
+			DeviceName:    device,
+			TargetID:      target.ID,
+			IndexID:       index.ID,
+			AlertType:     alertType,
+			Severity:      severity,
+			Message:       messages[device],
+			Status:        IncidentStatusActive,
+			StartedAt:     now,
+			LastCheckedAt: now,
+			CheckCount:    1,
+		}
+		if err := global.Mysql.Create(&incident).Error; err != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to open incident for device %s: %s", device, err.Error()))
+		}
+		opened = append(opened, device)
+	}
+
+	return opened
+}
+
+// Resolve 將本次有資料的設備對應的未結事件標記為恢復，回傳恢復的設備與離線時間
+func (s *DeviceIncidentService) Resolve(target entities.Target, index entities.Index, alertType string, devices []string, checkTime time.Time) []RecoveredDevice {
+	if len(devices) == 0 {
+		return nil
+	}
+
+	openIncidents, err := s.getOpenIncidents(target.ID, index.ID, alertType, devices)
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to load open incidents for %s: %s", index.Logname, err.Error()))
+		return nil
+	}
+
+	var recovered []RecoveredDevice
+	now := checkTime.Unix()
+	for _, incident := range openIncidents {
+		duration := now - incident.StartedAt
+		if s.DryRun {
+			recovered = append(recovered, RecoveredDevice{
+				Name:      incident.DeviceName,
+				StartedAt: incident.StartedAt,
+				Duration:  time.Duration(duration) * time.Second,
+			})
+			continue
+		}
+		err := global.Mysql.Model(&entities.AlertHistory{}).Where("id = ?", incident.ID).
+			Updates(map[string]interface{}{
+				"status":      IncidentStatusResolved,
+				"resolved_at": now,
+				"resolved_by": "system",
+				"duration":    duration,
+			}).Error
+		if err != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to resolve incident %d: %s", incident.ID, err.Error()))
+			continue
+		}
+
+		recovered = append(recovered, RecoveredDevice{
+			Name:      incident.DeviceName,
+			StartedAt: incident.StartedAt,
+			Duration:  time.Duration(duration) * time.Second,
+		})
+	}
+
+	return recovered
+}
+
+// Retract 撤銷在 checkTime 這次檢查才開啟的事件（重新檢查後發現資料只是延遲到達），回傳被撤銷的設備
+// 更早開啟的事件代表設備先前就已離線，不在此撤銷，交由下一次檢查正常恢復
+func (s *DeviceIncidentService) Retract(target entities.Target, index entities.Index, alertType string, devices []string, checkTime time.Time) []string {
+	if len(devices) == 0 {
+		return nil
+	}
+
+	openIncidents, err := s.getOpenIncidents(target.ID, index.ID, alertType, devices)
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to load open incidents for %s: %s", index.Logname, err.Error()))
+		return nil
+	}
+
+	var retracted []string
+	now := time.Now().Unix()
+	for _, incident := range openIncidents {
+		if incident.StartedAt < checkTime.Unix() {
+			continue
+		}
+		err := global.Mysql.Model(&entities.AlertHistory{}).Where("id = ?", incident.ID).
+			Updates(map[string]interface{}{
+				"status":      IncidentStatusResolved,
+				"resolved_at": now,
+				"resolved_by": "recheck",
+				"duration":    0,
+				"message":     incident.Message + "（重新檢查後資料已到達，撤銷告警）",
+			}).Error
+		if err != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to retract incident %d: %s", incident.ID, err.Error()))
+			continue
+		}
+		retracted = append(retracted, incident.DeviceName)
+	}
+
+	return retracted
+}
+
+// GetOpenIncidents 取得所有未結束（active / acknowledged）的事件，Duration 以目前時間計算
+func (s *DeviceIncidentService) GetOpenIncidents(logname string, alertType string) ([]entities.AlertHistory, error) {
+	query := global.Mysql.Model(&entities.AlertHistory{}).
+		Where("status IN ?", []string{IncidentStatusActive, IncidentStatusAcknowledged})
+	if logname != "" {
+		query = query.Where("logname = ?", logname)
+	}
+	if alertType != "" {
+		query = query.Where("alert_type = ?", alertType)
+	}
+
+	var incidents []entities.AlertHistory
+	if err := query.Order("started_at ASC").Find(&incidents).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	for i := range incidents {
+		if incidents[i].StartedAt > 0 {
+			incidents[i].Duration = now - incidents[i].StartedAt
+		}
+	}
+	return incidents, nil
+}
+
+// getOpenIncidents 依設備名稱取得指定 target/index 的未結事件
+func (s *DeviceIncidentService) getOpenIncidents(targetID int, indexID int, alertType string, devices []string) (map[string]entities.AlertHistory, error) {
+	var incidents []entities.AlertHistory
+	err := global.Mysql.
+		Where("target_id = ? AND index_id = ? AND alert_type = ? AND status IN ? AND device_name IN ?",
+			targetID, indexID, alertType, []string{IncidentStatusActive, IncidentStatusAcknowledged}, devices).
+		Find(&incidents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]entities.AlertHistory, len(incidents))
+	for _, incident := range incidents {
+		result[incident.DeviceName] = incident
+	}
+	return result, nil
+}
+
+// incidentMessage 依事件類型產生告警訊息
+func incidentMessage(alertType string, index entities.Index, device string) string {
+	switch alertType {
+	case IncidentTypeFlapping:
+		return fmt.Sprintf("設備 %s 在 %d 分鐘內頻繁上線/離線", device, index.FlapWindow)
+	case IncidentTypeLowVolume:
+		return fmt.Sprintf("%s 日誌中設備 %s 的日誌量低於門檻", index.Logname, device)
+	case IncidentTypeVolumeAnomaly:
+		return fmt.Sprintf("%s 日誌中設備 %s 的日誌量與歷史同時段差異過大", index.Logname, device)
+	}
+	return fmt.Sprintf("%s 日誌中找不到設備 %s", index.Logname, device)
+}
+
+// FormatOutageDuration 將離線時間轉為易讀格式
+func FormatOutageDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours >= 24 {
+		return fmt.Sprintf("%d 天 %d 小時 %d 分", hours/24, hours%24, minutes)
+	}
+	if hours > 0 {
+		return fmt.Sprintf("%d 小時 %d 分", hours, minutes)
+	}
+	return fmt.Sprintf("%d 分", minutes)
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatOutageDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{d: 0, want: "0 分"},
+		{d: 29 * time.Second, want: "0 分"},
+		{d: 30 * time.Second, want: "1 分"},
+		{d: 59 * time.Minute, want: "59 分"},
+		{d: time.Hour, want: "1 小時 0 分"},
+		{d: 2*time.Hour + 5*time.Minute + 40*time.Second, want: "2 小時 6 分"},
+		{d: 23*time.Hour + 59*time.Minute + 31*time.Second, want: "1 天 0 小時 0 分"},
+		{d: 50*time.Hour + 3*time.Minute, want: "2 天 2 小時 3 分"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.d.String(), func(t *testing.T) {
+			if got := FormatOutageDuration(tt.d); got != tt.want {
+				t.Errorf("FormatOutageDuration(%v) = %q, want %q", tt.d, got, tt.want)
+			}
+		})
+	}
+}
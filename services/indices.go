@@ -21,6 +21,37 @@ func CreateIndices(indices entities.Index) models.Response {
 	// 	return res
 	// }
 
+	if _, err := indices.Location(); err != nil {
+		res.Msg = fmt.Sprintf("Invalid timezone: %s", indices.Timezone)
+		return res
+	}
+	if indices.GraceDelay < 0 || indices.RecheckDelay < 0 {
+		res.Msg = "grace_delay and recheck_delay must not be negative"
+		return res
+	}
+	if indices.QueryTimeout < 0 {
+		res.Msg = "query_timeout must not be negative"
+		return res
+	}
+	if err := ValidateIndexSchedule(indices); err != nil {
+		res.Msg = fmt.Sprintf("Invalid schedule: %s", err.Error())
+		return res
+	}
+
+	// 驗證過濾條件
+	if err := ValidateIndexFilter(indices); err != nil {
+		res.Msg = fmt.Sprintf("Invalid filter: %s", err.Error())
+		return res
+	}
+	if err := ValidateNameNormalization(indices); err != nil {
+		res.Msg = fmt.Sprintf("Invalid name normalization: %s", err.Error())
+		return res
+	}
+
+	if err := ensureDeviceGroups(global.Mysql, indices.DeviceGroup); err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Create device group Fail: %s", err.Error()))
+	}
+
 	err := global.Mysql.Create(&indices).Error
 	if err != nil {
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Create indices Fail: %s", err.Error()))
@@ -38,50 +69,85 @@ func CreateIndices(indices entities.Index) models.Response {
 	return res
 }
 
-func UpdateIndices(indices entities.Index) models.Response {
+// indexUpdateColumns 更新 index 時允許寫入的欄位
+var indexUpdateColumns = []string{
+	"pattern", "device_group", "logname", "period", "unit", "field", "cron_expr",
+	"timestamp_field", "timezone", "grace_delay", "recheck_delay", "query_timeout",
+	"filter_type", "filter_query", "miss_threshold", "flap_window", "flap_threshold", "min_doc_count",
+	"normalize_lowercase", "normalize_strip_domain", "normalize_rewrites", "auto_add_devices",
+	"anomaly_detection", "anomaly_sensitivity", "anomaly_baseline_weeks", "es_connection_id",
+}
+
+// UpdateIndices 更新 index；只寫入 fields 中送出的欄位，未送出的欄位保留原值（fields 為 nil 時寫入全部欄位）
+func UpdateIndices(update entities.Index, fields []string) models.Response {
 
 	res := models.Response{}
 	res.Success = false
 	res.Body = []entities.Index{}
 
-	entriesTable, err := GetEntryByIndiceID(indices.ID)
-	if err != nil {
-		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Error when get data in CronList: %s", err.Error()))
-		res.Msg = fmt.Sprintf("Error when get data in CronList, err: %s", err.Error())
+	var indices entities.Index
+	if err := global.Mysql.Where("id = ?", update.ID).First(&indices).Error; err != nil {
+		res.Msg = "indices ID does not exist"
 		return res
 	}
+	stored := indices
+	columns := selectUpdateColumns(indexUpdateColumns, fields)
+	overlayColumns(&indices, &update, columns)
 
-	// 找出目前 Entries 中 與CronList 對應的 entryID 並刪除 Entries 中的 entry
-	entries := global.Crontab.Entries()
-	for _, entry := range entries {
-		for _, data := range entriesTable {
-			if data.EntryID == int(entry.ID) {
-				global.Crontab.Remove(entry.ID)
-			}
-		}
+	if _, err := indices.Location(); err != nil {
+		res.Msg = fmt.Sprintf("Invalid timezone: %s", indices.Timezone)
+		return res
+	}
+	if indices.GraceDelay < 0 || indices.RecheckDelay < 0 {
+		res.Msg = "grace_delay and recheck_delay must not be negative"
+		return res
+	}
+	if indices.QueryTimeout < 0 {
+		res.Msg = "query_timeout must not be negative"
+		return res
+	}
+	// 未修改排程的舊版 index 沿用固定間隔，修改排程時須符合新的規則
+	scheduleChanged := indices.Period != stored.Period || indices.Unit != stored.Unit ||
+		indices.CronExpr != stored.CronExpr || indices.Timezone != stored.Timezone
+	scheduleErr := ValidateIndexSchedule(indices)
+	if !scheduleChanged {
+		_, scheduleErr = IndexSchedule(indices)
+	}
+	if scheduleErr != nil {
+		res.Msg = fmt.Sprintf("Invalid schedule: %s", scheduleErr.Error())
+		return res
 	}
 
-	// 先取得 CronList 中 index_id 對應的 target_id
-	targetdata, err := GetTargetIDByIndiceID(indices.ID)
-	if err != nil {
-		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Get TargetID By IndiceID Fail: %s", err.Error()))
+	// 驗證過濾條件（在移除排程之前，避免驗證失敗時排程消失）
+	if err := ValidateIndexFilter(indices); err != nil {
+		res.Msg = fmt.Sprintf("Invalid filter: %s", err.Error())
+		return res
+	}
+	if err := ValidateNameNormalization(indices); err != nil {
+		res.Msg = fmt.Sprintf("Invalid name normalization: %s", err.Error())
+		return res
 	}
 
-	// 刪除 CronList 中對應的 entry ID
-	err = global.Mysql.Where("index_id = ?", indices.ID).Delete(&entities.CronList{}).Error
-	if err != nil {
-		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Error when deleting data in CronList table: %s", err.Error()))
-		res.Msg = fmt.Sprintf("Error when deleting data in CronList table, err: %s", err.Error())
+	if err := ensureDeviceGroups(global.Mysql, indices.DeviceGroup); err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Create device group Fail: %s", err.Error()))
+	}
+
+	if len(columns) == 0 {
+		res.Success = true
+		res.Body = indices
+		res.Msg = "Update indice Success"
 		return res
 	}
 
-	err = global.Mysql.Select("*").Where("id = ?", indices.ID).Updates(&indices).Error
+	// 明確指定欄位，讓 auto_add_devices=false 等零值也能被更新
+	err := global.Mysql.Model(&entities.Index{}).Where("id = ?", indices.ID).Select(columns).Updates(&indices).Error
 	if err != nil {
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Update indices Fail: %s", err.Error()))
 		res.Msg = "Update Fail"
 		return res
 	} else {
-		Control_center_by_IndiceID(indices.ID, targetdata)
+		// 依新設定重新排程該 index
+		Control_center_by_IndiceID(indices.ID)
 	}
 
 	res.Success = true
@@ -105,30 +171,8 @@ func DeleteIndice(id int) models.Response {
 		return res
 	}
 
-	entriesTable, lerr := GetEntryByIndiceID(id)
-	if lerr != nil {
-		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Error when get data in CronList, err: %s", lerr.Error()))
-		res.Msg = fmt.Sprintf("Error when get data in CronList, err: %s", lerr)
-		return res
-	}
-
-	// 找出目前 Entries 中 與 CronList 對應的 entryID 並刪除 Entries 中的 entry
-	entries := global.Crontab.Entries()
-	for _, entry := range entries {
-		for _, data := range entriesTable {
-			if data.EntryID == int(entry.ID) {
-				global.Crontab.Remove(entry.ID)
-			}
-		}
-	}
-
-	// 刪除 CronList 中對應的 entry ID
-	entry_err := global.Mysql.Where("index_id = ?", id).Delete(&entities.CronList{}).Error
-	if entry_err != nil {
-		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Error when deleting data in CronList table, err: %s", entry_err.Error()))
-		res.Msg = fmt.Sprintf("Error when deleting data in CronList table, err: %s", entry_err.Error())
-		return res
-	}
+	// 移除該 index 在所有 target 下的排程
+	GetSchedulerRegistry().RemoveIndex(id)
 
 	err := global.Mysql.Where("id = ?", id).Delete(&entities.Index{}).Error
 	if err != nil {
@@ -137,6 +181,9 @@ func DeleteIndice(id int) models.Response {
 		return res
 	}
 
+	// 清除該 index 的設備偵測狀態
+	NewDeviceStateService().ResetStates(id)
+
 	res.Success = true
 	res.Msg = "Delete indice Success"
 
@@ -178,20 +225,6 @@ func GetIndicesByTargetID(TargetID int) ([]entities.Index, error) {
 
 //// 供程序處理 func
 
-func GetEntryByIndiceID(IndicesID int) ([]entities.CronList, error) {
-	cronlist := []entities.CronList{}
-	// err := global.Mysql.Debug().Where("id = ?",scheduleID).Preload("Reports").Find(&schedule).Error
-
-	err := global.Mysql.Debug().Where("index_id = ?", IndicesID).Find(&cronlist).Error
-	if err != nil {
-		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Get Entry By IndiceID err: %s", err.Error()))
-		return cronlist, err
-	}
-
-	return cronlist, nil
-
-}
-
 func GetLogname() models.Response {
 
 	res := models.Response{}
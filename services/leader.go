@@ -0,0 +1,176 @@
+package services
+
+import (
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// schedulerLeaseName 偵測排程與 ES 監控共用的 leader 租約
+const schedulerLeaseName = "scheduler"
+
+// LeaderElector 以 MySQL 租約選出唯一執行排程的實例，leader 停止續約後由其他實例在租約到期時接手
+type LeaderElector struct {
+	enabled    bool
+	instanceID string
+	ttl        time.Duration
+	renew      time.Duration
+	leader     atomic.Bool
+
+	mutex      sync.Mutex
+	validUntil time.Time // 最後一次成功續約後，本地認定租約仍有效的時間
+}
+
+var GlobalLeader *LeaderElector
+
+// InitLeaderElection 依 HA 設定建立 leader elector；啟用時立即嘗試取得租約並在背景定期續約
+// 啟用 HA 但無法寫入租約時回傳錯誤，避免各實例都以為自己可以執行排程
+func InitLeaderElection() error {
+	cfg := global.EnvConfig.HA
+
+	e := &LeaderElector{
+		enabled:    cfg.Enabled,
+		instanceID: cfg.InstanceID,
+		ttl:        parseDurationOr(cfg.LeaseTTL, 30*time.Second),
+		renew:      parseDurationOr(cfg.RenewInterval, 10*time.Second),
+	}
+	if e.instanceID == "" {
+		e.instanceID, _ = os.Hostname()
+	}
+	if e.instanceID == "" {
+		e.instanceID = "log-detect"
+	}
+	if e.renew >= e.ttl {
+		e.renew = e.ttl / 3
+	}
+	GlobalLeader = e
+
+	if !e.enabled {
+		log.Logrecord_no_rotate("INFO", fmt.Sprintf("HA disabled, instance %s runs all schedules", e.instanceID))
+		return nil
+	}
+
+	log.Logrecord_no_rotate("INFO", fmt.Sprintf("HA enabled, instance %s joining leader election (ttl %s, renew %s)", e.instanceID, e.ttl, e.renew))
+	if err := e.tick(); err != nil {
+		return fmt.Errorf("cannot write scheduler lease: %w", err)
+	}
+	go func() {
+		ticker := time.NewTicker(e.renew)
+		defer ticker.Stop()
+		for range ticker.C {
+			e.tick()
+		}
+	}()
+	return nil
+}
+
+// IsLeader 目前實例是否應執行排程工作（未啟用 HA 時一律為 true）
+func IsLeader() bool {
+	if GlobalLeader == nil || !GlobalLeader.enabled {
+		return true
+	}
+	return GlobalLeader.leader.Load()
+}
+
+// InstanceID 目前實例的識別名稱
+func InstanceID() string {
+	if GlobalLeader == nil {
+		hostname, _ := os.Hostname()
+		return hostname
+	}
+	return GlobalLeader.instanceID
+}
+
+// tick 取得或續約租約；資料庫暫時失敗時，在本地租約有效期間內維持 leader
+func (e *LeaderElector) tick() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	start := time.Now()
+	isLeader, err := e.acquire()
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Leader election failed on %s: %s", e.instanceID, err.Error()))
+		isLeader = e.leader.Load() && time.Now().Before(e.validUntil)
+	} else if isLeader {
+		e.validUntil = start.Add(e.ttl)
+	}
+
+	if was := e.leader.Swap(isLeader); was != isLeader {
+		if isLeader {
+			log.Logrecord_no_rotate("INFO", fmt.Sprintf("Instance %s became scheduler leader", e.instanceID))
+		} else {
+			log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Instance %s lost scheduler leadership", e.instanceID))
+		}
+	}
+	return err
+}
+
+// acquire 租約已到期或原本由自己持有時寫入自己為 holder，回傳目前是否為 leader
+// 到期判斷使用資料庫時間，避免實例間時鐘不同步
+func (e *LeaderElector) acquire() (bool, error) {
+	err := global.Mysql.Exec(
+		"INSERT IGNORE INTO scheduler_leases (name, holder, acquired_at, renewed_at, expires_at) VALUES (?, '', 0, 0, 0)",
+		schedulerLeaseName).Error
+	if err != nil {
+		return false, err
+	}
+
+	// acquired_at 需在 holder 之前計算，才能判斷是否為新取得
+	err = global.Mysql.Exec(`
+		UPDATE scheduler_leases
+		SET acquired_at = IF(holder = ?, acquired_at, UNIX_TIMESTAMP()),
+		    holder = ?,
+		    renewed_at = UNIX_TIMESTAMP(),
+		    expires_at = UNIX_TIMESTAMP() + ?
+		WHERE name = ? AND (holder = ? OR expires_at <= UNIX_TIMESTAMP())`,
+		e.instanceID, e.instanceID, int64(e.ttl.Seconds()), schedulerLeaseName, e.instanceID).Error
+	if err != nil {
+		return false, err
+	}
+
+	var lease entities.SchedulerLease
+	if err := global.Mysql.Where("name = ?", schedulerLeaseName).First(&lease).Error; err != nil {
+		return false, err
+	}
+	return lease.Holder == e.instanceID, nil
+}
+
+// GetLeaderStatus 取得目前實例與 leader 租約的狀態
+func GetLeaderStatus() models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	status := entities.LeaderStatus{
+		Enabled:    GlobalLeader != nil && GlobalLeader.enabled,
+		InstanceID: InstanceID(),
+		IsLeader:   IsLeader(),
+	}
+
+	if status.Enabled {
+		if err := global.Mysql.Where("name = ?", schedulerLeaseName).First(&status.Lease).Error; err != nil {
+			res.Msg = fmt.Sprintf("Failed to get scheduler lease: %s", err.Error())
+			log.Logrecord_no_rotate("ERROR", res.Msg)
+			return res
+		}
+	}
+
+	res.Success = true
+	res.Msg = "Get leader status success"
+	res.Body = status
+	return res
+}
+
+// parseDurationOr 解析設定中的時間長度，空白或格式錯誤時使用預設值
+func parseDurationOr(value string, fallback time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
@@ -3,7 +3,9 @@ package services
 import (
 	"errors"
 	"fmt"
+	"html"
 	"log"
+	"log-detect/entities"
 	"log-detect/global"
 	"net/smtp"
 	// "log-detect/utils"
@@ -14,31 +16,55 @@ import (
 	"time"
 )
 
-func Mail4(receiver, cc, bcc []string, subject string, logname string, removed []string) {
-	// Load 環境參數
-	user := global.EnvConfig.Email.User
-	password := global.EnvConfig.Email.Password
-	host := global.EnvConfig.Email.Host
-	port := global.EnvConfig.Email.Port
+// Mail4 寄送失聯主機通知，維護中的設備不列入
+func Mail4(receiver, cc, bcc []string, subject string, logname string, removed []string) error {
+	if index, err := GetIndicesDataByLogname(logname); err == nil {
+		removed, _ = NewMaintenanceMatcher(entities.Target{}, index, time.Now()).Filter(removed)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	body := BuildMailBody(subject, fmt.Sprintf("%s 日誌，失聯主機如下：", logname), removed)
+	return SendHTMLMail(receiver, cc, bcc, subject, body)
+}
 
-	// body := fmt.Sprintf("%s 日誌，失聯主機:%s", logname, removed)
+// BuildMailBody 組裝 HTML 郵件內容（說明文字 + 明細表格）
+func BuildMailBody(subject string, intro string, items []string) string {
+	return BuildDeviceMailBody(subject, intro, items, nil)
+}
 
-	// 將 removed 數組轉換為 HTML 表格
+// BuildDeviceMailBody 組裝 HTML 郵件內容，devices 與 items 一一對應時加上設備屬性欄位
+func BuildDeviceMailBody(subject string, intro string, items []string, devices []NotificationDevice) string {
+	withDevices := len(devices) > 0 && len(devices) == len(items)
+
+	// 將 items 數組轉換為 HTML 表格
+	headers := ""
+	if withDevices {
+		headers = "<th>Criticality</th><th>Owner</th><th>Site</th><th>Environment</th><th>Tags</th>"
+	}
 	tableRows := ""
-	for i, item := range removed {
-		tableRows += fmt.Sprintf("<tr><td>%d</td><td>%s</td></tr>", i+1, item)
+	for i, item := range items {
+		cells := ""
+		if withDevices {
+			d := devices[i]
+			for _, value := range []string{d.Criticality, d.Owner, d.Site, d.Environment, strings.Join(d.Tags, ", ")} {
+				cells += fmt.Sprintf("<td>%s</td>", html.EscapeString(value))
+			}
+		}
+		tableRows += fmt.Sprintf("<tr><td>%d</td><td>%s</td>%s</tr>", i+1, item, cells)
 	}
 	table := fmt.Sprintf(`
 		<table border="2" style="border-collapse:collapse;table-layout:auto;text-align:left;">
 			<tr>
 				<th>#</th>
 				<th>Host</th>
+				%s
 			</tr>
 			%s
-		</table>`, tableRows)
+		</table>`, headers, tableRows)
 
 	// 組裝 HTML 內容
-	body := fmt.Sprintf(`
+	return fmt.Sprintf(`
 		<!DOCTYPE html>
 		<html>
 		<head>
@@ -58,23 +84,20 @@ func Mail4(receiver, cc, bcc []string, subject string, logname string, removed [
 			</style>			
 		</head>
 		<body>
-			<p>%s 日誌，失聯主機如下：</p>
+			<p>%s</p>
 			%s
 		</body>
-		</html>`, subject, logname, table)
-
-	// r := NewRequest(receiver, cc, bcc, subject, body)
+		</html>`, subject, intro, table)
+}
 
-	// fmt.Println("\ntest func mailWithNoAuth")
-	// ok, mailError := r.SendEmailTest4()
-	// if mailError != nil {
-	// 	fmt.Println("Fail to send Email")
-	// }
-	// if ok {
-	// 	fmt.Println("Success to send Email")
-	// }
+// SendHTMLMail 依 email 設定寄送 HTML 郵件
+func SendHTMLMail(receiver, cc, bcc []string, subject string, body string) error {
+	// Load 環境參數
+	user := global.EnvConfig.Email.User
+	password := global.EnvConfig.Email.Password
+	host := global.EnvConfig.Email.Host
+	port := global.EnvConfig.Email.Port
 
-	// fmt.Println("\ntest func complex Mail")
 	var mail Mail
 
 	if user == "" {
@@ -82,37 +105,36 @@ func Mail4(receiver, cc, bcc []string, subject string, logname string, removed [
 	} else {
 		mail = &SendMail{user: user, password: password, host: host, port: port}
 	}
-	// fmt.Println("mail",mail)
 
 	message := Message{
 		from:        global.EnvConfig.Email.Sender,
 		to:          receiver,
+		cc:          compactAddresses(cc),
+		bcc:         compactAddresses(bcc),
 		subject:     subject,
 		body:        body,
 		contentType: "text/html;charset=utf-8",
-		// attachment: Attachment{
-		//     name:        "test.jpg",
-		//     contentType: "image/jpg",
-		//     withFile:    true,
-		// },
-		// attachment: Attachment{
-		//     // name:        "/Users/chen/Documents/gitlab/git-out/product/report-backend/pdf/pdf_file/report01 2022-09-07 08:00:00~2022-09-07 16:24:25.pdf",
-		//     name: pdfPath,
-		//     contentType: "application/octet-stream",
-		//     withFile:    true,
-		// },
 	}
 
 	err := newFunction(mail, message)
 	if err != nil {
 		fmt.Println("Fail to send Email")
 		fmt.Println(err)
-	} else {
-		fmt.Println("Success to send Email")
+		return err
 	}
+	fmt.Println("Success to send Email")
+	return nil
+}
 
-	// fmt.Println("\nEmail test finish")
-
+// compactAddresses 移除空白的收件地址
+func compactAddresses(addresses []string) []string {
+	var result []string
+	for _, addr := range addresses {
+		if strings.TrimSpace(addr) != "" {
+			result = append(result, addr)
+		}
+	}
+	return result
 }
 
 func (r *Request) SendEmailTest4() (bool, error) {
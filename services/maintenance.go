@@ -0,0 +1,237 @@
+package services
+
+import (
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"strconv"
+	"time"
+)
+
+// StatusMaintenance 維護時段內的檢查結果狀態（不計入可用率）
+const StatusMaintenance = "maintenance"
+
+// GetAllMaintenanceWindows 取得所有維護時段
+func GetAllMaintenanceWindows() models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = []entities.MaintenanceWindow{}
+
+	var windows []entities.MaintenanceWindow
+	if err := global.Mysql.Order("id DESC").Find(&windows).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to get maintenance windows: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Get all maintenance windows success"
+	res.Body = windows
+	return res
+}
+
+// GetActiveMaintenanceWindows 取得目前生效中的維護時段
+func GetActiveMaintenanceWindows() models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = []entities.MaintenanceWindow{}
+
+	windows, err := loadEnabledMaintenanceWindows()
+	if err != nil {
+		res.Msg = fmt.Sprintf("Failed to get maintenance windows: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	active := []entities.MaintenanceWindow{}
+	now := time.Now()
+	for _, window := range windows {
+		if MaintenanceActive(window, now) {
+			active = append(active, window)
+		}
+	}
+
+	res.Success = true
+	res.Msg = "Get active maintenance windows success"
+	res.Body = active
+	return res
+}
+
+// CreateMaintenanceWindow 建立維護時段
+func CreateMaintenanceWindow(window entities.MaintenanceWindow) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if err := validateMaintenanceWindow(window); err != nil {
+		res.Msg = fmt.Sprintf("Invalid maintenance window: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	if err := global.Mysql.Create(&window).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to create maintenance window: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Create maintenance window success"
+	res.Body = window
+	return res
+}
+
+// UpdateMaintenanceWindow 更新維護時段
+func UpdateMaintenanceWindow(window entities.MaintenanceWindow) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if err := global.Mysql.Where("id = ?", window.ID).First(&entities.MaintenanceWindow{}).Error; err != nil {
+		res.Msg = fmt.Sprintf("Maintenance window not found: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	if err := validateMaintenanceWindow(window); err != nil {
+		res.Msg = fmt.Sprintf("Invalid maintenance window: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	// 明確指定欄位，讓 enable=false 等零值也能被更新
+	if err := global.Mysql.Model(&entities.MaintenanceWindow{}).Where("id = ?", window.ID).
+		Select("name", "scope_type", "scope_value", "start_at", "end_at", "cron_expr", "duration", "timezone", "enable", "description").
+		Updates(&window).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to update maintenance window: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Update maintenance window success"
+	res.Body = window
+	return res
+}
+
+// DeleteMaintenanceWindow 刪除維護時段
+func DeleteMaintenanceWindow(id int) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if err := global.Mysql.Where("id = ?", id).Delete(&entities.MaintenanceWindow{}).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to delete maintenance window: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Delete maintenance window success"
+	return res
+}
+
+// validateMaintenanceWindow 驗證欄位與週期維護的 cron 語法
+func validateMaintenanceWindow(window entities.MaintenanceWindow) error {
+	if err := window.Validate(); err != nil {
+		return err
+	}
+	if window.IsRecurring() {
+		if _, err := BuildSchedule(PeriodCron, 0, window.CronExpr, window.Timezone); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MaintenanceActive 判斷維護時段在 t 是否生效
+func MaintenanceActive(window entities.MaintenanceWindow, t time.Time) bool {
+	if !window.Enable {
+		return false
+	}
+	now := t.Unix()
+
+	if !window.IsRecurring() {
+		return now >= window.StartAt && now < window.EndAt
+	}
+
+	if window.StartAt > 0 && now < window.StartAt {
+		return false
+	}
+	if window.EndAt > 0 && now >= window.EndAt {
+		return false
+	}
+	spec, err := BuildSchedule(PeriodCron, 0, window.CronExpr, window.Timezone)
+	if err != nil {
+		return false
+	}
+	// 最近一次開始時間（含 t 當下）加上持續時間仍涵蓋 t
+	start := LastFireTime(spec, t.Add(time.Second))
+	if start.IsZero() {
+		return false
+	}
+	return t.Before(start.Add(time.Duration(window.Duration) * time.Minute))
+}
+
+// loadEnabledMaintenanceWindows 讀取所有啟用中的維護時段
+func loadEnabledMaintenanceWindows() ([]entities.MaintenanceWindow, error) {
+	var windows []entities.MaintenanceWindow
+	err := global.Mysql.Where("enable = ?", true).Find(&windows).Error
+	return windows, err
+}
+
+// MaintenanceMatcher 某次檢查適用的維護範圍
+type MaintenanceMatcher struct {
+	all     bool            // target / index / 整個設備群組都在維護中
+	devices map[string]bool // 個別設備維護
+}
+
+// NewMaintenanceMatcher 取得 target / index 在 t 生效中的維護範圍，讀取失敗時視為沒有維護
+func NewMaintenanceMatcher(target entities.Target, index entities.Index, t time.Time) MaintenanceMatcher {
+	matcher := MaintenanceMatcher{devices: map[string]bool{}}
+
+	windows, err := loadEnabledMaintenanceWindows()
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to load maintenance windows: %s", err.Error()))
+		return matcher
+	}
+
+	// 上層群組的維護時段也涵蓋下層群組
+	groups := map[string]bool{}
+	for _, group := range deviceGroupLineage(index.DeviceGroup) {
+		groups[group.Name] = true
+	}
+
+	for _, window := range windows {
+		if !MaintenanceActive(window, t) {
+			continue
+		}
+		switch window.ScopeType {
+		case entities.MaintenanceScopeTarget:
+			matcher.all = matcher.all || (target.ID != 0 && window.ScopeValue == strconv.Itoa(target.ID))
+		case entities.MaintenanceScopeIndex:
+			matcher.all = matcher.all || window.ScopeValue == strconv.Itoa(index.ID)
+		case entities.MaintenanceScopeDeviceGroup:
+			matcher.all = matcher.all || groups[window.ScopeValue]
+		case entities.MaintenanceScopeDevice:
+			matcher.devices[window.ScopeValue] = true
+		}
+	}
+	return matcher
+}
+
+// Covers 設備是否在維護中
+func (m MaintenanceMatcher) Covers(device string) bool {
+	return m.all || m.devices[device]
+}
+
+// Filter 將清單分為需通知與維護中的設備
+func (m MaintenanceMatcher) Filter(devices []string) (notify []string, silenced []string) {
+	for _, device := range devices {
+		if m.Covers(device) {
+			silenced = append(silenced, device)
+			continue
+		}
+		notify = append(notify, device)
+	}
+	return notify, silenced
+}
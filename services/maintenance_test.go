@@ -0,0 +1,74 @@
+package services
+
+import (
+	"log-detect/entities"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceActive(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	unix := func(s string) int64 { return at(s).Unix() }
+
+	once := entities.MaintenanceWindow{Enable: true, StartAt: unix("2026-10-17T08:00:00Z"), EndAt: unix("2026-10-17T10:00:00Z")}
+	// 每週六台北時間 02:00 起 120 分鐘
+	weekly := entities.MaintenanceWindow{Enable: true, CronExpr: "0 2 * * 6", Duration: 120, Timezone: "Asia/Taipei"}
+	bounded := weekly
+	bounded.StartAt = unix("2026-10-09T00:00:00Z")
+	bounded.EndAt = unix("2026-10-16T00:00:00Z")
+	disabled := once
+	disabled.Enable = false
+	invalid := entities.MaintenanceWindow{Enable: true, CronExpr: "not a cron", Duration: 60}
+
+	tests := []struct {
+		name   string
+		window entities.MaintenanceWindow
+		t      string
+		want   bool
+	}{
+		{name: "one-off before", window: once, t: "2026-10-17T07:59:59Z", want: false},
+		{name: "one-off at start", window: once, t: "2026-10-17T08:00:00Z", want: true},
+		{name: "one-off inside", window: once, t: "2026-10-17T09:30:00Z", want: true},
+		{name: "one-off at end", window: once, t: "2026-10-17T10:00:00Z", want: false},
+		{name: "disabled", window: disabled, t: "2026-10-17T09:00:00Z", want: false},
+
+		// 2026-10-17 為週六，台北 02:00 = UTC 前一天 18:00
+		{name: "recurring at start", window: weekly, t: "2026-10-16T18:00:00Z", want: true},
+		{name: "recurring inside", window: weekly, t: "2026-10-16T19:59:00Z", want: true},
+		{name: "recurring after duration", window: weekly, t: "2026-10-16T20:00:00Z", want: false},
+		{name: "recurring before start", window: weekly, t: "2026-10-16T17:59:00Z", want: false},
+		{name: "recurring other day", window: weekly, t: "2026-10-14T19:00:00Z", want: false},
+		{name: "recurring within range", window: bounded, t: "2026-10-09T19:00:00Z", want: true},
+		{name: "recurring after end_at", window: bounded, t: "2026-10-16T19:00:00Z", want: false},
+		{name: "recurring before start_at", window: bounded, t: "2026-10-02T19:00:00Z", want: false},
+		{name: "invalid cron", window: invalid, t: "2026-10-17T00:00:00Z", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaintenanceActive(tt.window, at(tt.t)); got != tt.want {
+				t.Errorf("MaintenanceActive() at %s = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaintenanceMatcherFilter(t *testing.T) {
+	m := MaintenanceMatcher{devices: map[string]bool{"fw01": true}}
+	notify, silenced := m.Filter([]string{"fw01", "fw02"})
+	if len(notify) != 1 || notify[0] != "fw02" || len(silenced) != 1 || silenced[0] != "fw01" {
+		t.Errorf("Filter() = %v, %v", notify, silenced)
+	}
+
+	m.all = true
+	notify, silenced = m.Filter([]string{"fw01", "fw02"})
+	if len(notify) != 0 || len(silenced) != 2 {
+		t.Errorf("Filter() with whole scope = %v, %v", notify, silenced)
+	}
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -16,6 +17,13 @@ import (
 func RunMigrations() error {
 	log.Println("Starting database migrations...")
 
+	// 多個實例同時啟動時，以 MySQL named lock 確保只有一個實例執行 migration
+	release, err := acquireMigrationLock()
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer release()
+
 	// 執行 MySQL migrations
 	if err := runMySQLMigrations(); err != nil {
 		return fmt.Errorf("MySQL migration failed: %w", err)
@@ -30,6 +38,36 @@ func RunMigrations() error {
 	return nil
 }
 
+// migrationLockTimeout 等待其他實例完成 migration 的秒數
+const migrationLockTimeout = 300
+
+// acquireMigrationLock 取得 MySQL GET_LOCK，回傳釋放函式（鎖綁定在取得它的連線上）
+func acquireMigrationLock() (func(), error) {
+	db, err := global.Mysql.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(context.Background(), "SELECT GET_LOCK('log-detect:migrations', ?)", migrationLockTimeout).Scan(&got); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !got.Valid || got.Int64 != 1 {
+		conn.Close()
+		return nil, fmt.Errorf("timed out after %d seconds", migrationLockTimeout)
+	}
+
+	return func() {
+		conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK('log-detect:migrations')")
+		conn.Close()
+	}, nil
+}
+
 // runMySQLMigrations 執行 MySQL migrations
 func runMySQLMigrations() error {
 	db, err := global.Mysql.DB()
@@ -174,8 +212,8 @@ func executeSQL(db *sql.DB, sqlContent string) error {
 	statements := splitSQL(sqlContent)
 
 	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" || strings.HasPrefix(stmt, "--") {
+		stmt = stripLeadingComments(stmt)
+		if stmt == "" {
 			continue
 		}
 		if _, err := db.Exec(stmt); err != nil {
@@ -186,6 +224,20 @@ func executeSQL(db *sql.DB, sqlContent string) error {
 	return nil
 }
 
+// stripLeadingComments 移除語句前方的註解行
+// 區段註解與後面的 CREATE 會被分在同一段，不能整段略過
+func stripLeadingComments(stmt string) string {
+	stmt = strings.TrimSpace(stmt)
+	for strings.HasPrefix(stmt, "--") {
+		idx := strings.Index(stmt, "\n")
+		if idx < 0 {
+			return ""
+		}
+		stmt = strings.TrimSpace(stmt[idx+1:])
+	}
+	return stmt
+}
+
 // splitSQL 分割 SQL 語句
 func splitSQL(content string) []string {
 	var statements []string
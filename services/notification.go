@@ -0,0 +1,209 @@
+package services
+
+import (
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+)
+
+// maskedToken API 回應中代替真實 Token 與 header 值的字串，更新時收到此值表示不修改
+const maskedToken = "********"
+
+// GetAllNotificationChannels 取得所有通知通道
+func GetAllNotificationChannels() models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = []entities.NotificationChannel{}
+
+	var channels []entities.NotificationChannel
+	if err := global.Mysql.Where("deleted_at IS NULL").Order("id ASC").Find(&channels).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to get notification channels: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	masked := make([]entities.NotificationChannel, len(channels))
+	for i := range channels {
+		masked[i] = *channels[i].MaskToken()
+	}
+
+	res.Success = true
+	res.Msg = "Get all notification channels success"
+	res.Body = masked
+	return res
+}
+
+// CreateNotificationChannel 建立通知通道
+func CreateNotificationChannel(channel entities.NotificationChannel) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if err := channel.Validate(); err != nil {
+		res.Msg = fmt.Sprintf("Invalid notification channel: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	result := global.Mysql.Where("name = ? AND deleted_at IS NULL", channel.Name).First(&entities.NotificationChannel{})
+	if result.RowsAffected > 0 {
+		res.Msg = fmt.Sprintf("Notification channel name '%s' already exists", channel.Name)
+		log.Logrecord_no_rotate("WARNING", res.Msg)
+		return res
+	}
+
+	if err := global.Mysql.Create(&channel).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to create notification channel: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Create notification channel success"
+	res.Body = channel.MaskToken()
+	return res
+}
+
+// UpdateNotificationChannel 更新通知通道
+func UpdateNotificationChannel(channel entities.NotificationChannel) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	var existing entities.NotificationChannel
+	if err := global.Mysql.Where("id = ? AND deleted_at IS NULL", channel.ID).First(&existing).Error; err != nil {
+		res.Msg = fmt.Sprintf("Notification channel not found: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	// 前端回傳遮罩後的 Token 或 header 值時沿用原值
+	if channel.Token == maskedToken {
+		channel.Token = existing.Token
+	}
+	restoreMaskedHeaders(channel.Headers, existing.Headers)
+
+	if err := channel.Validate(); err != nil {
+		res.Msg = fmt.Sprintf("Invalid notification channel: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	result := global.Mysql.Where("id != ? AND name = ? AND deleted_at IS NULL", channel.ID, channel.Name).First(&entities.NotificationChannel{})
+	if result.RowsAffected > 0 {
+		res.Msg = fmt.Sprintf("Notification channel name '%s' already exists", channel.Name)
+		log.Logrecord_no_rotate("WARNING", res.Msg)
+		return res
+	}
+
+	// 明確指定欄位，讓 enable=false 等零值也能被更新
+	if err := global.Mysql.Model(&entities.NotificationChannel{}).Where("id = ?", channel.ID).
+		Select("name", "type", "url", "token", "headers", "enable", "description").
+		Updates(&channel).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to update notification channel: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Update notification channel success"
+	res.Body = channel.MaskToken()
+	return res
+}
+
+// restoreMaskedHeaders 將值為遮罩字串的 header 換回原本儲存的值
+func restoreMaskedHeaders(headers, stored map[string]string) {
+	for key, value := range headers {
+		if value != maskedToken {
+			continue
+		}
+		if original, ok := stored[key]; ok {
+			headers[key] = original
+		}
+	}
+}
+
+// DeleteNotificationChannel 刪除通知通道
+func DeleteNotificationChannel(id int) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if err := global.Mysql.Where("id = ?", id).Delete(&entities.NotificationChannel{}).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to delete notification channel: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Delete notification channel success"
+	return res
+}
+
+// TestNotificationChannel 發送測試訊息到指定通道
+func TestNotificationChannel(id int) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	var channel entities.NotificationChannel
+	if err := global.Mysql.Where("id = ? AND deleted_at IS NULL", id).First(&channel).Error; err != nil {
+		res.Msg = fmt.Sprintf("Notification channel not found: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	notifier, err := NewNotifier(channel)
+	if err != nil {
+		res.Msg = fmt.Sprintf("Invalid notification channel: %s", err.Error())
+		return res
+	}
+
+	err = notifier.Send(Notification{
+		Subject: "log-detect 通知測試",
+		Logname: "test",
+		Event:   "test",
+		Summary: fmt.Sprintf("通道 %s 測試訊息", channel.Name),
+	})
+	if err != nil {
+		res.Msg = fmt.Sprintf("Send test notification failed: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Send test notification success"
+	return res
+}
+
+// GetNotificationDeliveries 查詢通知發送紀錄
+func GetNotificationDeliveries(source string, logname string, status string, limit int) models.Response {
+	res := models.Response{}
+	res.Success = false
+	res.Body = []entities.NotificationDelivery{}
+
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	query := global.Mysql.Model(&entities.NotificationDelivery{})
+	if source != "" {
+		query = query.Where("source = ?", source)
+	}
+	if logname != "" {
+		query = query.Where("logname = ?", logname)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []entities.NotificationDelivery
+	if err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		res.Msg = fmt.Sprintf("Failed to get notification deliveries: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Get notification deliveries success"
+	res.Body = deliveries
+	return res
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"sort"
+	"time"
+)
+
+// defaultDigestInterval 摘要通知預設寄送間隔
+const defaultDigestInterval = time.Hour
+
+// defaultDigestMaxAttempts 摘要明細預設重送次數上限
+const defaultDigestMaxAttempts = 5
+
+// QueueNotificationDigest 將通知明細存入摘要佇列，下次摘要時合併寄送
+func QueueNotificationDigest(dest NotificationDestination, n Notification) error {
+	if len(n.Items) == 0 {
+		return nil
+	}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+
+	items := make([]entities.NotificationDigestItem, 0, len(n.Items))
+	for i, item := range n.Items {
+		digest := entities.NotificationDigestItem{
+			Source:     dest.Source,
+			SourceID:   dest.SourceID,
+			Receivers:  dest.Receivers,
+			ChannelIDs: dest.ChannelIDs,
+			Subject:    n.Subject,
+			Logname:    n.Logname,
+			Event:      n.Event,
+			Item:       item,
+			EventTime:  n.Time.Unix(),
+		}
+		if i < len(n.Devices) {
+			if device, err := json.Marshal(n.Devices[i]); err == nil {
+				digest.Device = string(device)
+			}
+		}
+		items = append(items, digest)
+	}
+
+	if err := global.Mysql.Create(&items).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Queue notification digest Fail: %s", err.Error()))
+		return err
+	}
+	return nil
+}
+
+// StartNotificationDigest 定期寄送摘要通知（多實例部署時只有 leader 寄送）
+func StartNotificationDigest() {
+	interval := parseDurationOr(global.EnvConfig.Notify.DigestInterval, defaultDigestInterval)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if !IsLeader() {
+				continue
+			}
+			FlushNotificationDigest()
+		}
+	}()
+}
+
+// digestGroup 同一來源、收件對象、日誌與事件的摘要明細
+type digestGroup struct {
+	dest     NotificationDestination
+	n        Notification
+	ids      []int
+	first    int64
+	attempts int
+}
+
+// FlushNotificationDigest 依來源、收件對象、日誌與事件合併佇列中的明細寄出；
+// 寄送前排除目前維護中的設備。寄送後明細只保留失敗的收件人與通道留待下次摘要重送，
+// 已成功的通道不會重複收到；重送超過 notify.digest_max_attempts 次後捨棄
+func FlushNotificationDigest() {
+	maxAttempts := global.EnvConfig.Notify.DigestMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultDigestMaxAttempts
+	}
+
+	var items []entities.NotificationDigestItem
+	if err := global.Mysql.Order("id").Find(&items).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to load notification digest: %s", err.Error()))
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	groups := map[string]*digestGroup{}
+	var keys []string
+	for _, item := range items {
+		// 重送中的明細只剩失敗的收件對象，與新排入的明細分開寄送
+		key := fmt.Sprintf("%s:%d:%s:%s:%v:%v", item.Source, item.SourceID, item.Logname, item.Event, item.Receivers, item.ChannelIDs)
+		group, ok := groups[key]
+		if !ok {
+			group = &digestGroup{
+				dest: NotificationDestination{
+					Source:     item.Source,
+					SourceID:   item.SourceID,
+					Receivers:  item.Receivers,
+					ChannelIDs: item.ChannelIDs,
+				},
+				n: Notification{
+					Subject:  fmt.Sprintf("[摘要] %s", item.Subject),
+					Logname:  item.Logname,
+					Event:    item.Event,
+					Severity: entities.DeviceCriticalityLow,
+				},
+				first: item.EventTime,
+			}
+			groups[key] = group
+			keys = append(keys, key)
+		}
+
+		device := NotificationDevice{}
+		if item.Device != "" {
+			_ = json.Unmarshal([]byte(item.Device), &device)
+		}
+		eventTime := time.Unix(item.EventTime, 0)
+		group.n.Items = append(group.n.Items, fmt.Sprintf("%s %s", eventTime.Format("01-02 15:04"), item.Item))
+		group.n.Devices = append(group.n.Devices, device)
+		group.n.Time = eventTime
+		group.ids = append(group.ids, item.ID)
+		if item.Attempts > group.attempts {
+			group.attempts = item.Attempts
+		}
+	}
+
+	sort.Strings(keys)
+	now := time.Now()
+	for _, key := range keys {
+		group := groups[key]
+		group.n.Summary = fmt.Sprintf("%s 日誌，%s 以來低重要性設備的事件（%s）：", group.n.Logname,
+			time.Unix(group.first, 0).Format("2006-01-02 15:04"), group.n.Event)
+
+		// 排入摘要後才進入維護時段的設備不再通知，明細一併刪除
+		if group.dest.Source == "device" {
+			filterDigestMaintenance(&group.n, digestMaintenance(group.dest, group.n.Logname, now))
+		}
+		if len(group.n.Items) > 0 {
+			result := DispatchNotification(group.dest, group.n)
+			if retry := digestRetry(group.dest, result); len(retry.Receivers) > 0 || len(retry.ChannelIDs) > 0 {
+				if group.attempts+1 < maxAttempts {
+					keepDigestItems(group, retry)
+					continue
+				}
+				log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Notification digest for %s (%s) failed %d times, dropping %d items",
+					group.n.Logname, group.n.Event, group.attempts+1, len(group.ids)))
+			}
+		}
+
+		if err := global.Mysql.Where("id IN ?", group.ids).Delete(&entities.NotificationDigestItem{}).Error; err != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to delete sent notification digest items: %s", err.Error()))
+		}
+	}
+}
+
+// digestRetry 依發送結果取得需要重送的收件對象；Email 一次寄給全部收件人，失敗時全部重送
+func digestRetry(dest NotificationDestination, result NotificationResult) NotificationDestination {
+	retry := NotificationDestination{Source: dest.Source, SourceID: dest.SourceID, Receivers: []string{}, ChannelIDs: []int{}}
+	for _, delivery := range result.Deliveries {
+		if delivery.Status != "failed" {
+			continue
+		}
+		if delivery.ChannelID == 0 {
+			retry.Receivers = append(retry.Receivers, dest.Receivers...)
+			continue
+		}
+		retry.ChannelIDs = append(retry.ChannelIDs, delivery.ChannelID)
+	}
+	return retry
+}
+
+// keepDigestItems 明細改為只寄給失敗的收件對象並累計重送次數
+func keepDigestItems(group *digestGroup, retry NotificationDestination) {
+	receivers, _ := json.Marshal(retry.Receivers)
+	channelIDs, _ := json.Marshal(retry.ChannelIDs)
+	err := global.Mysql.Model(&entities.NotificationDigestItem{}).Where("id IN ?", group.ids).Updates(map[string]interface{}{
+		"receivers":   string(receivers),
+		"channel_ids": string(channelIDs),
+		"attempts":    group.attempts + 1,
+	}).Error
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to keep notification digest items for retry: %s", err.Error()))
+		return
+	}
+	log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Notification digest for %s (%s) failed for %d receivers and %d channels, keeping %d items for the next digest",
+		group.n.Logname, group.n.Event, len(retry.Receivers), len(retry.ChannelIDs), len(group.ids)))
+}
+
+// digestMaintenance 取得設備摘要對應 target / index 目前的維護範圍；
+// target 或 index 已刪除時仍套用設備與 target 層級的維護時段
+func digestMaintenance(dest NotificationDestination, logname string, t time.Time) MaintenanceMatcher {
+	target := entities.Target{ID: dest.SourceID}
+	index := entities.Index{}
+	if loaded, err := GetTargetByID(dest.SourceID); err == nil {
+		target = loaded
+		for _, idx := range loaded.Indices {
+			if idx.Logname == logname {
+				index = idx
+				break
+			}
+		}
+	}
+	return NewMaintenanceMatcher(target, index, t)
+}
+
+// filterDigestMaintenance 移除維護中設備的明細；n.Items 與 n.Devices 一一對應
+func filterDigestMaintenance(n *Notification, maint MaintenanceMatcher) {
+	names := make([]string, len(n.Devices))
+	for i, device := range n.Devices {
+		names[i] = device.Name
+	}
+	_, silenced := maint.Filter(names)
+	if len(silenced) == 0 {
+		return
+	}
+	skip := map[string]bool{}
+	for _, name := range silenced {
+		skip[name] = true
+	}
+
+	items := n.Items[:0]
+	devices := n.Devices[:0]
+	for i, device := range n.Devices {
+		if skip[device.Name] {
+			continue
+		}
+		items = append(items, n.Items[i])
+		devices = append(devices, device)
+	}
+	n.Items, n.Devices = items, devices
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"log-detect/entities"
+	"reflect"
+	"testing"
+)
+
+func TestFilterDigestMaintenance(t *testing.T) {
+	digest := func() Notification {
+		return Notification{
+			Items:   []string{"10-17 08:00 host-a 離線", "10-17 08:05 host-b 離線", "10-17 08:10 host-a 恢復"},
+			Devices: []NotificationDevice{{Name: "host-a"}, {Name: "host-b"}, {Name: "host-a"}},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		maint MaintenanceMatcher
+		want  []string
+	}{
+		{name: "no maintenance", maint: MaintenanceMatcher{}, want: []string{"10-17 08:00 host-a 離線", "10-17 08:05 host-b 離線", "10-17 08:10 host-a 恢復"}},
+		{name: "device maintenance", maint: MaintenanceMatcher{devices: map[string]bool{"host-a": true}}, want: []string{"10-17 08:05 host-b 離線"}},
+		{name: "whole index", maint: MaintenanceMatcher{all: true}, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := digest()
+			filterDigestMaintenance(&n, tt.maint)
+			if !reflect.DeepEqual(append([]string{}, n.Items...), tt.want) {
+				t.Errorf("items = %v, want %v", n.Items, tt.want)
+			}
+			if len(n.Devices) != len(n.Items) {
+				t.Errorf("devices = %d, items = %d, want the same length", len(n.Devices), len(n.Items))
+			}
+		})
+	}
+}
+
+func TestDigestRetry(t *testing.T) {
+	dest := NotificationDestination{Source: "device", SourceID: 1, Receivers: []string{"a@example.com", "b@example.com"}, ChannelIDs: []int{2, 3, 4}}
+	delivery := func(channelID int, status string) entities.NotificationDelivery {
+		return entities.NotificationDelivery{ChannelID: channelID, Status: status}
+	}
+
+	tests := []struct {
+		name          string
+		deliveries    []entities.NotificationDelivery
+		wantReceivers []string
+		wantChannels  []int
+	}{
+		{
+			name:          "all sent",
+			deliveries:    []entities.NotificationDelivery{delivery(0, "sent"), delivery(2, "sent"), delivery(3, "sent"), delivery(4, "sent")},
+			wantReceivers: []string{},
+			wantChannels:  []int{},
+		},
+		{
+			name:          "one channel failed",
+			deliveries:    []entities.NotificationDelivery{delivery(0, "sent"), delivery(2, "sent"), delivery(3, "failed"), delivery(4, "sent")},
+			wantReceivers: []string{},
+			wantChannels:  []int{3},
+		},
+		{
+			name:          "email failed",
+			deliveries:    []entities.NotificationDelivery{delivery(0, "failed"), delivery(2, "sent"), delivery(3, "sent"), delivery(4, "failed")},
+			wantReceivers: []string{"a@example.com", "b@example.com"},
+			wantChannels:  []int{4},
+		},
+		{
+			// 停用或已刪除的通道沒有發送紀錄，不再重送
+			name:          "disabled channel skipped",
+			deliveries:    []entities.NotificationDelivery{delivery(0, "sent"), delivery(2, "sent")},
+			wantReceivers: []string{},
+			wantChannels:  []int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry := digestRetry(dest, NotificationResult{Deliveries: tt.deliveries})
+			if !reflect.DeepEqual(retry.Receivers, tt.wantReceivers) || !reflect.DeepEqual(retry.ChannelIDs, tt.wantChannels) {
+				t.Errorf("retry = %v %v, want %v %v", retry.Receivers, retry.ChannelIDs, tt.wantReceivers, tt.wantChannels)
+			}
+			if retry.Source != dest.Source || retry.SourceID != dest.SourceID {
+				t.Errorf("retry source = %s:%d, want %s:%d", retry.Source, retry.SourceID, dest.Source, dest.SourceID)
+			}
+		})
+	}
+}
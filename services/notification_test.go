@@ -0,0 +1,42 @@
+package services
+
+import (
+	"log-detect/entities"
+	"reflect"
+	"testing"
+)
+
+func TestMaskTokenHeaders(t *testing.T) {
+	channel := entities.NotificationChannel{
+		Token:   "secret-token",
+		Headers: map[string]string{"Authorization": "Bearer abc", "X-Api-Key": "k1", "X-Empty": ""},
+	}
+
+	masked := channel.MaskToken()
+	if masked.Token != maskedToken {
+		t.Errorf("token = %q, want masked", masked.Token)
+	}
+	want := map[string]string{"Authorization": maskedToken, "X-Api-Key": maskedToken, "X-Empty": ""}
+	if !reflect.DeepEqual(masked.Headers, want) {
+		t.Errorf("headers = %v, want %v", masked.Headers, want)
+	}
+	if channel.Headers["Authorization"] != "Bearer abc" || channel.Token != "secret-token" {
+		t.Errorf("MaskToken modified the stored channel: %+v", channel)
+	}
+}
+
+func TestRestoreMaskedHeaders(t *testing.T) {
+	stored := map[string]string{"Authorization": "Bearer abc", "X-Api-Key": "k1"}
+	headers := map[string]string{
+		"Authorization": maskedToken, // 未修改
+		"X-Api-Key":     "k2",        // 更新
+		"X-New":         "v",         // 新增
+		"X-Unknown":     maskedToken, // 沒有原值時照原樣儲存
+	}
+
+	restoreMaskedHeaders(headers, stored)
+	want := map[string]string{"Authorization": "Bearer abc", "X-Api-Key": "k2", "X-New": "v", "X-Unknown": maskedToken}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("headers = %v, want %v", headers, want)
+	}
+}
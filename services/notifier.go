@@ -0,0 +1,299 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Notification 與通道無關的通知內容
+type Notification struct {
+	Subject  string    `json:"subject"`
+	Logname  string    `json:"logname"`
+	Event    string    `json:"event"`    // device_offline, es_alert ...
+	Severity string    `json:"severity"` // critical, high, medium, low
+	Summary  string    `json:"summary"`  // 一行摘要（郵件中作為表格前的說明）
+	Items    []string  `json:"items"`    // 明細（例如失聯主機清單）
+	Time     time.Time `json:"time"`
+
+	Devices []NotificationDevice `json:"devices,omitempty"` // 與 Items 一一對應的設備屬性，非設備通知為空
+}
+
+// NotificationDevice 通知中單一設備的屬性
+type NotificationDevice struct {
+	Name        string   `json:"name"`
+	Owner       string   `json:"owner,omitempty"`
+	Site        string   `json:"site,omitempty"`
+	Environment string   `json:"environment,omitempty"`
+	Criticality string   `json:"criticality,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// Notifier 通知通道介面
+type Notifier interface {
+	Type() string
+	Send(n Notification) error
+}
+
+// NotificationDestination 一次通知要送往的對象
+type NotificationDestination struct {
+	Source     string   // device, es_monitor
+	SourceID   int      // target id 或 monitor id
+	Receivers  []string // Email 收件人
+	ChannelIDs []int    // notification_channels.id
+}
+
+// NotificationResult 一次通知的發送結果
+type NotificationResult struct {
+	Sent       int                             `json:"sent"`
+	Failed     int                             `json:"failed"`
+	Deliveries []entities.NotificationDelivery `json:"deliveries"`
+}
+
+// DefaultLineNotifyURL LINE Notify API 位址
+const DefaultLineNotifyURL = "https://notify-api.line.me/api/notify"
+
+var notifierHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewNotifier 依通道配置建立對應的 Notifier
+func NewNotifier(channel entities.NotificationChannel) (Notifier, error) {
+	if err := channel.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch channel.Type {
+	case entities.ChannelTypeWebhook:
+		return &WebhookNotifier{url: channel.URL, headers: channel.Headers}, nil
+	case entities.ChannelTypeSlack:
+		return &SlackNotifier{url: channel.URL}, nil
+	case entities.ChannelTypeTeams:
+		return &TeamsNotifier{url: channel.URL}, nil
+	case entities.ChannelTypeLineNotify:
+		endpoint := channel.URL
+		if endpoint == "" {
+			endpoint = DefaultLineNotifyURL
+		}
+		return &LineNotifyNotifier{url: endpoint, token: channel.Token}, nil
+	}
+	return nil, fmt.Errorf("unsupported channel type: %s", channel.Type)
+}
+
+// EmailNotifier 透過 SMTP 寄送 HTML 郵件
+type EmailNotifier struct {
+	receivers []string
+}
+
+// NewEmailNotifier 建立 Email 通知
+func NewEmailNotifier(receivers []string) *EmailNotifier {
+	return &EmailNotifier{receivers: receivers}
+}
+
+func (e *EmailNotifier) Type() string { return entities.ChannelTypeEmail }
+
+func (e *EmailNotifier) Send(n Notification) error {
+	intro := n.Summary
+	if intro == "" {
+		intro = fmt.Sprintf("%s 日誌，失聯主機如下：", n.Logname)
+	}
+	body := BuildDeviceMailBody(n.Subject, intro, n.Items, n.Devices)
+	return SendHTMLMail(e.receivers, nil, nil, n.Subject, body)
+}
+
+// WebhookNotifier 通用 JSON webhook
+type WebhookNotifier struct {
+	url     string
+	headers map[string]string
+}
+
+func (w *WebhookNotifier) Type() string { return entities.ChannelTypeWebhook }
+
+func (w *WebhookNotifier) Send(n Notification) error {
+	return postJSON(w.url, w.headers, n)
+}
+
+// SlackNotifier Slack incoming webhook
+type SlackNotifier struct {
+	url string
+}
+
+func (s *SlackNotifier) Type() string { return entities.ChannelTypeSlack }
+
+func (s *SlackNotifier) Send(n Notification) error {
+	payload := map[string]string{
+		"text": fmt.Sprintf("*%s*\n%s", n.Subject, formatNotificationText(n, "• ")),
+	}
+	return postJSON(s.url, nil, payload)
+}
+
+// TeamsNotifier Microsoft Teams incoming webhook（MessageCard 格式）
+type TeamsNotifier struct {
+	url string
+}
+
+func (t *TeamsNotifier) Type() string { return entities.ChannelTypeTeams }
+
+func (t *TeamsNotifier) Send(n Notification) error {
+	payload := map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "http://schema.org/extensions",
+		"summary":    n.Subject,
+		"themeColor": severityColor(n.Severity),
+		"title":      n.Subject,
+		"text":       strings.ReplaceAll(formatNotificationText(n, "- "), "\n", "<br>"),
+	}
+	return postJSON(t.url, nil, payload)
+}
+
+// LineNotifyNotifier LINE Notify
+type LineNotifyNotifier struct {
+	url   string
+	token string
+}
+
+func (l *LineNotifyNotifier) Type() string { return entities.ChannelTypeLineNotify }
+
+func (l *LineNotifyNotifier) Send(n Notification) error {
+	form := url.Values{}
+	form.Set("message", fmt.Sprintf("\n%s\n%s", n.Subject, formatNotificationText(n, "・")))
+
+	req, err := http.NewRequest("POST", l.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+l.token)
+	return doNotifyRequest(req)
+}
+
+// DispatchNotification 將通知送往 Email 收件人與所有指定通道，並寫入發送紀錄
+func DispatchNotification(dest NotificationDestination, n Notification) NotificationResult {
+	result := NotificationResult{}
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+
+	receivers := compactAddresses(dest.Receivers)
+	if len(receivers) > 0 {
+		err := NewEmailNotifier(receivers).Send(n)
+		result.record(dest, n, 0, entities.ChannelTypeEmail, strings.Join(receivers, ","), err)
+	}
+
+	if len(dest.ChannelIDs) == 0 {
+		return result
+	}
+
+	var channels []entities.NotificationChannel
+	if err := global.Mysql.Where("id IN ? AND enable = ?", dest.ChannelIDs, true).Find(&channels).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to load notification channels: %s", err.Error()))
+		return result
+	}
+
+	for _, channel := range channels {
+		notifier, err := NewNotifier(channel)
+		if err == nil {
+			err = notifier.Send(n)
+		}
+		result.record(dest, n, channel.ID, channel.Type, channel.Name, err)
+	}
+
+	return result
+}
+
+// record 紀錄單一通道的發送結果
+func (r *NotificationResult) record(dest NotificationDestination, n Notification, channelID int, channelType string, recipient string, err error) {
+	delivery := entities.NotificationDelivery{
+		Source:      dest.Source,
+		SourceID:    dest.SourceID,
+		Logname:     n.Logname,
+		Event:       n.Event,
+		ChannelID:   channelID,
+		ChannelType: channelType,
+		Recipient:   recipient,
+		Subject:     n.Subject,
+		Status:      "sent",
+		Date:        n.Time.Format("2006-01-02"),
+		Time:        n.Time.Format("15:04:05"),
+	}
+	if err != nil {
+		delivery.Status = "failed"
+		delivery.ErrorMsg = err.Error()
+		r.Failed++
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Notification via %s (%s) failed: %s", channelType, recipient, err.Error()))
+	} else {
+		r.Sent++
+	}
+
+	if dbErr := global.Mysql.Create(&delivery).Error; dbErr != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Create notification delivery Fail: %s", dbErr.Error()))
+	}
+	r.Deliveries = append(r.Deliveries, delivery)
+}
+
+// formatNotificationText 將通知轉為純文字（Slack / Teams / LINE 共用）
+func formatNotificationText(n Notification, bullet string) string {
+	var sb strings.Builder
+	if n.Summary != "" {
+		sb.WriteString(n.Summary)
+		sb.WriteString("\n")
+	}
+	for _, item := range n.Items {
+		sb.WriteString(bullet)
+		sb.WriteString(item)
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// severityColor Teams 卡片顏色
+func severityColor(severity string) string {
+	switch severity {
+	case "critical":
+		return "D70000"
+	case "high":
+		return "FF8C00"
+	case "medium":
+		return "FFD700"
+	}
+	return "0078D7"
+}
+
+// postJSON 以 JSON 格式 POST 到指定 URL
+func postJSON(endpoint string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return doNotifyRequest(req)
+}
+
+// doNotifyRequest 發送請求並檢查 HTTP 狀態碼
+func doNotifyRequest(req *http.Request) error {
+	resp, err := notifierHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// recheckTask 延遲重新檢查的內容（沿用原本的查詢區間）
+type recheckTask struct {
+	target    entities.Target
+	index     entities.Index
+	params    DeviceSearchParams
+	devices   []string // 檢查時缺失的設備（含尚未達門檻與已離線）
+	names     *DeviceNameResolver
+	checkTime time.Time
+}
+
+// scheduleRecheck 依 index 的 RecheckDelay 排程重新檢查，未設定或沒有缺失設備時不處理
+func scheduleRecheck(task recheckTask) {
+	if task.index.RecheckDelay <= 0 || len(task.devices) == 0 {
+		return
+	}
+	time.AfterFunc(time.Duration(task.index.RecheckDelay)*time.Minute, func() {
+		runRecheck(task)
+	})
+}
+
+// runRecheck 以相同區間重新查詢，資料延遲到達的設備撤銷離線事件並將歷史紀錄降為 warning
+func runRecheck(task recheckTask) {
+	logname := task.index.Logname
+
+	esClient, err := detectClient(task.index.ID)
+	if err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Cannot recheck %s: %s", logname, err.Error()))
+		return
+	}
+	// 部分結果中出現的設備仍可確定有資料
+	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeout(task.index))
+	defer cancel()
+	result, err := SearchRequestWithClient(ctx, esClient, task.params)
+	if err != nil && !result.Partial {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Recheck search failed for %s: %s", logname, err.Error()))
+		return
+	}
+
+	result, _ = task.names.Apply(result)
+	counts := result.DocCounts()
+	var late []string
+	for _, device := range task.devices {
+		if counts[device] > 0 {
+			late = append(late, device)
+		}
+	}
+	if len(late) == 0 {
+		return
+	}
+	log.Logrecord_no_rotate("INFO", fmt.Sprintf("Recheck of %s found late data for %d devices: %v", logname, len(late), late))
+
+	NewDeviceStateService().MarkPresent(task.target.ID, task.index.ID, late, task.checkTime)
+	downgradeLateHistory(task, late, counts)
+
+	retracted := NewDeviceIncidentService().Retract(task.target, task.index, IncidentTypeOffline, late, task.checkTime)
+	retracted, _ = NewMaintenanceMatcher(task.target, task.index, time.Now()).Filter(retracted)
+	if len(retracted) > 0 {
+		// 與原本的離線告警寄給相同對象
+		DispatchNotification(deviceNotificationDestination(task.target, task.index), Notification{
+			Subject:  fmt.Sprintf("[撤銷] %s", task.target.Subject),
+			Logname:  logname,
+			Event:    "device_offline_retracted",
+			Severity: "low",
+			Summary:  fmt.Sprintf("%s 日誌，以下主機的資料延遲到達，撤銷先前的失聯告警：", logname),
+			Items:    retracted,
+			Time:     time.Now(),
+		})
+	}
+}
+
+// downgradeLateHistory 將延遲到達設備在該次檢查的 offline / missed 紀錄改為 warning（LATE_ARRIVAL）
+func downgradeLateHistory(task recheckTask, devices []string, counts map[string]int64) {
+	for _, device := range devices {
+		msg := fmt.Sprintf("Data arrived late, found %d documents on recheck", counts[device])
+
+		if global.TimescaleDB != nil {
+			_, err := global.TimescaleDB.Exec(`
+				UPDATE device_metrics
+				SET status = 'warning', lost = false, lost_num = 0,
+				    data_count = $1, error_code = 'LATE_ARRIVAL', error_msg = $2
+				WHERE target_id = $3 AND index_id = $4 AND device_id = $5
+				  AND timestamp_unix = $6
+				  AND error_code IN ('DEVICE_OFFLINE', 'DEVICE_MISSED')
+				  AND status <> 'maintenance'
+			`, counts[device], msg, task.target.ID, task.index.ID, device, task.checkTime.Unix())
+			if err != nil {
+				log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to downgrade history for %s in TimescaleDB: %s", device, err.Error()))
+			}
+		}
+
+		if err := updateLateHistoryES(task, device, counts[device], msg); err != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to downgrade history for %s in ES: %s", device, err.Error()))
+		}
+	}
+}
+
+// updateLateHistoryES 以 update_by_query 更新 ES 中對應的歷史紀錄
+func updateLateHistoryES(task recheckTask, device string, count int64, msg string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"target_id": task.target.ID}},
+					map[string]interface{}{"term": map[string]interface{}{"index_id": task.index.ID}},
+					map[string]interface{}{"term": map[string]interface{}{"timestamp": task.checkTime.Unix()}},
+					map[string]interface{}{"match_phrase": map[string]interface{}{"name": device}},
+					map[string]interface{}{"terms": map[string]interface{}{"error_code.keyword": []string{"DEVICE_OFFLINE", "DEVICE_MISSED"}}},
+				},
+				"must_not": []interface{}{
+					map[string]interface{}{"match_phrase": map[string]interface{}{"status": StatusMaintenance}},
+				},
+			},
+		},
+		"script": map[string]interface{}{
+			"lang": "painless",
+			"source": "ctx._source.status = 'warning'; ctx._source.lost = 'false'; ctx._source.lost_num = 0; " +
+				"ctx._source.data_count = params.count; ctx._source.error_code = 'LATE_ARRIVAL'; ctx._source.error_msg = params.msg;",
+			"params": map[string]interface{}{"count": count, "msg": msg},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	refresh := true
+	req := esapi.UpdateByQueryRequest{
+		Index:     []string{"log-detect-history-*"},
+		Body:      bytes.NewReader(body),
+		Conflicts: "proceed",
+		Refresh:   &refresh,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeout(task.index))
+	defer cancel()
+	res, err := req.Do(ctx, global.Elasticsearch)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		resBody, _ := io.ReadAll(res.Body)
+		return fmt.Errorf("update_by_query error [%d]: %s", res.StatusCode, truncateBody(resBody))
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 排程週期類型
+const (
+	PeriodMinutes = "minutes"
+	PeriodHours   = "hours"
+	PeriodDays    = "days"
+	PeriodCron    = "cron"
+)
+
+// 預覽排程時回傳的預設 / 最大筆數
+const (
+	defaultNextRunCount = 5
+	maxNextRunCount     = 50
+)
+
+// scheduleParser 與 cron.New() 預設相同的五欄位格式，另支援 @hourly 等描述字
+var scheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// BuildSchedule 依週期設定產生 cron 表示式並驗證，timezone 不為空時加上 CRON_TZ 前綴
+func BuildSchedule(period string, unit int, cronExpr string, timezone string) (string, error) {
+	// 舊版以 "*/unit" 直接組表示式，minutes 60、hours 24 等值實際上是每小時 / 每天執行；
+	// 既有設定照原本的意思換成小時 / 天的排程，不因升級而停止偵測
+	if period == PeriodMinutes && unit >= 60 && unit%60 == 0 {
+		period, unit = PeriodHours, unit/60
+	}
+	if period == PeriodHours && unit == 24 {
+		period, unit = PeriodDays, 1
+	}
+
+	var spec string
+	switch period {
+	case PeriodMinutes:
+		if unit < 1 || unit > 59 {
+			return "", fmt.Errorf("minutes 的 unit 必須介於 1 到 59")
+		}
+		spec = fmt.Sprintf("*/%d * * * *", unit)
+	case PeriodHours:
+		if unit < 1 || unit > 23 {
+			return "", fmt.Errorf("hours 的 unit 必須介於 1 到 23")
+		}
+		spec = fmt.Sprintf("0 */%d * * *", unit)
+	case PeriodDays:
+		if unit < 1 || unit > 31 {
+			return "", fmt.Errorf("days 的 unit 必須介於 1 到 31")
+		}
+		spec = fmt.Sprintf("0 0 */%d * *", unit)
+	case PeriodCron:
+		spec = strings.TrimSpace(cronExpr)
+		if spec == "" {
+			return "", fmt.Errorf("cron_expr 不可為空")
+		}
+		if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+			return "", fmt.Errorf("cron_expr 不可包含時區，請改用 timezone 設定")
+		}
+	default:
+		return "", fmt.Errorf("不支援的 period: %s", period)
+	}
+
+	if timezone != "" {
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return "", fmt.Errorf("無效的時區: %s", timezone)
+		}
+		spec = fmt.Sprintf("CRON_TZ=%s %s", timezone, spec)
+	}
+
+	if _, err := scheduleParser.Parse(spec); err != nil {
+		return "", fmt.Errorf("無效的排程 %q: %w", spec, err)
+	}
+	return spec, nil
+}
+
+// intervalPrefix 固定間隔排程的前綴
+const intervalPrefix = "@every "
+
+// intervalSchedule 固定間隔的排程，執行時間對齊間隔的整數倍，重啟或重新排程後不會改變
+type intervalSchedule struct {
+	every time.Duration
+}
+
+// Next 實作 cron.Schedule
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	return t.Truncate(s.every).Add(s.every)
+}
+
+// parseSchedule 解析排程表示式；@every 改用對齊的 intervalSchedule，讓上次 / 下次執行時間可以推算
+func parseSchedule(spec string) (cron.Schedule, error) {
+	if strings.HasPrefix(spec, intervalPrefix) {
+		every, err := time.ParseDuration(strings.TrimPrefix(spec, intervalPrefix))
+		if err != nil || every < time.Minute {
+			return nil, fmt.Errorf("無效的間隔: %s", spec)
+		}
+		return intervalSchedule{every: every.Truncate(time.Minute)}, nil
+	}
+	return scheduleParser.Parse(spec)
+}
+
+// legacyInterval 舊版 minutes 超過 59 或 hours 超過 23 且無法換算成 cron 的設定（例如 minutes 90），改以固定間隔執行
+func legacyInterval(period string, unit int) (string, bool) {
+	switch {
+	case period == PeriodMinutes && unit > 59:
+		return fmt.Sprintf("%s%dm", intervalPrefix, unit), true
+	case period == PeriodHours && unit > 23:
+		return fmt.Sprintf("%s%dh", intervalPrefix, unit), true
+	}
+	return "", false
+}
+
+// IndexSchedule 取得 index 的排程；升級前儲存、新設定不再接受的舊版週期照設定的間隔繼續執行
+func IndexSchedule(index entities.Index) (string, error) {
+	spec, err := BuildSchedule(index.Period, index.Unit, index.CronExpr, index.Timezone)
+	if err != nil {
+		if legacy, ok := legacyInterval(index.Period, index.Unit); ok {
+			return legacy, nil
+		}
+	}
+	return spec, err
+}
+
+// ValidateIndexSchedule 驗證新設定的排程（不接受舊版週期）
+func ValidateIndexSchedule(index entities.Index) error {
+	_, err := BuildSchedule(index.Period, index.Unit, index.CronExpr, index.Timezone)
+	return err
+}
+
+// warnLegacySchedules 啟動時提醒仍使用舊版週期、以固定間隔執行的 index
+func warnLegacySchedules() {
+	var indices []entities.Index
+	if err := global.Mysql.Find(&indices).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to check legacy schedules: %s", err.Error()))
+		return
+	}
+	for _, index := range indices {
+		if ValidateIndexSchedule(index) == nil {
+			continue
+		}
+		if legacy, ok := legacyInterval(index.Period, index.Unit); ok {
+			log.Logrecord_no_rotate("WARNING", fmt.Sprintf("Index %d (%s) uses legacy schedule %s %d, running as %q; change it to a supported period or cron_expr",
+				index.ID, index.Logname, index.Period, index.Unit, legacy))
+		}
+	}
+}
+
+// NextFireTimes 計算排程在 from 之後的 n 次執行時間
+func NextFireTimes(spec string, from time.Time, n int) ([]time.Time, error) {
+	schedule, err := parseSchedule(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var times []time.Time
+	next := from
+	for i := 0; i < n; i++ {
+		next = schedule.Next(next)
+		if next.IsZero() {
+			break
+		}
+		times = append(times, next)
+	}
+	return times, nil
+}
+
+// LastFireTime 取得排程在 t 之前（不含 t）最近一次的執行時間，找不到時回傳零值
+func LastFireTime(spec string, t time.Time) time.Time {
+	schedule, err := parseSchedule(spec)
+	if err != nil {
+		return time.Time{}
+	}
+
+	// 逐步加大回看範圍，直到找到至少一次執行時間
+	for lookback := time.Minute; lookback <= 366*24*time.Hour; lookback *= 2 {
+		var last time.Time
+		for next := schedule.Next(t.Add(-lookback)); next.Before(t); next = schedule.Next(next) {
+			last = next
+		}
+		if !last.IsZero() {
+			return last
+		}
+	}
+	return time.Time{}
+}
+
+// PreviewSchedule 驗證排程設定並回傳接下來的執行時間
+func PreviewSchedule(req entities.SchedulePreviewRequest) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	spec, err := BuildSchedule(req.Period, req.Unit, req.CronExpr, req.Timezone)
+	if err != nil {
+		res.Msg = err.Error()
+		return res
+	}
+
+	count := req.Count
+	if count <= 0 {
+		count = defaultNextRunCount
+	}
+	if count > maxNextRunCount {
+		count = maxNextRunCount
+	}
+
+	loc := time.Local
+	if req.Timezone != "" {
+		loc, _ = time.LoadLocation(req.Timezone)
+	}
+	times, err := NextFireTimes(spec, time.Now().In(loc), count)
+	if err != nil {
+		res.Msg = err.Error()
+		return res
+	}
+
+	preview := entities.SchedulePreview{Schedule: spec, NextRuns: []string{}}
+	for _, t := range times {
+		preview.NextRuns = append(preview.NextRuns, t.Format(time.RFC3339))
+	}
+
+	res.Body = preview
+	res.Success = true
+	return res
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"log-detect/entities"
+	"log-detect/global"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+func TestBuildSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		period   string
+		unit     int
+		cronExpr string
+		timezone string
+		want     string
+		wantErr  bool
+	}{
+		{name: "minutes", period: PeriodMinutes, unit: 5, want: "*/5 * * * *"},
+		{name: "hours", period: PeriodHours, unit: 2, want: "0 */2 * * *"},
+		{name: "days", period: PeriodDays, unit: 1, want: "0 0 */1 * *"},
+		{name: "cron", period: PeriodCron, cronExpr: " 30 8 * * 1-5 ", want: "30 8 * * 1-5"},
+		{name: "descriptor", period: PeriodCron, cronExpr: "@hourly", want: "@hourly"},
+		{name: "timezone", period: PeriodHours, unit: 1, timezone: "Asia/Taipei", want: "CRON_TZ=Asia/Taipei 0 */1 * * *"},
+
+		// 舊版設定照原本的實際行為換算
+		{name: "legacy minutes 60", period: PeriodMinutes, unit: 60, want: "0 */1 * * *"},
+		{name: "legacy minutes 120", period: PeriodMinutes, unit: 120, want: "0 */2 * * *"},
+		{name: "legacy minutes 1440", period: PeriodMinutes, unit: 1440, want: "0 0 */1 * *"},
+		{name: "legacy hours 24", period: PeriodHours, unit: 24, want: "0 0 */1 * *"},
+
+		{name: "minutes 90", period: PeriodMinutes, unit: 90, wantErr: true},
+		{name: "minutes 0", period: PeriodMinutes, unit: 0, wantErr: true},
+		{name: "hours 25", period: PeriodHours, unit: 25, wantErr: true},
+		{name: "days 32", period: PeriodDays, unit: 32, wantErr: true},
+		{name: "empty cron", period: PeriodCron, cronExpr: " ", wantErr: true},
+		{name: "cron with timezone", period: PeriodCron, cronExpr: "CRON_TZ=UTC 0 * * * *", wantErr: true},
+		{name: "invalid cron", period: PeriodCron, cronExpr: "61 * * * *", wantErr: true},
+		{name: "invalid timezone", period: PeriodMinutes, unit: 5, timezone: "Mars/Base", wantErr: true},
+		{name: "unknown period", period: "weeks", unit: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildSchedule(tt.period, tt.unit, tt.cronExpr, tt.timezone)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("BuildSchedule() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildSchedule() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("BuildSchedule() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastFireTime(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	legacyHourly, err := BuildSchedule(PeriodMinutes, 60, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		t    time.Time
+		want time.Time
+	}{
+		{name: "between runs", spec: "*/5 * * * *", t: at("2026-10-17T10:07:30Z"), want: at("2026-10-17T10:05:00Z")},
+		{name: "exactly on a run", spec: "*/5 * * * *", t: at("2026-10-17T10:05:00Z"), want: at("2026-10-17T10:00:00Z")},
+		{name: "legacy minutes 60", spec: legacyHourly, t: at("2026-10-17T10:30:00Z"), want: at("2026-10-17T10:00:00Z")},
+		{name: "legacy interval", spec: "@every 90m", t: at("2026-10-17T10:30:00Z"), want: at("2026-10-17T09:00:00Z")},
+		{name: "legacy interval on a run", spec: "@every 90m", t: at("2026-10-17T10:30:00Z").Add(time.Minute), want: at("2026-10-17T10:30:00Z")},
+		{name: "daily", spec: "0 0 * * *", t: at("2026-10-17T10:00:00Z"), want: at("2026-10-17T00:00:00Z")},
+		{name: "monthly", spec: "0 0 1 * *", t: at("2026-10-17T10:00:00Z"), want: at("2026-10-01T00:00:00Z")},
+		{name: "timezone", spec: "CRON_TZ=Asia/Taipei 0 8 * * *", t: at("2026-10-17T01:00:00Z"), want: at("2026-10-17T00:00:00Z")},
+		{name: "invalid spec", spec: "bad", t: at("2026-10-17T10:00:00Z"), want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := LastFireTime(tt.spec, tt.t)
+			if !got.Equal(tt.want) {
+				t.Errorf("LastFireTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexScheduleLegacyInterval(t *testing.T) {
+	tests := []struct {
+		name      string
+		index     entities.Index
+		want      string
+		wantValid bool // 新設定是否接受
+	}{
+		{name: "supported", index: entities.Index{Period: PeriodMinutes, Unit: 5}, want: "*/5 * * * *", wantValid: true},
+		{name: "minutes 120 converts to hours", index: entities.Index{Period: PeriodMinutes, Unit: 120}, want: "0 */2 * * *", wantValid: true},
+		{name: "minutes 90", index: entities.Index{Period: PeriodMinutes, Unit: 90}, want: "@every 90m"},
+		{name: "hours 48", index: entities.Index{Period: PeriodHours, Unit: 48}, want: "@every 48h"},
+		{name: "minutes 1500", index: entities.Index{Period: PeriodMinutes, Unit: 1500}, want: "@every 1500m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IndexSchedule(tt.index)
+			if err != nil || got != tt.want {
+				t.Errorf("IndexSchedule() = %q, %v, want %q", got, err, tt.want)
+			}
+			if err := ValidateIndexSchedule(tt.index); (err == nil) != tt.wantValid {
+				t.Errorf("ValidateIndexSchedule() error = %v, want valid %v", err, tt.wantValid)
+			}
+		})
+	}
+
+	if _, err := IndexSchedule(entities.Index{Period: PeriodMinutes, Unit: 0}); err == nil {
+		t.Error("IndexSchedule() accepted unit 0")
+	}
+}
+
+func TestSchedulerRegistryListsFailedRegistrations(t *testing.T) {
+	setupTestEnv(t)
+	previous := global.Crontab
+	global.Crontab = cron.New()
+	t.Cleanup(func() { global.Crontab = previous })
+
+	r := &SchedulerRegistry{jobs: map[schedulerKey]*scheduledEntry{}, failed: map[schedulerKey]failedEntry{}}
+	target := entities.Target{ID: 1, Subject: "core"}
+	legacy := entities.Index{ID: 1, Logname: "legacy", Period: PeriodMinutes, Unit: 60}
+	broken := entities.Index{ID: 2, Logname: "broken", Period: PeriodCron, CronExpr: "61 * * * *"}
+	interval := entities.Index{ID: 3, Logname: "interval", Period: PeriodMinutes, Unit: 90}
+
+	desired := desiredJobs([]entities.Target{{ID: 1, Subject: "core", Enable: true, Indices: []entities.Index{legacy, broken, interval}}}, func(entities.Index) bool { return true })
+	result := r.sync(desired, func(schedulerKey) bool { return true }, false)
+	if result.Added != 2 || result.Failed != 1 {
+		t.Fatalf("sync result = %+v, want 2 added and 1 failed", result)
+	}
+
+	jobs := r.List()
+	if len(jobs) != 3 {
+		t.Fatalf("List() returned %d jobs, want 3", len(jobs))
+	}
+	byName := map[string]entities.ScheduledJob{}
+	for _, job := range jobs {
+		byName[job.Logname] = job
+	}
+	if job := byName["legacy"]; job.Schedule != "0 */1 * * *" || job.Error != "" {
+		t.Errorf("legacy job = %+v", job)
+	}
+	if job := byName["interval"]; job.Schedule != "@every 90m" || job.EntryID == 0 || job.Error != "" {
+		t.Errorf("interval job = %+v, want a running @every 90m entry", job)
+	}
+	if job := byName["broken"]; job.EntryID != 0 || job.Error == "" {
+		t.Errorf("broken job = %+v, want an error and no entry", job)
+	}
+
+	// 修正設定後重新加入即清除錯誤
+	broken.CronExpr = "*/30 * * * *"
+	if err := r.Add(target, broken); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	for _, job := range r.List() {
+		if job.Error != "" {
+			t.Errorf("job %s still has error %q", job.Logname, job.Error)
+		}
+	}
+
+	// 從資料庫移除後不再列出
+	r.failed[schedulerKey{1, 4}] = failedEntry{target: target, index: entities.Index{ID: 4}, err: "invalid"}
+	r.RemoveTarget(1)
+	if jobs := r.List(); len(jobs) != 0 {
+		t.Errorf("List() after RemoveTarget = %+v, want empty", jobs)
+	}
+}
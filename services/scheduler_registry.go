@@ -0,0 +1,508 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log-detect/entities"
+	"log-detect/global"
+	"log-detect/log"
+	"log-detect/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+// schedulerKey 排程的唯一鍵：同一個 index 可能屬於多個 target
+type schedulerKey struct {
+	TargetID int
+	IndexID  int
+}
+
+// scheduledEntry 排程器中的一筆工作
+type scheduledEntry struct {
+	target  entities.Target
+	index   entities.Index
+	spec    string
+	version string // 排程表示式與 target / index 更新時間，用來判斷是否需要重新排程
+	entryID cron.EntryID
+	paused  bool
+	prev    time.Time // 移除 cron entry 前最後一次執行時間
+}
+
+// failedEntry 加入排程失敗的工作，保留到成功加入或從資料庫移除為止
+type failedEntry struct {
+	target entities.Target
+	index  entities.Index
+	err    string
+}
+
+// SchedulerRegistry 以 (target ID, index ID) 管理 global.Crontab 中的偵測排程，
+// 取代原本記錄 entry ID 的 cron_lists 表；暫停狀態只存在記憶體中，重啟後恢復排程
+type SchedulerRegistry struct {
+	mutex  sync.Mutex
+	jobs   map[schedulerKey]*scheduledEntry
+	failed map[schedulerKey]failedEntry
+}
+
+var (
+	schedulerRegistry     *SchedulerRegistry
+	schedulerRegistryOnce sync.Once
+)
+
+// GetSchedulerRegistry 取得排程器單例
+func GetSchedulerRegistry() *SchedulerRegistry {
+	schedulerRegistryOnce.Do(func() {
+		schedulerRegistry = &SchedulerRegistry{
+			jobs:   make(map[schedulerKey]*scheduledEntry),
+			failed: make(map[schedulerKey]failedEntry),
+		}
+	})
+	return schedulerRegistry
+}
+
+// Add 加入 (target, index) 的排程，已存在時以新設定取代並保留暫停狀態
+func (r *SchedulerRegistry) Add(target entities.Target, index entities.Index) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.add(target, index)
+}
+
+// Reschedule 以新的 target / index 設定重新排程既有工作
+func (r *SchedulerRegistry) Reschedule(target entities.Target, index entities.Index) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.jobs[schedulerKey{target.ID, index.ID}]; !ok {
+		return fmt.Errorf("no job scheduled for target %d index %d", target.ID, index.ID)
+	}
+	return r.add(target, index)
+}
+
+// Remove 移除單一排程，回傳是否存在
+func (r *SchedulerRegistry) Remove(targetID, indexID int) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.remove(schedulerKey{targetID, indexID})
+}
+
+// RemoveTarget 移除 target 的所有排程，回傳移除數量
+func (r *SchedulerRegistry) RemoveTarget(targetID int) int {
+	return r.removeWhere(func(key schedulerKey) bool { return key.TargetID == targetID })
+}
+
+// RemoveIndex 移除 index 在所有 target 下的排程，回傳移除數量
+func (r *SchedulerRegistry) RemoveIndex(indexID int) int {
+	return r.removeWhere(func(key schedulerKey) bool { return key.IndexID == indexID })
+}
+
+// Pause 暫停排程：移除 cron entry 但保留工作，之後可 Resume
+func (r *SchedulerRegistry) Pause(targetID, indexID int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	job, ok := r.jobs[schedulerKey{targetID, indexID}]
+	if !ok {
+		return fmt.Errorf("no job scheduled for target %d index %d", targetID, indexID)
+	}
+	if job.paused {
+		return nil
+	}
+
+	r.unschedule(job)
+	job.paused = true
+	log.Logrecord_no_rotate("INFO", fmt.Sprintf("Schedule paused: target %d (%s), index %d (%s)", targetID, job.target.Subject, indexID, job.index.Logname))
+	return nil
+}
+
+// Resume 恢復已暫停的排程
+func (r *SchedulerRegistry) Resume(targetID, indexID int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	job, ok := r.jobs[schedulerKey{targetID, indexID}]
+	if !ok {
+		return fmt.Errorf("no job scheduled for target %d index %d", targetID, indexID)
+	}
+	if !job.paused {
+		return nil
+	}
+
+	if err := r.schedule(job); err != nil {
+		return err
+	}
+	job.paused = false
+	log.Logrecord_no_rotate("INFO", fmt.Sprintf("Schedule resumed: target %d (%s), index %d (%s)", targetID, job.target.Subject, indexID, job.index.Logname))
+	return nil
+}
+
+// Reconcile 依資料庫中啟用的 target 與其 indices 同步所有排程：
+// 加入缺少的、移除多餘的，設定有變動的重新排程，未變動的保留原 cron entry
+func (r *SchedulerRegistry) Reconcile() (entities.SchedulerReconcileResult, error) {
+	targets, err := GetAllTargetsData()
+	if err != nil {
+		return entities.SchedulerReconcileResult{}, err
+	}
+
+	desired := desiredJobs(targets, func(entities.Index) bool { return true })
+	return r.sync(desired, func(schedulerKey) bool { return true }, false), nil
+}
+
+// ReloadTarget 重新載入單一 target 的排程（target 已刪除時移除其所有排程）
+func (r *SchedulerRegistry) ReloadTarget(targetID int) (entities.SchedulerReconcileResult, error) {
+	targets := []entities.Target{}
+	target, err := GetTargetByID(targetID)
+	if err == nil {
+		targets = append(targets, target)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return entities.SchedulerReconcileResult{}, err
+	}
+
+	desired := desiredJobs(targets, func(entities.Index) bool { return true })
+	return r.sync(desired, func(key schedulerKey) bool { return key.TargetID == targetID }, true), nil
+}
+
+// ReloadIndex 重新載入 index 在所有所屬 target 下的排程
+func (r *SchedulerRegistry) ReloadIndex(indexID int) (entities.SchedulerReconcileResult, error) {
+	links := []entities.IndicesTargets{}
+	if err := global.Mysql.Where("index_id = ?", indexID).Find(&links).Error; err != nil {
+		return entities.SchedulerReconcileResult{}, err
+	}
+
+	targets := []entities.Target{}
+	for _, link := range links {
+		target, err := GetTargetByID(link.TargetID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return entities.SchedulerReconcileResult{}, err
+		}
+		targets = append(targets, target)
+	}
+
+	desired := desiredJobs(targets, func(index entities.Index) bool { return index.ID == indexID })
+	return r.sync(desired, func(key schedulerKey) bool { return key.IndexID == indexID }, true), nil
+}
+
+// List 列出所有排程與下次 / 上次執行時間，加入失敗的工作帶上 Error
+func (r *SchedulerRegistry) List() []entities.ScheduledJob {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	jobs := make([]entities.ScheduledJob, 0, len(r.jobs))
+	for key, job := range r.jobs {
+		item := entities.ScheduledJob{
+			TargetID: key.TargetID,
+			Subject:  job.target.Subject,
+			IndexID:  key.IndexID,
+			Logname:  job.index.Logname,
+			Schedule: job.spec,
+			EntryID:  int(job.entryID),
+			Paused:   job.paused,
+		}
+
+		prev := job.prev
+		if job.entryID != 0 {
+			entry := global.Crontab.Entry(job.entryID)
+			if !entry.Next.IsZero() {
+				next := entry.Next
+				item.Next = &next
+			}
+			if !entry.Prev.IsZero() {
+				prev = entry.Prev
+			}
+		}
+		if !prev.IsZero() {
+			item.Prev = &prev
+		}
+		if failed, ok := r.failed[key]; ok {
+			item.Error = failed.err
+		}
+
+		jobs = append(jobs, item)
+	}
+
+	// 從未成功加入的工作沒有 entry，一併列出以免排程消失後無從得知
+	for key, failed := range r.failed {
+		if _, ok := r.jobs[key]; ok {
+			continue
+		}
+		jobs = append(jobs, entities.ScheduledJob{
+			TargetID: key.TargetID,
+			Subject:  failed.target.Subject,
+			IndexID:  key.IndexID,
+			Logname:  failed.index.Logname,
+			Error:    failed.err,
+		})
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].TargetID != jobs[j].TargetID {
+			return jobs[i].TargetID < jobs[j].TargetID
+		}
+		return jobs[i].IndexID < jobs[j].IndexID
+	})
+	return jobs
+}
+
+// add 需持有 mutex；失敗時記錄原因供 List 列出，成功時清除
+func (r *SchedulerRegistry) add(target entities.Target, index entities.Index) error {
+	key := schedulerKey{target.ID, index.ID}
+	if err := r.addJob(key, target, index); err != nil {
+		r.failed[key] = failedEntry{target: target, index: index, err: err.Error()}
+		return err
+	}
+	delete(r.failed, key)
+	return nil
+}
+
+// addJob 需持有 mutex；排程表示式無效時保留原本的工作
+func (r *SchedulerRegistry) addJob(key schedulerKey, target entities.Target, index entities.Index) error {
+	spec, err := IndexSchedule(index)
+	if err != nil {
+		return fmt.Errorf("invalid schedule for %s: %w", index.Logname, err)
+	}
+
+	job, exists := r.jobs[key]
+	if exists {
+		r.unschedule(job)
+	} else {
+		job = &scheduledEntry{}
+	}
+	job.target = target
+	job.index = index
+	job.spec = spec
+	job.version = jobVersion(target, index)
+
+	if !job.paused {
+		if err := r.schedule(job); err != nil {
+			if !exists {
+				return err
+			}
+			// 舊的 entry 已移除，保留工作並標記為暫停，避免排程消失後無從得知
+			job.paused = true
+			return err
+		}
+	}
+
+	r.jobs[key] = job
+	return nil
+}
+
+// schedule 需持有 mutex；將工作加入 cron
+func (r *SchedulerRegistry) schedule(job *scheduledEntry) error {
+	target := job.target
+	index := job.index
+
+	schedule, err := parseSchedule(job.spec)
+	if err == nil {
+		job.entryID = global.Crontab.Schedule(schedule, cron.FuncJob(func() {
+			// 多實例部署時只有 leader 執行偵測，其他實例保留排程以便接手
+			if !IsLeader() {
+				return
+			}
+			// 交由執行器排隊，限制同時查詢同一個叢集的數量
+			GetDetectExecutor().Submit(target, index, time.Now())
+		}))
+	}
+	if err != nil {
+		msg := fmt.Sprintf("標的名稱: %s ,日誌名稱: %s , 初始化失敗", target.Subject, index.Logname)
+		log.Logrecord_no_rotate("排程 ", msg)
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Crontab AddFunc error: %s", err.Error()))
+		return err
+	}
+
+	msg := fmt.Sprintf("標的名稱: %s ,日誌名稱: %s , 初始化成功", target.Subject, index.Logname)
+	log.Logrecord_no_rotate("排程 ", msg)
+	return nil
+}
+
+// unschedule 需持有 mutex；從 cron 移除工作並保留上次執行時間
+func (r *SchedulerRegistry) unschedule(job *scheduledEntry) {
+	if job.entryID == 0 {
+		return
+	}
+	if prev := global.Crontab.Entry(job.entryID).Prev; !prev.IsZero() {
+		job.prev = prev
+	}
+	global.Crontab.Remove(job.entryID)
+	job.entryID = 0
+}
+
+// remove 需持有 mutex
+func (r *SchedulerRegistry) remove(key schedulerKey) bool {
+	delete(r.failed, key)
+	job, ok := r.jobs[key]
+	if !ok {
+		return false
+	}
+	r.unschedule(job)
+	delete(r.jobs, key)
+	return true
+}
+
+func (r *SchedulerRegistry) removeWhere(match func(schedulerKey) bool) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	removed := 0
+	for key := range r.jobs {
+		if match(key) && r.remove(key) {
+			removed++
+		}
+	}
+	for key := range r.failed {
+		if match(key) {
+			delete(r.failed, key)
+		}
+	}
+	return removed
+}
+
+// sync 將 scope 範圍內的排程同步為 desired；force 時即使設定未變動也重新排程
+func (r *SchedulerRegistry) sync(desired map[schedulerKey]desiredJob, scope func(schedulerKey) bool, force bool) entities.SchedulerReconcileResult {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	result := entities.SchedulerReconcileResult{}
+
+	for key := range r.jobs {
+		if !scope(key) {
+			continue
+		}
+		if _, ok := desired[key]; !ok {
+			r.remove(key)
+			result.Removed++
+		}
+	}
+	for key := range r.failed {
+		if _, ok := desired[key]; scope(key) && !ok {
+			delete(r.failed, key)
+		}
+	}
+
+	for key, want := range desired {
+		job, exists := r.jobs[key]
+		if exists && !force && job.version == jobVersion(want.target, want.index) {
+			result.Unchanged++
+			continue
+		}
+
+		if err := r.add(want.target, want.index); err != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Schedule target %d index %d failed: %s", key.TargetID, key.IndexID, err.Error()))
+			result.Failed++
+			continue
+		}
+		if exists {
+			result.Rescheduled++
+		} else {
+			result.Added++
+		}
+	}
+
+	return result
+}
+
+// desiredJob 資料庫中應有的排程
+type desiredJob struct {
+	target entities.Target
+	index  entities.Index
+}
+
+// desiredJobs 從 targets 中取出啟用 target 下符合條件的 indices
+func desiredJobs(targets []entities.Target, include func(entities.Index) bool) map[schedulerKey]desiredJob {
+	desired := make(map[schedulerKey]desiredJob)
+	for _, target := range targets {
+		// 檢查 Target 是否啟用
+		if !target.Enable {
+			log.Logrecord_no_rotate("INFO", fmt.Sprintf("Target '%s' is disabled, skipping initialization", target.Subject))
+			continue
+		}
+		for _, index := range target.Indices {
+			if include(index) {
+				desired[schedulerKey{target.ID, index.ID}] = desiredJob{target: target, index: index}
+			}
+		}
+	}
+	return desired
+}
+
+// jobVersion 排程的版本指紋；排程表示式無法解析時以空字串代入，讓 add 重新回報錯誤
+func jobVersion(target entities.Target, index entities.Index) string {
+	spec, _ := IndexSchedule(index)
+	return fmt.Sprintf("%s|%d|%d", spec, target.UpdatedAt, index.UpdatedAt)
+}
+
+// GetScheduledJobs 列出目前實例的所有偵測排程，包含加入失敗的工作
+func GetScheduledJobs() models.Response {
+	res := models.Response{}
+	res.Success = true
+	res.Msg = "Get scheduled jobs success"
+
+	jobs := GetSchedulerRegistry().List()
+	failed := 0
+	for _, job := range jobs {
+		if job.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		res.Msg = fmt.Sprintf("Get scheduled jobs success, %d failed to register", failed)
+	}
+	res.Body = jobs
+	return res
+}
+
+// PauseScheduledJob 暫停單一偵測排程
+func PauseScheduledJob(req entities.ScheduledJobRequest) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if err := GetSchedulerRegistry().Pause(req.TargetID, req.IndexID); err != nil {
+		res.Msg = err.Error()
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Pause scheduled job success"
+	res.Body = GetSchedulerRegistry().List()
+	return res
+}
+
+// ResumeScheduledJob 恢復單一偵測排程
+func ResumeScheduledJob(req entities.ScheduledJobRequest) models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	if err := GetSchedulerRegistry().Resume(req.TargetID, req.IndexID); err != nil {
+		res.Msg = err.Error()
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Resume scheduled job failed: %s", res.Msg))
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Resume scheduled job success"
+	res.Body = GetSchedulerRegistry().List()
+	return res
+}
+
+// ReconcileSchedules 依資料庫重新同步所有偵測排程
+func ReconcileSchedules() models.Response {
+	res := models.Response{}
+	res.Success = false
+
+	result, err := GetSchedulerRegistry().Reconcile()
+	if err != nil {
+		res.Msg = fmt.Sprintf("Reconcile schedules failed: %s", err.Error())
+		log.Logrecord_no_rotate("ERROR", res.Msg)
+		return res
+	}
+
+	res.Success = true
+	res.Msg = "Reconcile schedules success"
+	res.Body = result
+	return res
+}
@@ -83,37 +83,7 @@ func UpdateTarget(target entities.Target) models.Response {
 		return res
 	}
 
-	// cronlist table
-	entriesTable, err := GetEntryByTargetID(target.ID)
-	if err != nil {
-		res.Msg = fmt.Sprintf("Error when get data in CronList, err: %s", err)
-		log.Logrecord_no_rotate("ERROR", res.Msg)
-		return res
-	}
-
-	// 找出目前 Entries 中 與CronList 對應的 entryID 並刪除 Entries 中的 entry
-	entries := global.Crontab.Entries()
-
-	for _, entry := range entries {
-		for _, data := range entriesTable {
-			if data.EntryID == int(entry.ID) {
-				global.Crontab.Remove(entry.ID)
-			}
-
-		}
-
-	}
-
-	// 刪除 CronList 中對應的 entry ID
-	err = global.Mysql.Where("target_id = ?", target.ID).Delete(&entities.CronList{}).Error
-	if err != nil {
-		res.Msg = fmt.Sprintf("Error when deleting data in CronList table, err: %s", err)
-		log.Logrecord_no_rotate("ERROR", res.Msg)
-		return res
-	}
-
-
-	err = global.Mysql.Debug().Select("*").Where("id = ?", target.ID).Updates(&target).Error
+	err := global.Mysql.Debug().Select("*").Where("id = ?", target.ID).Updates(&target).Error
 	if err != nil {
 		res.Msg = "Update Fail"
 		return res
@@ -149,31 +119,8 @@ func DeleteTarget(id int) models.Response {
 		return res
 	}
 
-	entriesTable, err := GetEntryByTargetID(id)
-	if err != nil {
-		res.Msg = fmt.Sprintf("Error when get data in CronList, err: %s", err)
-		log.Logrecord_no_rotate("ERROR", res.Msg)
-		return res
-	}
-
-	// 找出目前 Entries 中 與CronList 對應的 entryID 並刪除 Entries 中的 entry
-	entries := global.Crontab.Entries()
-	for _, entry := range entries {
-		for _, data := range entriesTable {
-			if data.EntryID == int(entry.ID) {
-				global.Crontab.Remove(entry.ID)
-			}
-		}
-
-	}
-
-	// 刪除 CronList 中對應的 entry ID
-	err = global.Mysql.Where("target_id = ?", id).Delete(&entities.CronList{}).Error
-	if err != nil {
-		res.Msg = fmt.Sprintf("Error when deleting data in CronList table, err: %s", err)
-		log.Logrecord_no_rotate("ERROR", res.Msg)
-		return res
-	}
+	// 移除該 target 的所有排程
+	GetSchedulerRegistry().RemoveTarget(id)
 
 	err = global.Mysql.Where("id = ?", id).Delete(&entities.Target{}).Error
 	if err != nil {
@@ -229,15 +176,3 @@ func GetAllTargetsData() ([]entities.Target, error) {
 	}
 	return targets, nil
 }
-
-func GetEntryByTargetID(TargetID int) ([]entities.CronList, error) {
-	cronlist := []entities.CronList{}
-	// err := global.Mysql.Debug().Where("id = ?",scheduleID).Preload("Reports").Find(&schedule).Error
-
-	err := global.Mysql.Debug().Where("target_id = ?", TargetID).Find(&cronlist).Error
-	if err != nil {
-		return cronlist, err
-	}
-	return cronlist, nil
-
-}
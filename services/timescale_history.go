@@ -7,22 +7,41 @@ import (
 	"log-detect/log"
 	"log-detect/models"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+// 改名或合併前以舊群組名稱寫入的 device_metrics，透過 device_group_names 對應到目前的群組名稱
+const (
+	metricsWithGroup = "device_metrics m LEFT JOIN device_group_names g ON g.name = m.device_group"
+	metricsGroup     = "COALESCE(g.group_name, m.device_group)"
 )
 
+// lognameNow 以 logname 對應 index 的時區取得目前時間；device_metrics.date 依 index 時區寫入，查詢「今天」時須使用相同時區
+func lognameNow(logname string) time.Time {
+	var index entities.Index
+	if logname != "" {
+		if err := global.Mysql.Where("logname = ?", logname).Limit(1).Find(&index).Error; err != nil {
+			log.Logrecord_no_rotate("ERROR", fmt.Sprintf("find indices data error: %s", err.Error()))
+		}
+	}
+	return time.Now().In(indexLocation(index))
+}
+
 // GetHistoryDataByDeviceName_TS 從 TimescaleDB 查詢設備歷史 (替代 MySQL 版本)
 func GetHistoryDataByDeviceName_TS(logname string, name string) []entities.History {
 	histories := []entities.History{}
-	date := time.Now().Format("2006-01-02")
+	date := lognameNow(logname).Format("2006-01-02")
 
 	query := `
-		SELECT device_id, device_group, logname, status,
+		SELECT device_id, ` + metricsGroup + `, logname, status,
 		       CASE WHEN lost THEN 'true' ELSE 'false' END as lost,
 		       lost_num, date, hour_time, date_time, timestamp_unix,
 		       period, unit, COALESCE(target_id, 0), COALESCE(index_id, 0),
 		       response_time, data_count,
 		       COALESCE(error_msg, '') as error_msg,
 		       COALESCE(error_code, '') as error_code
-		FROM device_metrics
+		FROM ` + metricsWithGroup + `
 		WHERE logname = $1 AND device_id = $2 AND date = $3
 		ORDER BY time DESC
 	`
@@ -99,8 +118,8 @@ func CheckLogstatus_TS(logname string) entities.LognameCheck {
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("find indices data error: %s", index_err.Error()))
 	}
 
-	now := time.Now()
-	lastCrontabTime := GetLastCrontabTime(now, indices.Period, indices.Unit)
+	now := time.Now().In(indexLocation(indices))
+	lastCrontabTime := GetLastCrontabTime(now, indices)
 	date := now.Format("2006-01-02")
 
 	// 從 TimescaleDB 查詢歷史記錄
@@ -135,7 +154,7 @@ func GetHistoryStatistics_TS(logname, deviceGroup string, startDate, endDate str
 		SELECT
 			date,
 			logname,
-			device_group,
+			` + metricsGroup + ` as device_group,
 			COUNT(*) as total_checks,
 			COUNT(*) FILTER (WHERE status = 'online') as online_count,
 			COUNT(*) FILTER (WHERE status = 'offline') as offline_count,
@@ -143,10 +162,11 @@ func GetHistoryStatistics_TS(logname, deviceGroup string, startDate, endDate str
 			COUNT(*) FILTER (WHERE status = 'error') as error_count,
 			ROUND(AVG(response_time), 2) as avg_response_time,
 			ROUND(
-				(COUNT(*) FILTER (WHERE status = 'online')::DECIMAL / NULLIF(COUNT(*), 0)) * 100,
+				(COUNT(*) FILTER (WHERE status = 'online')::DECIMAL /
+				 NULLIF(COUNT(*) FILTER (WHERE status NOT IN ('maintenance', 'error')), 0)) * 100,
 				2
 			) as uptime_rate
-		FROM device_metrics
+		FROM ` + metricsWithGroup + `
 		WHERE 1=1
 	`
 
@@ -159,8 +179,9 @@ func GetHistoryStatistics_TS(logname, deviceGroup string, startDate, endDate str
 		argIndex++
 	}
 	if deviceGroup != "" {
-		query += fmt.Sprintf(" AND device_group = $%d", argIndex)
-		args = append(args, deviceGroup)
+		// 包含下層群組
+		query += fmt.Sprintf(" AND "+metricsGroup+" = ANY($%d)", argIndex)
+		args = append(args, pq.Array(deviceGroupTreeNames(deviceGroup)))
 		argIndex++
 	}
 	if startDate != "" {
@@ -173,7 +194,7 @@ func GetHistoryStatistics_TS(logname, deviceGroup string, startDate, endDate str
 		args = append(args, endDate)
 	}
 
-	query += " GROUP BY date, logname, device_group ORDER BY date DESC"
+	query += " GROUP BY date, logname, " + metricsGroup + " ORDER BY date DESC"
 
 	rows, err := global.TimescaleDB.Query(query, args...)
 	if err != nil {
@@ -212,7 +233,7 @@ func GetDeviceTimeline_TS(deviceName, logname string, days int) models.Response
 	timeline.Logname = logname
 
 	// 計算開始日期
-	startDate := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	startDate := lognameNow(logname).AddDate(0, 0, -days).Format("2006-01-02")
 
 	query := `
 		SELECT timestamp_unix, status, response_time, data_count, COALESCE(error_msg, '')
@@ -250,16 +271,16 @@ func GetTrendData_TS(logname, deviceGroup string, days int) models.Response {
 	res.Success = false
 
 	var trends []entities.TrendData
-	startDate := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	startDate := lognameNow(logname).AddDate(0, 0, -days).Format("2006-01-02")
 
 	query := `
 		SELECT
 			date,
-			ROUND(AVG(CASE WHEN NOT lost THEN 100 ELSE 0 END), 2) as uptime_rate,
-			SUM(CASE WHEN lost THEN 1 ELSE 0 END) as offline_count,
+			ROUND(AVG(CASE WHEN status IN ('maintenance', 'error') THEN NULL WHEN NOT lost THEN 100 ELSE 0 END), 2) as uptime_rate,
+			SUM(CASE WHEN lost AND status <> 'maintenance' THEN 1 ELSE 0 END) as offline_count,
 			SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) as error_count,
 			ROUND(AVG(response_time), 0) as avg_response_time
-		FROM device_metrics
+		FROM ` + metricsWithGroup + `
 		WHERE date >= $1
 	`
 
@@ -272,8 +293,8 @@ func GetTrendData_TS(logname, deviceGroup string, days int) models.Response {
 		argIndex++
 	}
 	if deviceGroup != "" {
-		query += fmt.Sprintf(" AND device_group = $%d", argIndex)
-		args = append(args, deviceGroup)
+		query += fmt.Sprintf(" AND "+metricsGroup+" = ANY($%d)", argIndex)
+		args = append(args, pq.Array(deviceGroupTreeNames(deviceGroup)))
 	}
 
 	query += " GROUP BY date ORDER BY date ASC"
@@ -307,18 +328,18 @@ func GetGroupStatistics_TS(logname string) models.Response {
 	res.Success = false
 
 	var statistics []entities.GroupStatistics
-	today := time.Now().Format("2006-01-02")
+	today := lognameNow(logname).Format("2006-01-02")
 
 	query := `
 		SELECT
 			d.device_group,
 			$1 as logname,
 			COUNT(DISTINCT d.id) as total_devices,
-			COUNT(DISTINCT CASE WHEN h.lost = false THEN h.device_id END) as online_devices,
-			COUNT(DISTINCT CASE WHEN h.lost = true THEN h.device_id END) as offline_devices,
+			COUNT(DISTINCT CASE WHEN h.lost = false AND h.status <> 'error' THEN h.device_id END) as online_devices,
+			COUNT(DISTINCT CASE WHEN h.lost = true AND h.status <> 'maintenance' THEN h.device_id END) as offline_devices,
 			MAX(h.date_time) as last_check_time,
 			ROUND(
-				(COUNT(DISTINCT CASE WHEN h.lost = false THEN h.device_id END)::DECIMAL /
+				(COUNT(DISTINCT CASE WHEN h.lost = false AND h.status <> 'error' THEN h.device_id END)::DECIMAL /
 				 NULLIF(COUNT(DISTINCT d.id), 0)) * 100,
 				2
 			) as uptime_rate
@@ -363,7 +384,6 @@ func GetDashboardData_TS() models.Response {
 	res.Success = false
 
 	var dashboard entities.DashboardData
-	today := time.Now().Format("2006-01-02")
 
 	// 從 MySQL 獲取目標統計
 	if err := global.Mysql.Model(&entities.Target{}).Count(&dashboard.TotalTargets).Error; err != nil {
@@ -376,26 +396,51 @@ func GetDashboardData_TS() models.Response {
 		return res
 	}
 
-	// 從 MySQL 獲取總設備數
+	// 從 MySQL 獲取總設備數（除役與預期缺席中的設備另外統計，不計入總數與離線）
 	if err := global.Mysql.Model(&entities.Device{}).Count(&dashboard.TotalDevices).Error; err != nil {
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to count devices: %s", err.Error()))
 		return res
 	}
 
-	// 從 TimescaleDB 獲取在線設備數
+	if err := global.Mysql.Model(&entities.Device{}).Where("status = ?", entities.DeviceDecommissioned).Count(&dashboard.DecommissionedDevices).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to count decommissioned devices: %s", err.Error()))
+		return res
+	}
+
+	if err := global.Mysql.Model(&entities.Device{}).Where("status = ? AND absent_until > ?", entities.DeviceExpectedAbsent, time.Now().Unix()).Count(&dashboard.ExpectedAbsentDevices).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to count expected absent devices: %s", err.Error()))
+		return res
+	}
+
+	dashboard.TotalDevices -= dashboard.DecommissionedDevices + dashboard.ExpectedAbsentDevices
+
+	// 各 index 的「今天」依其時區計算，與寫入 device_metrics.date 時一致
+	var indices []entities.Index
+	if err := global.Mysql.Find(&indices).Error; err != nil {
+		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to get indices: %s", err.Error()))
+		return res
+	}
+	lognames, todays := dashboardToday(indices, time.Now())
+
+	// 從 TimescaleDB 獲取在線設備數（維護中的設備不算離線，查詢失敗的 error 紀錄不算在線）
 	query := `
-		SELECT COUNT(DISTINCT device_id)
-		FROM device_metrics
-		WHERE date = $1 AND lost = false
+		SELECT COUNT(DISTINCT m.device_id)
+		FROM device_metrics m
+		JOIN unnest($1::text[], $2::text[]) AS t(logname, date) ON m.logname = t.logname AND m.date = t.date
+		WHERE (m.lost = false AND m.status <> 'error') OR m.status = 'maintenance'
 	`
 
-	err := global.TimescaleDB.QueryRow(query, today).Scan(&dashboard.OnlineDevices)
+	err := global.TimescaleDB.QueryRow(query, pq.Array(lognames), pq.Array(todays)).Scan(&dashboard.OnlineDevices)
 	if err != nil {
 		log.Logrecord_no_rotate("ERROR", fmt.Sprintf("Failed to count online devices: %s", err.Error()))
 		return res
 	}
 
 	dashboard.OfflineDevices = dashboard.TotalDevices - dashboard.OnlineDevices
+	if dashboard.OfflineDevices < 0 {
+		// 除役的設備仍有日誌時會計入在線數
+		dashboard.OfflineDevices = 0
+	}
 
 	// 計算在線率
 	if dashboard.TotalDevices > 0 {
@@ -414,3 +459,18 @@ func GetDashboardData_TS() models.Response {
 	res.Success = true
 	return res
 }
+
+// dashboardToday 回傳各 logname 與其 index 時區的當天日期（同一 logname 只取第一個 index）
+func dashboardToday(indices []entities.Index, now time.Time) ([]string, []string) {
+	seen := map[string]bool{}
+	var lognames, todays []string
+	for _, index := range indices {
+		if seen[index.Logname] {
+			continue
+		}
+		seen[index.Logname] = true
+		lognames = append(lognames, index.Logname)
+		todays = append(todays, now.In(indexLocation(index)).Format("2006-01-02"))
+	}
+	return lognames, todays
+}
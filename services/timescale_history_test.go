@@ -0,0 +1,28 @@
+package services
+
+import (
+	"log-detect/entities"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDashboardToday(t *testing.T) {
+	setupTestEnv(t)
+	// UTC 20:00 時台北已是隔天
+	now := time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)
+	indices := []entities.Index{
+		{Logname: "waf", Timezone: "Asia/Taipei"},
+		{Logname: "fw", Timezone: "UTC"},
+		{Logname: "waf", Timezone: "UTC"},
+		{Logname: "proxy"},
+	}
+
+	lognames, todays := dashboardToday(indices, now)
+	if want := []string{"waf", "fw", "proxy"}; !reflect.DeepEqual(lognames, want) {
+		t.Errorf("lognames = %v, want %v", lognames, want)
+	}
+	if want := []string{"2026-10-17", "2026-10-16", now.In(indexLocation(entities.Index{})).Format("2006-01-02")}; !reflect.DeepEqual(todays, want) {
+		t.Errorf("todays = %v, want %v", todays, want)
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+)
+
+// selectUpdateColumns 取出 fields（請求中有送出的 JSON 欄位）裡允許更新的欄位；
+// fields 為 nil（例如表單請求無法判斷）時回傳全部允許的欄位
+func selectUpdateColumns(allowed []string, fields []string) []string {
+	if fields == nil {
+		return append([]string{}, allowed...)
+	}
+	sent := map[string]bool{}
+	for _, field := range fields {
+		sent[field] = true
+	}
+	var columns []string
+	for _, column := range allowed {
+		if sent[column] {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// overlayColumns 將 src 中 json 名稱在 columns 內的欄位複製到 dst，其餘欄位保留 dst 原本（資料庫中）的值；
+// dst、src 為同型別的 struct 指標
+func overlayColumns(dst, src interface{}, columns []string) {
+	include := map[string]bool{}
+	for _, column := range columns {
+		include[column] = true
+	}
+
+	dv := reflect.ValueOf(dst).Elem()
+	sv := reflect.ValueOf(src).Elem()
+	for i := 0; i < dv.NumField(); i++ {
+		name := strings.Split(dv.Type().Field(i).Tag.Get("json"), ",")[0]
+		if include[name] {
+			dv.Field(i).Set(sv.Field(i))
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"log-detect/entities"
+	"reflect"
+	"testing"
+)
+
+func TestSelectUpdateColumns(t *testing.T) {
+	allowed := []string{"pattern", "field", "auto_add_devices"}
+	tests := []struct {
+		name   string
+		fields []string
+		want   []string
+	}{
+		{name: "form request", fields: nil, want: []string{"pattern", "field", "auto_add_devices"}},
+		{name: "only sent fields", fields: []string{"id", "field"}, want: []string{"field"}},
+		{name: "zero value sent", fields: []string{"auto_add_devices"}, want: []string{"auto_add_devices"}},
+		{name: "nothing updatable", fields: []string{"id", "created_at"}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectUpdateColumns(allowed, tt.fields); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectUpdateColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOverlayColumns(t *testing.T) {
+	stored := entities.Index{ID: 7, Pattern: "logs-*", Field: "host.name", AutoAddDevices: true, MissThreshold: 3}
+	update := entities.Index{ID: 7, Field: "host.hostname"}
+
+	overlayColumns(&stored, &update, selectUpdateColumns(indexUpdateColumns, []string{"id", "field", "auto_add_devices"}))
+
+	if stored.Field != "host.hostname" {
+		t.Errorf("Field = %q, want the sent value", stored.Field)
+	}
+	if stored.AutoAddDevices {
+		t.Error("AutoAddDevices should be cleared when false is sent")
+	}
+	if stored.Pattern != "logs-*" || stored.MissThreshold != 3 {
+		t.Errorf("unsent fields changed: pattern %q, miss_threshold %d", stored.Pattern, stored.MissThreshold)
+	}
+}
+
+func TestOverlayColumnsDeviceStatus(t *testing.T) {
+	until := int64(1700000000)
+	stored := entities.Device{ID: 3, Name: "fw01", Status: entities.DeviceActive, Tags: []string{"edge"}}
+	update := entities.Device{ID: 3, Name: "fw01", Owner: "ops@example.com", Status: "anything", AbsentUntil: &until}
+
+	overlayColumns(&stored, &update, selectUpdateColumns(deviceUpdateColumns, []string{"id", "name", "owner", "status", "absent_until"}))
+
+	if stored.Owner != "ops@example.com" {
+		t.Errorf("Owner = %q, want the sent value", stored.Owner)
+	}
+	if stored.Status != entities.DeviceActive || stored.AbsentUntil != nil {
+		t.Errorf("status = %q, absent_until = %v, want them left to SetDeviceStatus", stored.Status, stored.AbsentUntil)
+	}
+	if !reflect.DeepEqual(stored.Tags, []string{"edge"}) {
+		t.Errorf("Tags = %v, want unsent tags kept", stored.Tags)
+	}
+}
@@ -4,6 +4,9 @@ type EnviromentModel struct {
 	Database    database
 	Timescale   timescale    // 新增 TimescaleDB 配置
 	BatchWriter batchWriter  // 新增批量寫入配置
+	Detect      detect       // 設備偵測配置
+	HA          ha           // 多實例排程配置
+	Notify      notify       // 通知配置
 	Server      server
 	ES          es
 	LIST        list
@@ -93,3 +96,34 @@ type batchWriter struct {
 	BatchSize     int    `mapstructure:"batch_size"`
 	FlushInterval string `mapstructure:"flush_interval"`
 }
+
+// 設備偵測配置結構
+type detect struct {
+	PageSize     int    `mapstructure:"page_size"`     // composite aggregation 每頁 bucket 數
+	QueryTimeout string `mapstructure:"query_timeout"` // ES 查詢逾時，預設 30s（可由 ES 連線或 index 覆寫）
+	RunTimeout   string `mapstructure:"run_timeout"`   // 單次偵測的執行期限，預設 5m
+
+	// 偵測執行器
+	Workers            int    `mapstructure:"workers"`              // 同時執行的偵測數，預設 8
+	PerConnectionLimit int    `mapstructure:"per_connection_limit"` // 每個 ES 連線同時執行的偵測數，預設 4（可由 ES 連線覆寫）
+	StartJitter        string `mapstructure:"start_jitter"`         // 排程觸發後隨機延遲的上限，例如 20s，預設不延遲
+
+	// 同一 ES 連線、同一分鐘觸發的偵測以 _msearch 合併查詢第一頁
+	BatchSearch  bool   `mapstructure:"batch_search"`   // 預設關閉
+	BatchWindow  string `mapstructure:"batch_window"`   // 收集同批工作的等待時間，預設 500ms
+	MaxBatchSize int    `mapstructure:"max_batch_size"` // 單次 _msearch 最多合併的查詢數，預設 50
+}
+
+// 多實例排程（leader election）配置結構
+type ha struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	InstanceID    string `mapstructure:"instance_id"`    // 實例識別，預設為 hostname
+	LeaseTTL      string `mapstructure:"lease_ttl"`      // leader 租約有效時間，預設 30s
+	RenewInterval string `mapstructure:"renew_interval"` // 續約 / 搶佔間隔，預設 10s
+}
+
+// 通知配置結構
+type notify struct {
+	DigestInterval    string `mapstructure:"digest_interval"`     // 低重要性設備事件的摘要寄送間隔，預設 1h
+	DigestMaxAttempts int    `mapstructure:"digest_max_attempts"` // 摘要明細的重送次數上限，超過後捨棄，預設 5
+}
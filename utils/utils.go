@@ -37,6 +37,52 @@ func loadConfigFile() {
 func viperconfigToModel() {
 	// var c structs.ActionStruct
 	// c.Actions = viper.GetStringSlice("actions")
+
+	// config.yml 中的執行設定補上 setting.yml / 環境變數未設定的欄位
+	runtimeSettingsToModel(global.EnvConfig)
+}
+
+// runtimeSettingsToModel 讀取 HA 等執行設定；已有值的欄位不覆寫，因此 setting.yml / 環境變數優先於 config.yml
+func runtimeSettingsToModel(config *structs.EnviromentModel) {
+	// HA
+	setBool(&config.HA.Enabled, "ha.enabled")
+	setString(&config.HA.InstanceID, "ha.instance_id")
+	setString(&config.HA.LeaseTTL, "ha.lease_ttl")
+	setString(&config.HA.RenewInterval, "ha.renew_interval")
+
+	// Detect
+	setString(&config.Detect.QueryTimeout, "detect.query_timeout")
+	setString(&config.Detect.RunTimeout, "detect.run_timeout")
+	setInt(&config.Detect.Workers, "detect.workers")
+	setInt(&config.Detect.PerConnectionLimit, "detect.per_connection_limit")
+	setString(&config.Detect.StartJitter, "detect.start_jitter")
+	setInt(&config.Detect.PageSize, "detect.page_size")
+	setBool(&config.Detect.BatchSearch, "detect.batch_search")
+	setString(&config.Detect.BatchWindow, "detect.batch_window")
+	setInt(&config.Detect.MaxBatchSize, "detect.max_batch_size")
+
+	// Notify
+	setString(&config.Notify.DigestInterval, "notify.digest_interval")
+	setInt(&config.Notify.DigestMaxAttempts, "notify.digest_max_attempts")
+}
+
+// setString 欄位為空時讀取 key
+func setString(field *string, key string) {
+	if *field == "" {
+		*field = viper.GetString(key)
+	}
+}
+
+// setInt 欄位為 0 時讀取 key
+func setInt(field *int, key string) {
+	if *field == 0 {
+		*field = viper.GetInt(key)
+	}
+}
+
+// setBool 任一設定來源啟用即為 true
+func setBool(field *bool, key string) {
+	*field = *field || viper.GetBool(key)
 }
 
 func loadSettingFile() {
@@ -104,6 +150,9 @@ func viperSettingToModel() {
 	config.BatchWriter.BatchSize = viper.GetInt("batch_writer.batch_size")
 	config.BatchWriter.FlushInterval = viper.GetString("batch_writer.flush_interval")
 
+	// Detect
+	config.Detect.PageSize = viper.GetInt("detect.page_size")
+
 	config.Cors.Allow.Headers = viper.GetStringSlice("cors.allow.headers")
 
 	config.Server.Mode = viper.GetString("server.mode")
@@ -128,6 +177,8 @@ func viperSettingToModel() {
 	config.Email.DisableTLS = viper.GetBool("email.disable_tls")
 	config.Email.Auth = viper.GetBool("email.auth")
 
+	runtimeSettingsToModel(&config)
+
 	global.EnvConfig = &config
 	// global.Action = &action
 }